@@ -7,17 +7,25 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/alaajili/task-scheduler/api-server/internal/handlers"
 	"github.com/alaajili/task-scheduler/api-server/internal/repository"
 	"github.com/alaajili/task-scheduler/api-server/internal/service"
+	"github.com/alaajili/task-scheduler/shared/callback"
 	"github.com/alaajili/task-scheduler/shared/config"
 	"github.com/alaajili/task-scheduler/shared/database"
 	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/notify"
+	"github.com/alaajili/task-scheduler/shared/outbox"
+	"github.com/alaajili/task-scheduler/shared/queue"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// idempotencyPurgeInterval is how often expired idempotency keys are deleted
+const idempotencyPurgeInterval = time.Hour
+
 func main() {
 	// Initialize logger
 	if err := logger.Init("development"); err != nil {
@@ -39,13 +47,73 @@ func main() {
 	}
 	defer db.Close()
 
+	// Initialize redis queue
+	rq, err := queue.NewRedisQueue(cfg.Redis)
+	if err != nil {
+		logger.Fatal("Failed to connect to redis", zap.Error(err))
+	}
+	defer rq.Close()
+
+	// Start the outbox relay that publishes committed tasks to the queue
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	relay := outbox.NewRelay(db, rq, cfg.Outbox)
+	go relay.Run(relayCtx)
+
+	// Start the dispatcher that delivers task callbacks to their urls
+	dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+	defer stopDispatch()
+	dispatcher := callback.NewDispatcher(db, cfg.Callback)
+	go dispatcher.Run(dispatchCtx)
+
+	// Start listening for finished tasks and task events, which wake up the
+	// requests waiting for a task and feed the event streams
+	listenCtx, stopListen := context.WithCancel(context.Background())
+	defer stopListen()
+	listener := notify.NewListener(cfg.Database)
+	if err := listener.Start(listenCtx); err != nil {
+		logger.Fatal("Failed to start task notification listener", zap.Error(err))
+	}
+
 	// Initialize repositories, services, and handlers
 	taskRepository := repository.NewTaskRepository(db)
-	taskService := service.NewTaskService(taskRepository)
+	taskService := service.NewTaskService(taskRepository, relay, rq)
+	taskService.SetIdempotencyRetention(cfg.Server.IdempotencyRetention)
+	taskService.SetListener(listener)
 	taskHandler := handlers.NewTaskHandler(taskService)
+	scheduleRepository := repository.NewScheduleRepository(db)
+	scheduleService := service.NewScheduleService(scheduleRepository)
+	scheduleHandler := handlers.NewScheduleHandler(scheduleService)
+	workflowRepository := repository.NewWorkflowRepository(db)
+	workflowService := service.NewWorkflowService(workflowRepository, taskRepository, taskService)
+	workflowHandler := handlers.NewWorkflowHandler(workflowService)
+	batchRepository := repository.NewBatchRepository(db)
+	batchService := service.NewBatchService(batchRepository, taskRepository, taskService)
+	batchHandler := handlers.NewBatchHandler(batchService)
+	groupRepository := repository.NewGroupRepository(db)
+	groupService := service.NewGroupService(groupRepository)
+	groupHandler := handlers.NewGroupHandler(groupService)
+	callbackRepository := repository.NewCallbackRepository(db)
+	callbackService := service.NewCallbackService(callbackRepository)
+	callbackHandler := handlers.NewCallbackHandler(callbackService)
+	eventRepository := repository.NewEventRepository(db)
+	eventService := service.NewEventService(eventRepository, taskRepository, listener)
+	eventHandler := handlers.NewEventHandler(eventService)
+	historyRepository := repository.NewHistoryRepository(db)
+	historyService := service.NewHistoryService(historyRepository)
+	historyHandler := handlers.NewHistoryHandler(historyService)
+	deadLetterRepository := repository.NewDeadLetterRepository(db)
+	deadLetterService := service.NewDeadLetterService(deadLetterRepository, historyRepository, taskRepository, taskService)
+	deadLetterHandler := handlers.NewDeadLetterHandler(deadLetterService)
 	healthHandler := handlers.NewHealthHandler(db)
 
-	router := setupRouter(taskHandler, healthHandler)
+	// Expired idempotency keys are taken over on reuse anyway, purging them
+	// only keeps the table small
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	defer stopPurge()
+	go taskService.PurgeIdempotencyKeys(purgeCtx, idempotencyPurgeInterval)
+
+	router := setupRouter(taskHandler, scheduleHandler, workflowHandler, batchHandler, groupHandler, callbackHandler, eventHandler, historyHandler, deadLetterHandler, healthHandler)
 
 	// Start the server
 	srv := &http.Server{
@@ -54,6 +122,7 @@ func main() {
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 	}
+	srv.RegisterOnShutdown(eventHandler.Close)
 	go func() {
 		logger.Info("Starting API server", zap.Int("port", cfg.Server.Port))
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -73,11 +142,26 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Fatal("Server forced to shutdown", zap.Error(err))
 	}
+	stopRelay()
+	stopDispatch()
+	stopPurge()
+	stopListen()
 
 	logger.Info("Server exiting")
 }
 
-func setupRouter(taskHandler *handlers.TaskHandler, healthHandler *handlers.HealthHandler) *gin.Engine {
+func setupRouter(
+	taskHandler *handlers.TaskHandler,
+	scheduleHandler *handlers.ScheduleHandler,
+	workflowHandler *handlers.WorkflowHandler,
+	batchHandler *handlers.BatchHandler,
+	groupHandler *handlers.GroupHandler,
+	callbackHandler *handlers.CallbackHandler,
+	eventHandler *handlers.EventHandler,
+	historyHandler *handlers.HistoryHandler,
+	deadLetterHandler *handlers.DeadLetterHandler,
+	healthHandler *handlers.HealthHandler,
+) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 
@@ -96,10 +180,56 @@ func setupRouter(taskHandler *handlers.TaskHandler, healthHandler *handlers.Heal
 		tasks := apiV1.Group("/tasks")
 		{
 			tasks.POST("", taskHandler.CreateTask)
+			tasks.POST("/batch", batchHandler.CreateBatch)
+			tasks.POST("/retry", taskHandler.RetryTasks)
 			tasks.GET("/:id", taskHandler.GetTask)
+			tasks.GET("/:id/callbacks", callbackHandler.ListCallbacks)
+			tasks.GET("/:id/wait", taskHandler.WaitForTask)
+			tasks.GET("/:id/events", eventHandler.StreamTaskEvents)
+			tasks.GET("/:id/history", historyHandler.GetHistory)
 			tasks.GET("", taskHandler.ListTasks)
+			tasks.POST("/:id/retry", taskHandler.RetryTask)
+			tasks.PATCH("/:id", taskHandler.UpdateTask)
 			tasks.DELETE("/:id", taskHandler.CancelTask)
 		}
+
+		schedules := apiV1.Group("/schedules")
+		{
+			schedules.POST("", scheduleHandler.CreateSchedule)
+			schedules.GET("/:id", scheduleHandler.GetSchedule)
+			schedules.GET("", scheduleHandler.ListSchedules)
+			schedules.PUT("/:id", scheduleHandler.UpdateSchedule)
+			schedules.DELETE("/:id", scheduleHandler.DeleteSchedule)
+		}
+
+		workflows := apiV1.Group("/workflows")
+		{
+			workflows.POST("", workflowHandler.CreateWorkflow)
+			workflows.GET("/:id", workflowHandler.GetWorkflow)
+		}
+
+		batches := apiV1.Group("/batches")
+		{
+			batches.GET("/:id", batchHandler.GetBatch)
+			batches.DELETE("/:id", batchHandler.CancelBatch)
+		}
+
+		apiV1.GET("/events", eventHandler.StreamEvents)
+
+		groups := apiV1.Group("/groups")
+		{
+			groups.GET("/:id", groupHandler.GetGroup)
+		}
+
+		deadLetters := apiV1.Group("/dead-letters")
+		{
+			deadLetters.GET("", deadLetterHandler.ListDeadLetters)
+			deadLetters.DELETE("", deadLetterHandler.PurgeDeadLetters)
+			deadLetters.POST("/replay", deadLetterHandler.ReplayDeadLetters)
+			deadLetters.GET("/:id", deadLetterHandler.GetDeadLetter)
+			deadLetters.POST("/:id/replay", deadLetterHandler.ReplayDeadLetter)
+			deadLetters.DELETE("/:id", deadLetterHandler.PurgeDeadLetter)
+		}
 	}
 
 	return router
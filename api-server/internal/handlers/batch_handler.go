@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type BatchHandler struct {
+	service *service.BatchService
+}
+
+func NewBatchHandler(service *service.BatchService) *BatchHandler {
+	return &BatchHandler{service: service}
+}
+
+func (h *BatchHandler) CreateBatch(c *gin.Context) {
+	var req service.CreateBatchRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	result, err := h.service.CreateBatch(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	switch {
+	case result.Created == 0:
+		c.JSON(http.StatusBadRequest, result)
+	case result.Failed > 0:
+		// some of the tasks were created, the results say which
+		c.JSON(http.StatusMultiStatus, result)
+	default:
+		c.JSON(http.StatusCreated, result)
+	}
+}
+
+func (h *BatchHandler) GetBatch(c *gin.Context) {
+	status, err := h.service.GetBatch(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *BatchHandler) CancelBatch(c *gin.Context) {
+	batchID := c.Param("id")
+
+	cancelled, err := h.service.CancelBatch(c.Request.Context(), batchID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"batch_id":  batchID,
+		"cancelled": len(cancelled),
+	})
+}
@@ -0,0 +1,154 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/handlers"
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/api-server/internal/service"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupBatchRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	db := testutil.TestDB(t)
+	taskRepo := repository.NewTaskRepository(db)
+	taskService := service.NewTaskService(taskRepo, nil, nil)
+	batchService := service.NewBatchService(repository.NewBatchRepository(db), taskRepo, taskService)
+	taskHandler := handlers.NewTaskHandler(taskService)
+	batchHandler := handlers.NewBatchHandler(batchService)
+
+	router := gin.New()
+	router.GET("/api/v1/tasks", taskHandler.ListTasks)
+	router.POST("/api/v1/tasks/batch", batchHandler.CreateBatch)
+	router.GET("/api/v1/batches/:id", batchHandler.GetBatch)
+	router.DELETE("/api/v1/batches/:id", batchHandler.CancelBatch)
+	return router
+}
+
+func batchTask(to string) map[string]any {
+	return map[string]any{
+		"type":    models.TaskTypeEmailSend,
+		"payload": map[string]any{"to": to},
+	}
+}
+
+func TestCreateBatch(t *testing.T) {
+	router := setupBatchRouter(t)
+
+	tasks := make([]any, 0, 1500)
+	for i := 0; i < 1500; i++ {
+		tasks = append(tasks, batchTask("user@example.com"))
+	}
+	tasks[3] = map[string]any{"type": models.TaskTypeEmailSend, "payload": map[string]any{}, "delay": "1h"}
+
+	w := sendJSON(router, "POST", "/api/v1/tasks/batch", map[string]any{"tasks": tasks})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var result service.BatchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.NotEmpty(t, result.BatchID)
+	assert.Equal(t, 1500, result.Created)
+	assert.Equal(t, 0, result.Failed)
+	require.Len(t, result.Results, 1500)
+	assert.Equal(t, models.TaskStateScheduled, result.Results[3].State)
+
+	w = sendJSON(router, "GET", "/api/v1/batches/"+result.BatchID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var status models.BatchStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, 1500, status.Size)
+	assert.Equal(t, 1499, status.Counts[models.TaskStatePending])
+	assert.Equal(t, 1, status.Counts[models.TaskStateScheduled])
+	assert.Equal(t, result.BatchID, status.Tasks[0].BatchID)
+
+	w = sendJSON(router, "GET", "/api/v1/tasks?limit=5&batch_id="+result.BatchID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":5`)
+}
+
+func TestCreateBatch_PartialFailure(t *testing.T) {
+	router := setupBatchRouter(t)
+
+	req := map[string]any{"tasks": []any{
+		batchTask("a@example.com"),
+		map[string]any{"type": "unknown", "payload": map[string]any{}},
+		map[string]any{"type": models.TaskTypeEmailSend},
+		map[string]any{"type": models.TaskTypeEmailSend, "payload": map[string]any{}, "depends_on": []string{"x"}},
+		batchTask("b@example.com"),
+	}}
+
+	w := sendJSON(router, "POST", "/api/v1/tasks/batch", req)
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var result service.BatchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, 2, result.Created)
+	assert.Equal(t, 3, result.Failed)
+	assert.NotEmpty(t, result.Results[0].TaskID)
+	assert.Contains(t, result.Results[1].Error, "invalid task type")
+	assert.Contains(t, result.Results[2].Error, "payload is required")
+	assert.Contains(t, result.Results[3].Error, "not supported in batches")
+	assert.Empty(t, result.Results[3].TaskID)
+	assert.NotEmpty(t, result.Results[4].TaskID)
+
+	w = sendJSON(router, "GET", "/api/v1/batches/"+result.BatchID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var status models.BatchStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Len(t, status.Tasks, 2)
+}
+
+func TestCreateBatch_Invalid(t *testing.T) {
+	router := setupBatchRouter(t)
+
+	// no valid task, no batch
+	req := map[string]any{"tasks": []any{map[string]any{"type": "unknown", "payload": map[string]any{}}}}
+	w := sendJSON(router, "POST", "/api/v1/tasks/batch", req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NotContains(t, w.Body.String(), "batch_id")
+
+	assert.Equal(t, http.StatusBadRequest, sendJSON(router, "POST", "/api/v1/tasks/batch", map[string]any{"tasks": []any{}}).Code)
+
+	tooMany := make([]any, service.MaxBatchSize+1)
+	for i := range tooMany {
+		tooMany[i] = batchTask("a@example.com")
+	}
+	assert.Equal(t, http.StatusBadRequest, sendJSON(router, "POST", "/api/v1/tasks/batch", map[string]any{"tasks": tooMany}).Code)
+}
+
+func TestCancelBatch(t *testing.T) {
+	router := setupBatchRouter(t)
+
+	req := map[string]any{"tasks": []any{batchTask("a@example.com"), batchTask("b@example.com")}}
+	w := sendJSON(router, "POST", "/api/v1/tasks/batch", req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var result service.BatchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+
+	w = sendJSON(router, "DELETE", "/api/v1/batches/"+result.BatchID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"cancelled":2`)
+
+	w = sendJSON(router, "GET", "/api/v1/batches/"+result.BatchID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var status models.BatchStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, models.TaskStateCancelled, status.State)
+	assert.Equal(t, 2, status.Counts[models.TaskStateCancelled])
+
+	// nothing left to cancel
+	w = sendJSON(router, "DELETE", "/api/v1/batches/"+result.BatchID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"cancelled":0`)
+
+	assert.Equal(t, http.StatusNotFound, sendJSON(router, "DELETE", "/api/v1/batches/nonexistent-id", nil).Code)
+	assert.Equal(t, http.StatusNotFound, sendJSON(router, "GET", "/api/v1/batches/nonexistent-id", nil).Code)
+}
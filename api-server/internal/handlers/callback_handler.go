@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type CallbackHandler struct {
+	service *service.CallbackService
+}
+
+func NewCallbackHandler(service *service.CallbackService) *CallbackHandler {
+	return &CallbackHandler{service: service}
+}
+
+func (h *CallbackHandler) ListCallbacks(c *gin.Context) {
+	taskID := c.Param("id")
+
+	callbacks, err := h.service.ListCallbacks(c.Request.Context(), taskID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"task_id":   taskID,
+		"callbacks": callbacks,
+	})
+}
@@ -0,0 +1,180 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/handlers"
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/api-server/internal/service"
+	"github.com/alaajili/task-scheduler/shared/callback"
+	"github.com/alaajili/task-scheduler/shared/config"
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupCallbackRouter(t *testing.T) (*gin.Engine, *database.DB) {
+	gin.SetMode(gin.TestMode)
+
+	db := testutil.TestDB(t)
+	taskHandler := handlers.NewTaskHandler(service.NewTaskService(repository.NewTaskRepository(db), nil, nil))
+	callbackHandler := handlers.NewCallbackHandler(service.NewCallbackService(repository.NewCallbackRepository(db)))
+
+	router := gin.New()
+	router.POST("/api/v1/tasks", taskHandler.CreateTask)
+	router.DELETE("/api/v1/tasks/:id", taskHandler.CancelTask)
+	router.GET("/api/v1/tasks/:id/callbacks", callbackHandler.ListCallbacks)
+	return router, db
+}
+
+// receiver records the callbacks posted to it and answers with status
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	r.mu.Unlock()
+	w.WriteHeader(r.status)
+}
+
+func createCallbackTask(t *testing.T, router *gin.Engine, url, secret string) string {
+	w := sendJSON(router, "POST", "/api/v1/tasks", map[string]any{
+		"type":            models.TaskTypeEmailSend,
+		"payload":         map[string]any{"to": "user@example.com"},
+		"callback_url":    url,
+		"callback_secret": secret,
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var task models.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+	assert.Equal(t, url, task.CallbackURL)
+	if secret != "" {
+		assert.NotContains(t, w.Body.String(), secret)
+	}
+	return task.ID
+}
+
+func listCallbacks(t *testing.T, router *gin.Engine, taskID string) []models.Callback {
+	w := sendJSON(router, "GET", "/api/v1/tasks/"+taskID+"/callbacks", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Callbacks []models.Callback `json:"callbacks"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Callbacks
+}
+
+func TestCallback_Delivered(t *testing.T) {
+	router, db := setupCallbackRouter(t)
+	recv := &receiver{status: http.StatusNoContent}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	taskID := createCallbackTask(t, router, server.URL+"/hooks", "s3cret")
+	assert.Empty(t, listCallbacks(t, router, taskID))
+
+	require.Equal(t, http.StatusOK, sendJSON(router, "DELETE", "/api/v1/tasks/"+taskID, nil).Code)
+
+	dispatcher := callback.NewDispatcher(db, config.CallbackConfig{})
+	delivered, err := dispatcher.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+
+	require.Len(t, recv.requests, 1)
+	req, body := recv.requests[0], recv.bodies[0]
+	assert.Equal(t, "/hooks", req.URL.Path)
+	assert.Equal(t, string(models.CallbackEventCancelled), req.Header.Get(callback.EventHeader))
+	assert.Equal(t, "1", req.Header.Get(callback.AttemptHeader))
+	assert.Equal(t, callback.Sign("s3cret", body), req.Header.Get(callback.SignatureHeader))
+
+	var payload models.CallbackPayload
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, models.CallbackEventCancelled, payload.Event)
+	assert.Equal(t, taskID, payload.TaskID)
+	assert.Equal(t, models.TaskStateCancelled, payload.State)
+
+	callbacks := listCallbacks(t, router, taskID)
+	require.Len(t, callbacks, 1)
+	assert.Equal(t, models.CallbackStateDelivered, callbacks[0].State)
+	assert.NotNil(t, callbacks[0].DeliveredAt)
+	assert.Nil(t, callbacks[0].NextAttemptAt)
+	require.Len(t, callbacks[0].AttemptLog, 1)
+	assert.Equal(t, http.StatusNoContent, callbacks[0].AttemptLog[0].StatusCode)
+
+	// delivered callbacks are not sent again
+	delivered, err = dispatcher.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+	assert.Len(t, recv.requests, 1)
+}
+
+func TestCallback_Retried(t *testing.T) {
+	router, db := setupCallbackRouter(t)
+	recv := &receiver{status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	taskID := createCallbackTask(t, router, server.URL, "")
+	require.Equal(t, http.StatusOK, sendJSON(router, "DELETE", "/api/v1/tasks/"+taskID, nil).Code)
+
+	dispatcher := callback.NewDispatcher(db, config.CallbackConfig{
+		MaxAttempts: 2,
+		Backoff:     time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+	})
+	delivered, err := dispatcher.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+	assert.Empty(t, recv.requests[0].Header.Get(callback.SignatureHeader))
+
+	callbacks := listCallbacks(t, router, taskID)
+	require.Len(t, callbacks, 1)
+	assert.Equal(t, models.CallbackStatePending, callbacks[0].State)
+	assert.NotNil(t, callbacks[0].NextAttemptAt)
+	require.Len(t, callbacks[0].AttemptLog, 1)
+	assert.Contains(t, callbacks[0].AttemptLog[0].Error, "503")
+
+	// the second attempt is the last one
+	time.Sleep(10 * time.Millisecond)
+	_, err = dispatcher.DispatchOnce(context.Background())
+	require.NoError(t, err)
+	require.Len(t, recv.requests, 2)
+	assert.Equal(t, "2", recv.requests[1].Header.Get(callback.AttemptHeader))
+
+	callbacks = listCallbacks(t, router, taskID)
+	assert.Equal(t, models.CallbackStateFailed, callbacks[0].State)
+	assert.Equal(t, 2, callbacks[0].Attempts)
+	assert.Len(t, callbacks[0].AttemptLog, 2)
+}
+
+func TestCallback_Invalid(t *testing.T) {
+	router, _ := setupCallbackRouter(t)
+
+	create := func(body map[string]any) int {
+		body["type"] = models.TaskTypeEmailSend
+		body["payload"] = map[string]any{}
+		return sendJSON(router, "POST", "/api/v1/tasks", body).Code
+	}
+	assert.Equal(t, http.StatusBadRequest, create(map[string]any{"callback_url": "not a url"}))
+	assert.Equal(t, http.StatusBadRequest, create(map[string]any{"callback_url": "ftp://example.com"}))
+	assert.Equal(t, http.StatusBadRequest, create(map[string]any{"callback_secret": "s3cret"}))
+
+	assert.Equal(t, http.StatusNotFound, sendJSON(router, "GET", "/api/v1/tasks/nonexistent-id/callbacks", nil).Code)
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/api-server/internal/service"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultDeadLetterLimit is the page size when the request gives no limit
+const defaultDeadLetterLimit = 100
+
+type DeadLetterHandler struct {
+	service *service.DeadLetterService
+}
+
+func NewDeadLetterHandler(service *service.DeadLetterService) *DeadLetterHandler {
+	return &DeadLetterHandler{service: service}
+}
+
+// ReplayDeadLettersRequest selects the dead letters to replay like the query
+// parameters of ListDeadLetters, Reason is recorded in their history
+type ReplayDeadLettersRequest struct {
+	Type        models.TaskType    `json:"type"`
+	ErrorReason models.ErrorReason `json:"error_reason"`
+	After       *time.Time         `json:"after"`
+	Before      *time.Time         `json:"before"`
+	Limit       int                `json:"limit"`
+	Reason      string             `json:"reason"`
+}
+
+// replayRequest is the optional body of a single replay
+type replayRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (h *DeadLetterHandler) ListDeadLetters(c *gin.Context) {
+	filter, err := deadLetterFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultDeadLetterLimit
+	}
+
+	deadLetters, total, err := h.service.ListDeadLetters(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"dead_letters": deadLetters,
+		"count":        len(deadLetters),
+		"total":        total,
+	})
+}
+
+func (h *DeadLetterHandler) GetDeadLetter(c *gin.Context) {
+	deadLetter, err := h.service.GetDeadLetter(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, deadLetter)
+}
+
+func (h *DeadLetterHandler) ReplayDeadLetter(c *gin.Context) {
+	var req replayRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.service.ReplayDeadLetter(c.Request.Context(), c.Param("id"), req.Reason)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
+
+func (h *DeadLetterHandler) ReplayDeadLetters(c *gin.Context) {
+	var req ReplayDeadLettersRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := repository.DeadLetterFilter{
+		Type:        req.Type,
+		ErrorReason: req.ErrorReason,
+		After:       req.After,
+		Before:      req.Before,
+		Limit:       req.Limit,
+	}
+	ids, err := h.service.ReplayDeadLetters(c.Request.Context(), filter, req.Reason)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"replayed": len(ids),
+		"task_ids": ids,
+	})
+}
+
+func (h *DeadLetterHandler) PurgeDeadLetter(c *gin.Context) {
+	if err := h.service.PurgeDeadLetter(c.Request.Context(), c.Param("id")); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "dead letter purged successfully"})
+}
+
+// PurgeDeadLetters drops every dead letter selected by the query parameters
+// of ListDeadLetters, limit and offset aside
+func (h *DeadLetterHandler) PurgeDeadLetters(c *gin.Context) {
+	filter, err := deadLetterFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	purged, err := h.service.PurgeDeadLetters(c.Request.Context(), filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+// deadLetterFilter reads the type, error_reason, after, before (RFC3339),
+// limit and offset query parameters
+func deadLetterFilter(c *gin.Context) (repository.DeadLetterFilter, error) {
+	filter := repository.DeadLetterFilter{
+		Type:        models.TaskType(c.Query("type")),
+		ErrorReason: models.ErrorReason(c.Query("error_reason")),
+	}
+	for key, dest := range map[string]**time.Time{"after": &filter.After, "before": &filter.Before} {
+		if value := c.Query(key); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			*dest = &t
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil {
+			filter.Limit = l
+		}
+	}
+	if offset := c.Query("offset"); offset != "" {
+		if o, err := strconv.Atoi(offset); err == nil {
+			filter.Offset = o
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,179 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/handlers"
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/api-server/internal/service"
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/lifecycle"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupDeadLetterRouter(t *testing.T) (*gin.Engine, *database.DB) {
+	gin.SetMode(gin.TestMode)
+
+	db := testutil.TestDB(t)
+	taskRepo := repository.NewTaskRepository(db)
+	deadLetterService := service.NewDeadLetterService(
+		repository.NewDeadLetterRepository(db),
+		repository.NewHistoryRepository(db),
+		taskRepo,
+		service.NewTaskService(taskRepo, nil, nil),
+	)
+	handler := handlers.NewDeadLetterHandler(deadLetterService)
+
+	router := gin.New()
+	deadLetters := router.Group("/api/v1/dead-letters")
+	{
+		deadLetters.GET("", handler.ListDeadLetters)
+		deadLetters.DELETE("", handler.PurgeDeadLetters)
+		deadLetters.POST("/replay", handler.ReplayDeadLetters)
+		deadLetters.GET("/:id", handler.GetDeadLetter)
+		deadLetters.POST("/:id/replay", handler.ReplayDeadLetter)
+		deadLetters.DELETE("/:id", handler.PurgeDeadLetter)
+	}
+	return router, db
+}
+
+// deadLetterTask creates a task that failed for good, the way a worker fails it
+func deadLetterTask(t *testing.T, db *database.DB, taskType models.TaskType, reason models.ErrorReason) string {
+	ctx := context.Background()
+
+	task := models.NewTask(taskType, json.RawMessage(`{"to": "user@example.com"}`), 5)
+	testutil.CreateTestTask(t, db, task)
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, `
+		UPDATE tasks
+		SET state = 'failed', error = 'boom', error_reason = $2, retry_count = max_retries, completed_at = NOW()
+		WHERE id = $1
+	`, task.ID, reason)
+	require.NoError(t, err)
+	_, err = lifecycle.Finish(ctx, tx, task.ID)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	return task.ID
+}
+
+type deadLetterList struct {
+	DeadLetters []models.DeadLetter `json:"dead_letters"`
+	Count       int                 `json:"count"`
+	Total       int                 `json:"total"`
+}
+
+func listDeadLetters(t *testing.T, router *gin.Engine, query string) deadLetterList {
+	w := sendJSON(router, "GET", "/api/v1/dead-letters"+query, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var list deadLetterList
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	return list
+}
+
+func TestDeadLetters_ListAndInspect(t *testing.T) {
+	router, db := setupDeadLetterRouter(t)
+
+	email := deadLetterTask(t, db, models.TaskTypeEmailSend, models.ErrorReasonHandler)
+	deadLetterTask(t, db, models.TaskTypeEmailSend, models.ErrorReasonTimedOut)
+	deadLetterTask(t, db, models.TaskTypeHTTPRequest, models.ErrorReasonHandler)
+
+	// a failed task with retries left is not a dead letter
+	retryable := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{}`), 5)
+	retryable.State = models.TaskStateFailed
+	testutil.CreateTestTask(t, db, retryable)
+
+	list := listDeadLetters(t, router, "")
+	assert.Equal(t, 3, list.Total)
+
+	list = listDeadLetters(t, router, "?type=email_send&error_reason=handler_error")
+	require.Equal(t, 1, list.Total)
+	assert.Equal(t, email, list.DeadLetters[0].TaskID)
+	assert.Equal(t, "boom", list.DeadLetters[0].Error)
+
+	list = listDeadLetters(t, router, "?type=email_send&limit=1")
+	assert.Equal(t, 2, list.Total)
+	assert.Equal(t, 1, list.Count)
+
+	w := sendJSON(router, "GET", "/api/v1/dead-letters?after=yesterday", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendJSON(router, "GET", "/api/v1/dead-letters/"+email, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var deadLetter models.DeadLetter
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deadLetter))
+	require.NotNil(t, deadLetter.Task)
+	assert.Equal(t, models.TaskStateFailed, deadLetter.Task.State)
+
+	w = sendJSON(router, "GET", "/api/v1/dead-letters/"+retryable.ID, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeadLetters_Replay(t *testing.T) {
+	router, db := setupDeadLetterRouter(t)
+
+	single := deadLetterTask(t, db, models.TaskTypeHTTPRequest, models.ErrorReasonHandler)
+	bulk := []string{
+		deadLetterTask(t, db, models.TaskTypeEmailSend, models.ErrorReasonHandler),
+		deadLetterTask(t, db, models.TaskTypeEmailSend, models.ErrorReasonHandler),
+	}
+
+	w := sendJSON(router, "POST", "/api/v1/dead-letters/"+single+"/replay", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var task models.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+	assert.Equal(t, models.TaskStatePending, task.State)
+	assert.Equal(t, 0, task.RetryCount)
+
+	// a replayed task has left the dead letters
+	w = sendJSON(router, "POST", "/api/v1/dead-letters/"+single+"/replay", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = sendJSON(router, "POST", "/api/v1/dead-letters/replay", map[string]any{
+		"type":   models.TaskTypeEmailSend,
+		"reason": "smtp outage over",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Replayed int      `json:"replayed"`
+		TaskIDs  []string `json:"task_ids"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Replayed)
+	assert.ElementsMatch(t, bulk, resp.TaskIDs)
+	assert.Equal(t, 0, listDeadLetters(t, router, "").Total)
+}
+
+func TestDeadLetters_Purge(t *testing.T) {
+	router, db := setupDeadLetterRouter(t)
+
+	single := deadLetterTask(t, db, models.TaskTypeHTTPRequest, models.ErrorReasonHandler)
+	deadLetterTask(t, db, models.TaskTypeEmailSend, models.ErrorReasonHandler)
+	kept := deadLetterTask(t, db, models.TaskTypeEmailSend, models.ErrorReasonTimedOut)
+
+	w := sendJSON(router, "DELETE", "/api/v1/dead-letters/"+single, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = sendJSON(router, "DELETE", "/api/v1/dead-letters/"+single, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// the task itself stays failed
+	assert.Equal(t, models.TaskStateFailed, testutil.GetTestTaskByID(t, db, single).State)
+
+	w = sendJSON(router, "DELETE", "/api/v1/dead-letters?error_reason=handler_error", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"purged": 1}`, w.Body.String())
+
+	list := listDeadLetters(t, router, "")
+	require.Equal(t, 1, list.Total)
+	assert.Equal(t, kept, list.DeadLetters[0].TaskID)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/api-server/internal/service"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/workflow"
+	"github.com/gin-gonic/gin"
+)
+
+// statusFor maps errors returned by the service to an HTTP status code
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrTaskNotFound), errors.Is(err, repository.ErrScheduleNotFound),
+		errors.Is(err, repository.ErrWorkflowNotFound), errors.Is(err, repository.ErrBatchNotFound),
+		errors.Is(err, repository.ErrGroupNotFound), errors.Is(err, repository.ErrDeadLetterNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidTask), errors.Is(err, service.ErrInvalidSchedule),
+		errors.Is(err, service.ErrInvalidWorkflow), errors.Is(err, service.ErrInvalidBatch),
+		errors.Is(err, service.ErrInvalidRetry), errors.Is(err, service.ErrInvalidUpdate),
+		errors.Is(err, workflow.ErrDependencyNotFound):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrInvalidTransition), errors.Is(err, models.ErrConflict),
+		errors.Is(err, models.ErrDuplicateTask):
+		return http.StatusConflict
+	case errors.Is(err, repository.ErrIdempotencyKeyReused):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func respondError(c *gin.Context, err error) {
+	c.JSON(statusFor(err), gin.H{"error": err.Error()})
+}
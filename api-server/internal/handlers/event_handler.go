@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/service"
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// keepAliveInterval is how often an idle event stream sends a comment, so
+// proxies do not close it
+const keepAliveInterval = 15 * time.Second
+
+type EventHandler struct {
+	service *service.EventService
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func NewEventHandler(service *service.EventService) *EventHandler {
+	return &EventHandler{service: service, done: make(chan struct{})}
+}
+
+// Close ends the open streams, which would otherwise keep the server from
+// shutting down.
+func (h *EventHandler) Close() {
+	h.closeOnce.Do(func() { close(h.done) })
+}
+
+// StreamEvents streams the events of every task as Server-Sent Events.
+func (h *EventHandler) StreamEvents(c *gin.Context) {
+	h.stream(c, models.TaskEventFilter{})
+}
+
+// StreamTaskEvents streams the events of a single task.
+func (h *EventHandler) StreamTaskEvents(c *gin.Context) {
+	h.stream(c, models.TaskEventFilter{TaskID: c.Param("id")})
+}
+
+// stream filters by task type (type), state and event type (event), each a
+// comma separated list. The Last-Event-ID header, or the last_event_id query
+// parameter, resumes a stream after the last event the client received.
+func (h *EventHandler) stream(c *gin.Context, filter models.TaskEventFilter) {
+	for _, value := range queryList(c, "type") {
+		filter.TaskTypes = append(filter.TaskTypes, models.TaskType(value))
+	}
+	for _, value := range queryList(c, "state") {
+		filter.States = append(filter.States, models.TaskState(value))
+	}
+	for _, value := range queryList(c, "event") {
+		filter.Types = append(filter.Types, models.TaskEventType(value))
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	after := int64(-1)
+	if lastEventID != "" {
+		var err error
+		if after, err = strconv.ParseInt(lastEventID, 10, 64); err != nil || after < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid last event id"})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-h.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	stream, err := h.service.OpenStream(ctx, filter, after)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	defer stream.Close()
+
+	// the stream outlives the server's write timeout
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	for {
+		event, err := stream.Next(ctx, keepAliveInterval)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Info("Event stream ended", zap.Error(err))
+			}
+			return
+		}
+
+		if event == nil {
+			_, err = fmt.Fprint(c.Writer, ": keep-alive\n\n")
+		} else {
+			err = writeEvent(c, event)
+		}
+		if err != nil {
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+func writeEvent(c *gin.Context, event *models.TaskEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// queryList collects the values of a query parameter given several times or
+// as a comma separated list
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+	for _, raw := range c.QueryArray(key) {
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,175 @@
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/handlers"
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/api-server/internal/service"
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/notify"
+	"github.com/alaajili/task-scheduler/shared/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupEventServer(t *testing.T) (*httptest.Server, *database.DB) {
+	gin.SetMode(gin.TestMode)
+
+	db := testutil.TestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	listener := notify.NewListener(testutil.TestDatabaseConfig)
+	require.NoError(t, listener.Start(ctx))
+
+	taskRepo := repository.NewTaskRepository(db)
+	taskHandler := handlers.NewTaskHandler(service.NewTaskService(taskRepo, nil, nil))
+	eventHandler := handlers.NewEventHandler(service.NewEventService(repository.NewEventRepository(db), taskRepo, listener))
+
+	router := gin.New()
+	router.DELETE("/api/v1/tasks/:id", taskHandler.CancelTask)
+	router.GET("/api/v1/tasks/:id/events", eventHandler.StreamTaskEvents)
+	router.GET("/api/v1/events", eventHandler.StreamEvents)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	t.Cleanup(eventHandler.Close)
+	return server, db
+}
+
+// sseEvent is an event as read from the stream
+type sseEvent struct {
+	id    int64
+	name  string
+	event models.TaskEvent
+}
+
+// openStream connects to an event stream and returns the events it receives
+func openStream(t *testing.T, url, lastEventID string) <-chan sseEvent {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+
+		var current sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if current.name != "" {
+					events <- current
+				}
+				current = sseEvent{}
+			case strings.HasPrefix(line, "id: "):
+				current.id, _ = strconv.ParseInt(strings.TrimPrefix(line, "id: "), 10, 64)
+			case strings.HasPrefix(line, "event: "):
+				current.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &current.event)
+			}
+		}
+	}()
+	return events
+}
+
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "stream closed")
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return sseEvent{}
+	}
+}
+
+func TestStreamTaskEvents(t *testing.T) {
+	server, db := setupEventServer(t)
+
+	task := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{"to": "user@example.com"}`), 5)
+	testutil.CreateTestTask(t, db, task)
+
+	// the creation is replayed from the log, the cancellation is live
+	events := openStream(t, server.URL+"/api/v1/tasks/"+task.ID+"/events", "0")
+	created := nextEvent(t, events)
+	assert.Equal(t, string(models.TaskEventStateChanged), created.name)
+	assert.Equal(t, task.ID, created.event.TaskID)
+	assert.Equal(t, models.TaskStatePending, created.event.State)
+
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+"/api/v1/tasks/"+task.ID, nil)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	cancelled := nextEvent(t, events)
+	assert.Greater(t, cancelled.id, created.id)
+	assert.Equal(t, models.TaskStateCancelled, cancelled.event.State)
+	assert.Equal(t, models.TaskStatePending, cancelled.event.PreviousState)
+	assert.Equal(t, models.TaskTypeEmailSend, cancelled.event.TaskType)
+
+	// events logged while the client was away are replayed on resume
+	_, err = db.ExecContext(context.Background(), `UPDATE tasks SET worker_id = 'worker-1' WHERE id = $1`, task.ID)
+	require.NoError(t, err)
+	resumed := openStream(t, server.URL+"/api/v1/tasks/"+task.ID+"/events", strconv.FormatInt(cancelled.id, 10))
+	assigned := nextEvent(t, resumed)
+	assert.Equal(t, string(models.TaskEventWorkerAssigned), assigned.name)
+	assert.Equal(t, "worker-1", assigned.event.WorkerID)
+}
+
+func TestStreamEvents_Filter(t *testing.T) {
+	server, db := setupEventServer(t)
+
+	events := openStream(t, server.URL+"/api/v1/events?event=state_changed&state=cancelled&type=email_send", "")
+
+	other := models.NewTask(models.TaskTypeHTTPRequest, json.RawMessage(`{"url": "https://example.com"}`), 5)
+	testutil.CreateTestTask(t, db, other)
+	task := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{"to": "user@example.com"}`), 5)
+	testutil.CreateTestTask(t, db, task)
+	for _, id := range []string{other.ID, task.ID} {
+		_, err := db.ExecContext(context.Background(), `UPDATE tasks SET state = 'cancelled' WHERE id = $1`, id)
+		require.NoError(t, err)
+	}
+
+	event := nextEvent(t, events)
+	assert.Equal(t, task.ID, event.event.TaskID)
+	assert.Equal(t, models.TaskStateCancelled, event.event.State)
+}
+
+func TestStreamEvents_Invalid(t *testing.T) {
+	server, _ := setupEventServer(t)
+
+	resp, err := http.Get(server.URL + "/api/v1/tasks/nonexistent-id/events")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Get(server.URL + "/api/v1/events?last_event_id=latest")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
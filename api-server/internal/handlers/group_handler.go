@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type GroupHandler struct {
+	service *service.GroupService
+}
+
+func NewGroupHandler(service *service.GroupService) *GroupHandler {
+	return &GroupHandler{service: service}
+}
+
+func (h *GroupHandler) GetGroup(c *gin.Context) {
+	status, err := h.service.GetGroup(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
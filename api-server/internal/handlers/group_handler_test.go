@@ -0,0 +1,130 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/handlers"
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/api-server/internal/service"
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupGroupRouter(t *testing.T) (*gin.Engine, *database.DB) {
+	gin.SetMode(gin.TestMode)
+
+	db := testutil.TestDB(t)
+	taskRepo := repository.NewTaskRepository(db)
+	taskService := service.NewTaskService(taskRepo, nil, nil)
+	batchService := service.NewBatchService(repository.NewBatchRepository(db), taskRepo, taskService)
+	groupService := service.NewGroupService(repository.NewGroupRepository(db))
+	taskHandler := handlers.NewTaskHandler(taskService)
+	batchHandler := handlers.NewBatchHandler(batchService)
+	groupHandler := handlers.NewGroupHandler(groupService)
+
+	router := gin.New()
+	router.GET("/api/v1/tasks/:id", taskHandler.GetTask)
+	router.DELETE("/api/v1/tasks/:id", taskHandler.CancelTask)
+	router.POST("/api/v1/tasks/batch", batchHandler.CreateBatch)
+	router.DELETE("/api/v1/batches/:id", batchHandler.CancelBatch)
+	router.GET("/api/v1/groups/:id", groupHandler.GetGroup)
+	return router, db
+}
+
+func getGroup(t *testing.T, router *gin.Engine, groupID string) models.GroupStatus {
+	w := sendJSON(router, "GET", "/api/v1/groups/"+groupID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var status models.GroupStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	return status
+}
+
+func TestGroup_OnComplete(t *testing.T) {
+	router, db := setupGroupRouter(t)
+
+	req := map[string]any{
+		"tasks": []any{batchTask("a@example.com"), batchTask("b@example.com"), batchTask("c@example.com")},
+		"group": map[string]any{
+			"name": "newsletter",
+			"on_complete": map[string]any{
+				"type":    models.TaskTypeEmailSend,
+				"payload": map[string]any{"to": "admin@example.com"},
+			},
+		},
+	}
+	w := sendJSON(router, "POST", "/api/v1/tasks/batch", req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var result service.BatchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	require.NotEmpty(t, result.GroupID)
+
+	status := getGroup(t, router, result.GroupID)
+	assert.Equal(t, "newsletter", status.Name)
+	assert.Equal(t, 3, status.Total)
+	assert.Equal(t, 3, status.Counts[models.TaskStatePending])
+	assert.Equal(t, 0.0, status.Progress)
+	assert.Empty(t, status.Errors)
+
+	// the first member fails for good, the second one is cancelled
+	_, err := db.ExecContext(context.Background(), `
+		UPDATE tasks SET state = 'failed', error = 'smtp unavailable', retry_count = max_retries
+		WHERE id = $1
+	`, result.Results[0].TaskID)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, sendJSON(router, "DELETE", "/api/v1/tasks/"+result.Results[1].TaskID, nil).Code)
+
+	status = getGroup(t, router, result.GroupID)
+	assert.Equal(t, 2, status.Finished)
+	assert.Equal(t, 66.7, status.Progress)
+	assert.Nil(t, status.CompletedAt)
+	assert.Empty(t, status.OnCompleteTaskID)
+	require.Len(t, status.Errors, 1)
+	assert.Equal(t, "smtp unavailable", status.Errors[0].Error)
+	assert.Equal(t, []string{result.Results[0].TaskID}, status.Errors[0].TaskIDs)
+
+	// cancelling the last member completes the group
+	w = sendJSON(router, "DELETE", "/api/v1/batches/"+result.BatchID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"cancelled":1`)
+
+	status = getGroup(t, router, result.GroupID)
+	assert.Equal(t, 100.0, status.Progress)
+	assert.NotNil(t, status.CompletedAt)
+	require.NotEmpty(t, status.OnCompleteTaskID)
+
+	w = sendJSON(router, "GET", "/api/v1/tasks/"+status.OnCompleteTaskID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var task models.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+	assert.Equal(t, models.TaskStatePending, task.State)
+	assert.Empty(t, task.GroupID)
+
+	// finishing a member again does not create a second task
+	require.Equal(t, http.StatusOK, sendJSON(router, "DELETE", "/api/v1/batches/"+result.BatchID, nil).Code)
+	var created int
+	err = db.QueryRowContext(context.Background(),
+		`SELECT COUNT(*) FROM tasks WHERE payload->>'to' = 'admin@example.com'`).Scan(&created)
+	require.NoError(t, err)
+	assert.Equal(t, 1, created)
+}
+
+func TestGroup_Invalid(t *testing.T) {
+	router, _ := setupGroupRouter(t)
+
+	req := map[string]any{
+		"tasks": []any{batchTask("a@example.com")},
+		"group": map[string]any{"on_complete": map[string]any{"type": models.TaskTypeEmailSend}},
+	}
+	w := sendJSON(router, "POST", "/api/v1/tasks/batch", req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "payload is required")
+
+	assert.Equal(t, http.StatusNotFound, sendJSON(router, "GET", "/api/v1/groups/nonexistent-id", nil).Code)
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type HistoryHandler struct {
+	service *service.HistoryService
+}
+
+func NewHistoryHandler(service *service.HistoryService) *HistoryHandler {
+	return &HistoryHandler{service: service}
+}
+
+func (h *HistoryHandler) GetHistory(c *gin.Context) {
+	history, err := h.service.GetHistory(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
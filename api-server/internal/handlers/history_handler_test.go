@@ -0,0 +1,92 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/handlers"
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/api-server/internal/service"
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupHistoryRouter(t *testing.T) (*gin.Engine, *database.DB) {
+	gin.SetMode(gin.TestMode)
+
+	db := testutil.TestDB(t)
+	historyHandler := handlers.NewHistoryHandler(service.NewHistoryService(repository.NewHistoryRepository(db)))
+
+	router := gin.New()
+	router.GET("/api/v1/tasks/:id/history", historyHandler.GetHistory)
+	return router, db
+}
+
+func TestGetHistory(t *testing.T) {
+	router, db := setupHistoryRouter(t)
+	ctx := context.Background()
+
+	task := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{"to": "user@example.com"}`), 5)
+	testutil.CreateTestTask(t, db, task)
+
+	// a first attempt failed and was retried, the second one is running
+	steps := []string{
+		`UPDATE tasks SET state = 'running', worker_id = 'worker-1' WHERE id = $1`,
+		`INSERT INTO task_attempts (task_id, attempt, worker_id, state, finished_at, duration_ms, error, error_reason, retry_at)
+		 VALUES ($1, 1, 'worker-1', 'failed', NOW(), 1500, 'connection refused', 'handler_error', NOW())`,
+		`UPDATE tasks SET state = 'failed', retry_count = 1 WHERE id = $1`,
+		`UPDATE tasks SET state = 'pending', worker_id = NULL WHERE id = $1`,
+		`UPDATE tasks SET state = 'running', worker_id = 'worker-2' WHERE id = $1`,
+		`INSERT INTO task_attempts (task_id, attempt, worker_id) VALUES ($1, 2, 'worker-2')`,
+	}
+	for _, step := range steps {
+		_, err := db.ExecContext(ctx, step, task.ID)
+		require.NoError(t, err)
+	}
+
+	w := sendJSON(router, "GET", "/api/v1/tasks/"+task.ID+"/history", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var history models.TaskHistory
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	assert.Equal(t, task.ID, history.TaskID)
+	assert.Equal(t, models.TaskStateRunning, history.State)
+
+	require.Len(t, history.Attempts, 2)
+	first := history.Attempts[0]
+	assert.Equal(t, 1, first.Attempt)
+	assert.Equal(t, models.AttemptStateFailed, first.State)
+	assert.Equal(t, "worker-1", first.WorkerID)
+	assert.Equal(t, "connection refused", first.Error)
+	assert.Equal(t, models.ErrorReasonHandler, first.ErrorReason)
+	require.NotNil(t, first.DurationMs)
+	assert.Equal(t, int64(1500), *first.DurationMs)
+	assert.NotNil(t, first.RetryAt)
+	second := history.Attempts[1]
+	assert.Equal(t, models.AttemptStateRunning, second.State)
+	assert.Nil(t, second.FinishedAt)
+
+	var states []models.TaskState
+	for _, event := range history.Events {
+		if event.Type == models.TaskEventStateChanged {
+			states = append(states, event.State)
+		}
+	}
+	assert.Equal(t, []models.TaskState{
+		models.TaskStatePending, models.TaskStateRunning, models.TaskStateFailed,
+		models.TaskStatePending, models.TaskStateRunning,
+	}, states)
+}
+
+func TestGetHistory_NotFound(t *testing.T) {
+	router, _ := setupHistoryRouter(t)
+
+	w := sendJSON(router, "GET", "/api/v1/tasks/nonexistent-id/history", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
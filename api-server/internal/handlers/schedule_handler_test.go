@@ -0,0 +1,136 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/handlers"
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/api-server/internal/service"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupScheduleRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	db := testutil.TestDB(t)
+	service := service.NewScheduleService(repository.NewScheduleRepository(db))
+	handler := handlers.NewScheduleHandler(service)
+
+	router := gin.New()
+	schedules := router.Group("/api/v1/schedules")
+	{
+		schedules.POST("", handler.CreateSchedule)
+		schedules.GET("/:id", handler.GetSchedule)
+		schedules.GET("", handler.ListSchedules)
+		schedules.PUT("/:id", handler.UpdateSchedule)
+		schedules.DELETE("/:id", handler.DeleteSchedule)
+	}
+	return router
+}
+
+func sendJSON(router *gin.Engine, method, path string, body any) *httptest.ResponseRecorder {
+	var reader *bytes.Buffer
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewBuffer(data)
+	} else {
+		reader = bytes.NewBuffer(nil)
+	}
+
+	req, _ := http.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func nightlyReport() map[string]any {
+	return map[string]any{
+		"name":     "nightly-report",
+		"cron":     "0 2 * * *",
+		"timezone": "UTC",
+		"task": map[string]any{
+			"type":     models.TaskTypeDataProcessing,
+			"payload":  map[string]any{"report": "daily"},
+			"priority": 3,
+		},
+		"overlap_policy": models.OverlapSkip,
+	}
+}
+
+func TestScheduleCRUD(t *testing.T) {
+	router := setupScheduleRouter(t)
+
+	w := sendJSON(router, "POST", "/api/v1/schedules", nightlyReport())
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var created models.Schedule
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.ID)
+	assert.True(t, created.Enabled)
+	assert.Equal(t, models.OverlapSkip, created.OverlapPolicy)
+	assert.Equal(t, models.MisfireFireOnce, created.MisfirePolicy)
+	require.NotNil(t, created.NextRunAt)
+	assert.Equal(t, 2, created.NextRunAt.Hour())
+
+	w = sendJSON(router, "GET", "/api/v1/schedules/"+created.ID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var fetched models.Schedule
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &fetched))
+	assert.Equal(t, "0 2 * * *", fetched.CronExpr)
+	assert.Equal(t, models.TaskTypeDataProcessing, fetched.Task.Type)
+	assert.JSONEq(t, `{"report": "daily"}`, string(fetched.Task.Payload))
+
+	// disabling a schedule clears its next run
+	update := nightlyReport()
+	update["enabled"] = false
+	w = sendJSON(router, "PUT", "/api/v1/schedules/"+created.ID, update)
+	require.Equal(t, http.StatusOK, w.Code)
+	var updated models.Schedule
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.False(t, updated.Enabled)
+	assert.Nil(t, updated.NextRunAt)
+
+	w = sendJSON(router, "GET", "/api/v1/schedules", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, float64(1), list["count"])
+
+	w = sendJSON(router, "DELETE", "/api/v1/schedules/"+created.ID, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = sendJSON(router, "GET", "/api/v1/schedules/"+created.ID, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreateSchedule_Invalid(t *testing.T) {
+	router := setupScheduleRouter(t)
+
+	badCron := nightlyReport()
+	badCron["cron"] = "every night"
+	assert.Equal(t, http.StatusBadRequest, sendJSON(router, "POST", "/api/v1/schedules", badCron).Code)
+
+	badTimezone := nightlyReport()
+	badTimezone["timezone"] = "Nowhere/Special"
+	assert.Equal(t, http.StatusBadRequest, sendJSON(router, "POST", "/api/v1/schedules", badTimezone).Code)
+
+	badTask := nightlyReport()
+	badTask["task"] = map[string]any{"type": "unknown", "payload": map[string]any{}}
+	assert.Equal(t, http.StatusBadRequest, sendJSON(router, "POST", "/api/v1/schedules", badTask).Code)
+}
+
+func TestUpdateSchedule_NotFound(t *testing.T) {
+	router := setupScheduleRouter(t)
+
+	w := sendJSON(router, "PUT", "/api/v1/schedules/nonexistent-id", nightlyReport())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/alaajili/task-scheduler/api-server/internal/repository"
 	"github.com/alaajili/task-scheduler/api-server/internal/service"
@@ -25,9 +29,19 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	task, err := h.service.CreateTask(c.Request.Context(), req)
+	if key := c.GetHeader("Idempotency-Key"); key != "" {
+		req.IdempotencyKey = key
+	}
+
+	task, created, err := h.service.CreateTask(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
+		return
+	}
+	if !created {
+		// an idempotent replay or a task whose unique key is held
+		// gets the existing task
+		c.JSON(http.StatusOK, task)
 		return
 	}
 	c.JSON(http.StatusCreated, task)
@@ -37,7 +51,41 @@ func (h *TaskHandler) GetTask(c *gin.Context) {
 	taskID := c.Param("id")
 	task, err := h.service.GetTask(c.Request.Context(), taskID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
+
+// defaultWaitTimeout applies when WaitForTask is not given a timeout
+const defaultWaitTimeout = 30 * time.Second
+
+// WaitForTask long-polls a task: 200 once it has finished, 202 with its
+// current state when the timeout passes first.
+func (h *TaskHandler) WaitForTask(c *gin.Context) {
+	timeout := defaultWaitTimeout
+	if raw := c.Query("timeout"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 || parsed > service.MaxWaitTimeout {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("timeout must be a duration between 0 and %s", service.MaxWaitTimeout),
+			})
+			return
+		}
+		timeout = parsed
+	}
+
+	// the wait may outlast the server's write timeout, recorders used in
+	// tests do not support deadlines
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(timeout + 10*time.Second))
+
+	task, finished, err := h.service.WaitForTask(c.Request.Context(), c.Param("id"), timeout)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if !finished {
+		c.JSON(http.StatusAccepted, task)
 		return
 	}
 	c.JSON(http.StatusOK, task)
@@ -45,8 +93,11 @@ func (h *TaskHandler) GetTask(c *gin.Context) {
 
 func (h *TaskHandler) ListTasks(c *gin.Context) {
 	filters := repository.ListFilters{
-		Type:  models.TaskType(c.Query("type")),
-		State: models.TaskState(c.Query("state")),
+		Type:       models.TaskType(c.Query("type")),
+		State:      models.TaskState(c.Query("state")),
+		WorkflowID: c.Query("workflow_id"),
+		BatchID:    c.Query("batch_id"),
+		GroupID:    c.Query("group_id"),
 	}
 	if limit := c.Query("limit"); limit != "" {
 		if l, err := strconv.Atoi(limit); err == nil {
@@ -75,9 +126,58 @@ func (h *TaskHandler) CancelTask(c *gin.Context) {
 	taskID := c.Param("id")
 
 	if err := h.service.CancelTask(c.Request.Context(), taskID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 	
 	c.JSON(http.StatusOK, gin.H{"message": "task cancelled successfully"})
 }
+
+// RetryTask retries a failed or terminal task, the body is optional
+func (h *TaskHandler) RetryTask(c *gin.Context) {
+	var req service.RetryTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.service.RetryTask(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
+
+func (h *TaskHandler) UpdateTask(c *gin.Context) {
+	var req service.UpdateTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.service.UpdateTask(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
+
+func (h *TaskHandler) RetryTasks(c *gin.Context) {
+	var req service.BulkRetryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ids, err := h.service.RetryTasks(c.Request.Context(), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"retried":  len(ids),
+		"task_ids": ids,
+	})
+}
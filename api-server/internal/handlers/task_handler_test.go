@@ -2,18 +2,23 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/alaajili/task-scheduler/api-server/internal/handlers"
 	"github.com/alaajili/task-scheduler/api-server/internal/repository"
 	"github.com/alaajili/task-scheduler/api-server/internal/service"
 	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/notify"
 	"github.com/alaajili/task-scheduler/shared/testutil"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func setupTestRouter(t *testing.T) (*gin.Engine, *repository.TaskRepository) {
@@ -21,7 +26,7 @@ func setupTestRouter(t *testing.T) (*gin.Engine, *repository.TaskRepository) {
 
 	db := testutil.TestDB(t)
 	repository := repository.NewTaskRepository(db)
-	service := service.NewTaskService(repository)
+	service := service.NewTaskService(repository, nil, nil)
 	handler := handlers.NewTaskHandler(service)
 
 	router := gin.New()
@@ -33,6 +38,9 @@ func setupTestRouter(t *testing.T) (*gin.Engine, *repository.TaskRepository) {
 			tasks.GET("/:id", handler.GetTask)
 			tasks.GET("", handler.ListTasks)
 			tasks.DELETE("/:id", handler.CancelTask)
+			tasks.POST("/retry", handler.RetryTasks)
+			tasks.POST("/:id/retry", handler.RetryTask)
+			tasks.PATCH("/:id", handler.UpdateTask)
 		}
 	}
 	return router, repository
@@ -91,7 +99,233 @@ func TestCreateTask_InvalidType(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateTask_WithTimeout(t *testing.T) {
+	router, repository := setupTestRouter(t)
+
+	reqBody := map[string]any{
+		"type":            models.TaskTypeLongRunning,
+		"payload":         map[string]any{"duration": 1},
+		"timeout_seconds": 90,
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req, _ := http.NewRequest("POST", "/api/v1/tasks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created models.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, 90, created.TimeoutSeconds)
+
+	stored, err := repository.GetTaskByID(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 90, stored.TimeoutSeconds)
+	assert.Equal(t, 90*time.Second, stored.Timeout())
+}
+
+func TestCreateTask_Delayed(t *testing.T) {
+	router, repository := setupTestRouter(t)
+
+	reqBody := map[string]any{
+		"type":    models.TaskTypeEmailSend,
+		"payload": map[string]any{"to": "a@example.com", "subject": "later"},
+		"delay":   "1h",
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req, _ := http.NewRequest("POST", "/api/v1/tasks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created models.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, models.TaskStateScheduled, created.State)
+
+	stored, err := repository.GetTaskByID(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.TaskStateScheduled, stored.State)
+	require.NotNil(t, stored.NotBefore)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *stored.NotBefore, time.Minute)
+}
+
+func TestCreateTask_RunAtAndDelay(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	reqBody := map[string]any{
+		"type":    models.TaskTypeEmailSend,
+		"payload": map[string]any{"to": "a@example.com"},
+		"run_at":  time.Now().Add(time.Hour).Format(time.RFC3339),
+		"delay":   "1h",
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req, _ := http.NewRequest("POST", "/api/v1/tasks", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateTask_IdempotencyKey(t *testing.T) {
+	router, repo := setupTestRouter(t)
+
+	reqBody := map[string]any{
+		"type":            models.TaskTypeEmailSend,
+		"payload":         map[string]any{"to": "a@example.com"},
+		"idempotency_key": "order-42",
+	}
+
+	w := sendJSON(router, "POST", "/api/v1/tasks", reqBody)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created models.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	// the same request is a replay and returns the original task
+	w = sendJSON(router, "POST", "/api/v1/tasks", reqBody)
+	require.Equal(t, http.StatusOK, w.Code)
+	var replayed models.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &replayed))
+	assert.Equal(t, created.ID, replayed.ID)
+
+	tasks, err := repo.ListTasks(context.Background(), repository.ListFilters{})
+	require.NoError(t, err)
+	assert.Len(t, tasks, 1)
+
+	// the same key with another request is rejected
+	reqBody["payload"] = map[string]any{"to": "b@example.com"}
+	w = sendJSON(router, "POST", "/api/v1/tasks", reqBody)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestCreateTask_IdempotencyKeyHeader(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	body, _ := json.Marshal(map[string]any{
+		"type":            models.TaskTypeEmailSend,
+		"payload":         map[string]any{"to": "a@example.com"},
+		"idempotency_key": "ignored",
+	})
+	send := func(key string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/tasks", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("header-key")
+	require.Equal(t, http.StatusCreated, first.Code)
+	second := send("header-key")
+	require.Equal(t, http.StatusOK, second.Code)
+	assert.JSONEq(t, first.Body.String(), second.Body.String())
+
+	// the header takes precedence over the field
+	assert.Equal(t, http.StatusCreated, send("other-key").Code)
+}
+
+func TestCreateTask_UniqueKey(t *testing.T) {
+	router, repo := setupTestRouter(t)
+
+	reqBody := map[string]any{
+		"type":       models.TaskTypeDataProcessing,
+		"payload":    map[string]any{"customer": 42},
+		"unique_key": "customer-42",
+	}
+
+	w := sendJSON(router, "POST", "/api/v1/tasks", reqBody)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created models.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, models.UniqueScopePendingOrRunning, created.UniqueScope)
+
+	// rejected by default
+	w = sendJSON(router, "POST", "/api/v1/tasks", reqBody)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), created.ID)
+
+	reqBody["on_conflict"] = models.ConflictReturnExisting
+	w = sendJSON(router, "POST", "/api/v1/tasks", reqBody)
+	require.Equal(t, http.StatusOK, w.Code)
+	var existing models.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &existing))
+	assert.Equal(t, created.ID, existing.ID)
+
+	reqBody["on_conflict"] = models.ConflictReplace
+	reqBody["payload"] = map[string]any{"customer": 42, "full": true}
+	w = sendJSON(router, "POST", "/api/v1/tasks", reqBody)
+	require.Equal(t, http.StatusOK, w.Code)
+	stored, err := repo.GetTaskByID(context.Background(), created.ID)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"customer": 42, "full": true}`, string(stored.Payload))
+
+	// another type or key is not a duplicate
+	reqBody["type"] = models.TaskTypeEmailSend
+	assert.Equal(t, http.StatusCreated, sendJSON(router, "POST", "/api/v1/tasks", reqBody).Code)
+
+	// the key is free again once the task is done
+	_, err = repo.DB().ExecContext(context.Background(), `UPDATE tasks SET state = 'completed' WHERE id = $1`, created.ID)
+	require.NoError(t, err)
+	delete(reqBody, "on_conflict")
+	reqBody["type"] = models.TaskTypeDataProcessing
+	assert.Equal(t, http.StatusCreated, sendJSON(router, "POST", "/api/v1/tasks", reqBody).Code)
+}
+
+func TestCreateTask_UniqueKeyScopes(t *testing.T) {
+	router, repo := setupTestRouter(t)
+	ctx := context.Background()
+
+	create := func(key string, scope models.UniqueScope, window string) *httptest.ResponseRecorder {
+		reqBody := map[string]any{
+			"type":         models.TaskTypeDataProcessing,
+			"payload":      map[string]any{},
+			"unique_key":   key,
+			"unique_scope": scope,
+		}
+		if window != "" {
+			reqBody["unique_for"] = window
+		}
+		return sendJSON(router, "POST", "/api/v1/tasks", reqBody)
+	}
+	setState := func(w *httptest.ResponseRecorder, state models.TaskState) {
+		var task models.Task
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+		_, err := repo.DB().ExecContext(ctx, `UPDATE tasks SET state = $2 WHERE id = $1`, task.ID, state)
+		require.NoError(t, err)
+	}
+
+	// a running task no longer holds a key it only holds while pending
+	w := create("pending", models.UniqueScopePending, "")
+	require.Equal(t, http.StatusCreated, w.Code)
+	setState(w, models.TaskStateRunning)
+	assert.Equal(t, http.StatusCreated, create("pending", models.UniqueScopePending, "").Code)
+
+	w = create("active", models.UniqueScopePendingOrRunning, "")
+	require.Equal(t, http.StatusCreated, w.Code)
+	setState(w, models.TaskStateRunning)
+	assert.Equal(t, http.StatusConflict, create("active", models.UniqueScopePendingOrRunning, "").Code)
+
+	// a window holds the key whatever the state of the task
+	w = create("window", models.UniqueScopeWindow, "10m")
+	require.Equal(t, http.StatusCreated, w.Code)
+	setState(w, models.TaskStateCompleted)
+	assert.Equal(t, http.StatusConflict, create("window", models.UniqueScopeWindow, "10m").Code)
+
+	assert.Equal(t, http.StatusBadRequest, create("invalid", models.UniqueScopeWindow, "").Code)
+	assert.Equal(t, http.StatusBadRequest, create("invalid", "forever", "").Code)
 }
 
 func TestGetTask(t *testing.T) {
@@ -227,8 +461,306 @@ func TestCancelTask_AlreadyCompleted(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusConflict, w.Code)
 
 	completed := testutil.GetTestTaskByID(t, repository.DB(), task.ID)
 	assert.Equal(t, models.TaskStateCompleted, completed.State)
 }
+
+func TestCancelTask_NotFound(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	req, _ := http.NewRequest("DELETE", "/api/v1/tasks/nonexistent-id", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRetryTask(t *testing.T) {
+	router, repository := setupTestRouter(t)
+	ctx := context.Background()
+
+	task := models.NewTask(
+		models.TaskTypeHTTPRequest,
+		json.RawMessage(`{"url": "https://example.com"}`),
+		5,
+	)
+	task.MaxRetries = 3
+	testutil.CreateTestTask(t, repository.DB(), task)
+	_, err := repository.DB().ExecContext(ctx, `
+		UPDATE tasks
+		SET state = 'failed', retry_count = 3, error = 'connection refused', completed_at = NOW()
+		WHERE id = $1
+	`, task.ID)
+	require.NoError(t, err)
+
+	w := sendJSON(router, "POST", "/api/v1/tasks/"+task.ID+"/retry", map[string]any{
+		"reset_retries": true,
+		"priority":      9,
+		"payload":       map[string]any{"url": "https://example.org"},
+		"reason":        "upstream fixed",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var retried models.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &retried))
+	assert.Equal(t, models.TaskStatePending, retried.State)
+	assert.Equal(t, 0, retried.RetryCount)
+	assert.Equal(t, 9, retried.Priority)
+	assert.JSONEq(t, `{"url": "https://example.org"}`, string(retried.Payload))
+	assert.Empty(t, retried.Error)
+	assert.Nil(t, retried.CompletedAt)
+
+	// the manual action is in the history of the task
+	var message string
+	err = repository.DB().QueryRowContext(ctx,
+		`SELECT message FROM task_events WHERE task_id = $1 AND type = 'retried'`, task.ID,
+	).Scan(&message)
+	require.NoError(t, err)
+	assert.Equal(t, "retries reset, priority 9, payload replaced: upstream fixed", message)
+
+	// without a body the task is retried as is
+	_, err = repository.DB().ExecContext(ctx, `UPDATE tasks SET state = 'cancelled' WHERE id = $1`, task.ID)
+	require.NoError(t, err)
+	req, _ := http.NewRequest("POST", "/api/v1/tasks/"+task.ID+"/retry", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestRetryTask_Invalid(t *testing.T) {
+	router, repository := setupTestRouter(t)
+
+	task := models.NewTask(
+		models.TaskTypeHTTPRequest,
+		json.RawMessage(`{"url": "https://example.com"}`),
+		5,
+	)
+	testutil.CreateTestTask(t, repository.DB(), task)
+
+	// a pending task is not retried
+	w := sendJSON(router, "POST", "/api/v1/tasks/"+task.ID+"/retry", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = sendJSON(router, "POST", "/api/v1/tasks/"+task.ID+"/retry", map[string]any{"priority": 11})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendJSON(router, "POST", "/api/v1/tasks/"+task.ID+"/retry", map[string]any{
+		"reason": strings.Repeat("x", service.MaxRetryReasonLength+1),
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendJSON(router, "POST", "/api/v1/tasks/nonexistent-id/retry", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRetryTasks(t *testing.T) {
+	router, repository := setupTestRouter(t)
+	ctx := context.Background()
+
+	finish := func(taskType models.TaskType, state models.TaskState, finishedAt time.Time) string {
+		task := models.NewTask(taskType, json.RawMessage(`{"to": "user@example.com"}`), 5)
+		testutil.CreateTestTask(t, repository.DB(), task)
+		_, err := repository.DB().ExecContext(ctx,
+			`UPDATE tasks SET state = $2, completed_at = $3 WHERE id = $1`, task.ID, state, finishedAt)
+		require.NoError(t, err)
+		return task.ID
+	}
+
+	incident := time.Now().Add(-time.Hour)
+	inside := finish(models.TaskTypeEmailSend, models.TaskStateFailed, incident.Add(10*time.Minute))
+	finish(models.TaskTypeEmailSend, models.TaskStateFailed, incident.Add(-10*time.Minute))
+	finish(models.TaskTypeEmailSend, models.TaskStateCancelled, incident.Add(10*time.Minute))
+	finish(models.TaskTypeHTTPRequest, models.TaskStateFailed, incident.Add(10*time.Minute))
+
+	w := sendJSON(router, "POST", "/api/v1/tasks/retry", map[string]any{
+		"type":            models.TaskTypeEmailSend,
+		"finished_after":  incident,
+		"finished_before": incident.Add(30 * time.Minute),
+		"reason":          "smtp outage",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Retried int      `json:"retried"`
+		TaskIDs []string `json:"task_ids"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.Retried)
+	assert.Equal(t, []string{inside}, resp.TaskIDs)
+	assert.Equal(t, models.TaskStatePending, testutil.GetTestTaskByID(t, repository.DB(), inside).State)
+
+	// the same request again finds nothing left to retry
+	w = sendJSON(router, "POST", "/api/v1/tasks/retry", map[string]any{"type": models.TaskTypeEmailSend, "finished_after": incident})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 0, resp.Retried)
+
+	w = sendJSON(router, "POST", "/api/v1/tasks/retry", map[string]any{"state": models.TaskStateRunning})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUpdateTask(t *testing.T) {
+	router, repository := setupTestRouter(t)
+	ctx := context.Background()
+
+	task := models.NewTask(
+		models.TaskTypeHTTPRequest,
+		json.RawMessage(`{"url": "https://example.com"}`),
+		5,
+	)
+	testutil.CreateTestTask(t, repository.DB(), task)
+
+	w := sendJSON(router, "PATCH", "/api/v1/tasks/"+task.ID, map[string]any{
+		"priority":    9,
+		"max_retries": 5,
+		"payload":     map[string]any{"url": "https://example.org"},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var updated models.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, models.TaskStatePending, updated.State)
+	assert.Equal(t, 9, updated.Priority)
+	assert.Equal(t, 5, updated.MaxRetries)
+	assert.JSONEq(t, `{"url": "https://example.org"}`, string(updated.Payload))
+
+	var message string
+	err := repository.DB().QueryRowContext(ctx,
+		`SELECT message FROM task_events WHERE task_id = $1 AND type = 'updated'`, task.ID,
+	).Scan(&message)
+	require.NoError(t, err)
+	assert.Equal(t, "priority 9, max_retries 5, payload replaced", message)
+
+	// a run_at in the future holds the task back, one in the past releases it
+	runAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	w = sendJSON(router, "PATCH", "/api/v1/tasks/"+task.ID, map[string]any{"run_at": runAt})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, models.TaskStateScheduled, updated.State)
+	require.NotNil(t, updated.NotBefore)
+	assert.True(t, runAt.Equal(*updated.NotBefore))
+	assert.Equal(t, 9, updated.Priority)
+
+	w = sendJSON(router, "PATCH", "/api/v1/tasks/"+task.ID, map[string]any{"run_at": time.Now().Add(-time.Minute)})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, models.TaskStatePending, updated.State)
+
+	// the task is published again with its new due time
+	var entries int
+	err = repository.DB().QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM task_outbox WHERE task_id = $1 AND priority = 9 AND not_before IS NULL`, task.ID,
+	).Scan(&entries)
+	require.NoError(t, err)
+	assert.Equal(t, 1, entries)
+}
+
+func TestUpdateTask_Invalid(t *testing.T) {
+	router, repository := setupTestRouter(t)
+	ctx := context.Background()
+
+	task := models.NewTask(
+		models.TaskTypeHTTPRequest,
+		json.RawMessage(`{"url": "https://example.com"}`),
+		5,
+	)
+	testutil.CreateTestTask(t, repository.DB(), task)
+
+	w := sendJSON(router, "PATCH", "/api/v1/tasks/"+task.ID, map[string]any{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendJSON(router, "PATCH", "/api/v1/tasks/"+task.ID, map[string]any{"priority": 11})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sendJSON(router, "PATCH", "/api/v1/tasks/"+task.ID, map[string]any{"max_retries": -1})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = sendJSON(router, "PATCH", "/api/v1/tasks/nonexistent-id", map[string]any{"priority": 9})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// the payload only changes while the task is pending
+	_, err := repository.DB().ExecContext(ctx,
+		`UPDATE tasks SET state = 'scheduled', not_before = NOW() + INTERVAL '1 hour' WHERE id = $1`, task.ID)
+	require.NoError(t, err)
+	w = sendJSON(router, "PATCH", "/api/v1/tasks/"+task.ID, map[string]any{
+		"payload": map[string]any{"url": "https://example.org"},
+	})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.JSONEq(t, `{"url": "https://example.com"}`, string(testutil.GetTestTaskByID(t, repository.DB(), task.ID).Payload))
+
+	// running and finished tasks are left alone
+	for _, state := range []models.TaskState{models.TaskStateRunning, models.TaskStateCompleted, models.TaskStateFailed} {
+		_, err := repository.DB().ExecContext(ctx, `UPDATE tasks SET state = $2 WHERE id = $1`, task.ID, state)
+		require.NoError(t, err)
+
+		w = sendJSON(router, "PATCH", "/api/v1/tasks/"+task.ID, map[string]any{"priority": 9})
+		assert.Equal(t, http.StatusConflict, w.Code, state)
+	}
+	assert.Equal(t, 5, testutil.GetTestTaskByID(t, repository.DB(), task.ID).Priority)
+}
+
+func setupWaitRouter(t *testing.T) (*gin.Engine, *repository.TaskRepository, *notify.Listener) {
+	router, repo := setupTestRouter(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	listener := notify.NewListener(testutil.TestDatabaseConfig)
+	require.NoError(t, listener.Start(ctx))
+
+	service := service.NewTaskService(repo, nil, nil)
+	service.SetListener(listener)
+	router.GET("/api/v1/tasks/:id/wait", handlers.NewTaskHandler(service).WaitForTask)
+	return router, repo, listener
+}
+
+func TestWaitForTask(t *testing.T) {
+	router, repo, listener := setupWaitRouter(t)
+
+	task := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{"to": "user@example.com"}`), 5)
+	testutil.CreateTestTask(t, repo.DB(), task)
+
+	// every waiter wakes up when the task is cancelled
+	const waiters = 200
+	codes := make(chan int, waiters)
+	for i := 0; i < waiters; i++ {
+		go func() {
+			w := sendJSON(router, "GET", "/api/v1/tasks/"+task.ID+"/wait?timeout=30s", nil)
+			var waited models.Task
+			if json.Unmarshal(w.Body.Bytes(), &waited) != nil || waited.State != models.TaskStateCancelled {
+				codes <- 0
+				return
+			}
+			codes <- w.Code
+		}()
+	}
+	require.Eventually(t, func() bool { return listener.Waiters() == waiters }, 5*time.Second, 10*time.Millisecond)
+
+	start := time.Now()
+	require.Equal(t, http.StatusOK, sendJSON(router, "DELETE", "/api/v1/tasks/"+task.ID, nil).Code)
+	for i := 0; i < waiters; i++ {
+		assert.Equal(t, http.StatusOK, <-codes)
+	}
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, 0, listener.Waiters())
+
+	// a finished task is returned right away
+	w := sendJSON(router, "GET", "/api/v1/tasks/"+task.ID+"/wait", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestWaitForTask_Timeout(t *testing.T) {
+	router, repo, _ := setupWaitRouter(t)
+
+	task := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{"to": "user@example.com"}`), 5)
+	testutil.CreateTestTask(t, repo.DB(), task)
+
+	w := sendJSON(router, "GET", "/api/v1/tasks/"+task.ID+"/wait?timeout=100ms", nil)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	var waited models.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &waited))
+	assert.Equal(t, models.TaskStatePending, waited.State)
+
+	assert.Equal(t, http.StatusBadRequest, sendJSON(router, "GET", "/api/v1/tasks/"+task.ID+"/wait?timeout=soon", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, sendJSON(router, "GET", "/api/v1/tasks/"+task.ID+"/wait?timeout=1h", nil).Code)
+	assert.Equal(t, http.StatusNotFound, sendJSON(router, "GET", "/api/v1/tasks/nonexistent-id/wait", nil).Code)
+}
@@ -0,0 +1,200 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/handlers"
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/api-server/internal/service"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupWorkflowRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	db := testutil.TestDB(t)
+	taskRepo := repository.NewTaskRepository(db)
+	taskService := service.NewTaskService(taskRepo, nil, nil)
+	workflowService := service.NewWorkflowService(repository.NewWorkflowRepository(db), taskRepo, taskService)
+	taskHandler := handlers.NewTaskHandler(taskService)
+	workflowHandler := handlers.NewWorkflowHandler(workflowService)
+
+	router := gin.New()
+	router.POST("/api/v1/tasks", taskHandler.CreateTask)
+	router.GET("/api/v1/tasks/:id", taskHandler.GetTask)
+	router.DELETE("/api/v1/tasks/:id", taskHandler.CancelTask)
+	router.POST("/api/v1/workflows", workflowHandler.CreateWorkflow)
+	router.GET("/api/v1/workflows/:id", workflowHandler.GetWorkflow)
+	return router
+}
+
+func workflowTask(key string, dependsOn ...string) map[string]any {
+	return map[string]any{
+		"key":        key,
+		"type":       models.TaskTypeEmailSend,
+		"payload":    map[string]any{"to": key + "@example.com"},
+		"depends_on": dependsOn,
+	}
+}
+
+// diamond runs b and c after a, then d after both
+func diamond() map[string]any {
+	return map[string]any{
+		"name": "diamond",
+		"tasks": []any{
+			workflowTask("d", "b", "c"),
+			workflowTask("b", "a"),
+			workflowTask("c", "a"),
+			workflowTask("a"),
+		},
+	}
+}
+
+func tasksByKey(status models.WorkflowStatus) map[string]*models.Task {
+	byKey := make(map[string]*models.Task)
+	for _, task := range status.Tasks {
+		byKey[task.WorkflowKey] = task
+	}
+	return byKey
+}
+
+func TestCreateWorkflow(t *testing.T) {
+	router := setupWorkflowRouter(t)
+
+	w := sendJSON(router, "POST", "/api/v1/workflows", diamond())
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var created models.WorkflowStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.ID)
+	assert.Equal(t, models.FailurePolicyFail, created.FailurePolicy)
+	assert.Equal(t, models.TaskStatePending, created.State)
+	require.Len(t, created.Tasks, 4)
+
+	// tasks come back in dependency order
+	assert.Equal(t, "a", created.Tasks[0].WorkflowKey)
+	assert.Equal(t, "d", created.Tasks[3].WorkflowKey)
+
+	tasks := tasksByKey(created)
+	assert.Equal(t, models.TaskStatePending, tasks["a"].State)
+	assert.Equal(t, models.TaskStateBlocked, tasks["b"].State)
+	assert.Equal(t, []string{tasks["a"].ID}, tasks["b"].DependsOn)
+	assert.ElementsMatch(t, []string{tasks["b"].ID, tasks["c"].ID}, tasks["d"].DependsOn)
+
+	w = sendJSON(router, "GET", "/api/v1/workflows/"+created.ID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var fetched models.WorkflowStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &fetched))
+	assert.Equal(t, models.TaskStatePending, fetched.State)
+	assert.Equal(t, 1, fetched.Counts[models.TaskStatePending])
+	assert.Equal(t, 3, fetched.Counts[models.TaskStateBlocked])
+	assert.ElementsMatch(t, []string{tasks["b"].ID, tasks["c"].ID}, tasksByKey(fetched)["d"].DependsOn)
+}
+
+func TestCreateWorkflow_CancelFailsDependents(t *testing.T) {
+	router := setupWorkflowRouter(t)
+
+	req := diamond()
+	req["tasks"] = append(req["tasks"].([]any), map[string]any{
+		"key":            "cleanup",
+		"type":           models.TaskTypeEmailSend,
+		"payload":        map[string]any{},
+		"depends_on":     []string{"d"},
+		"failure_policy": models.FailurePolicySkip,
+	})
+
+	w := sendJSON(router, "POST", "/api/v1/workflows", req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created models.WorkflowStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	w = sendJSON(router, "DELETE", "/api/v1/tasks/"+tasksByKey(created)["a"].ID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = sendJSON(router, "GET", "/api/v1/workflows/"+created.ID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var status models.WorkflowStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, models.TaskStateFailed, status.State)
+
+	tasks := tasksByKey(status)
+	assert.Equal(t, models.TaskStateCancelled, tasks["a"].State)
+	for _, key := range []string{"b", "c", "d"} {
+		assert.Equal(t, models.TaskStateFailed, tasks[key].State, key)
+		assert.Equal(t, models.ErrorReasonDependencyFailed, tasks[key].ErrorReason, key)
+	}
+	assert.Equal(t, models.TaskStateSkipped, tasks["cleanup"].State)
+}
+
+func TestCreateWorkflow_Invalid(t *testing.T) {
+	router := setupWorkflowRouter(t)
+
+	cycle := map[string]any{
+		"tasks": []any{workflowTask("a", "b"), workflowTask("b", "a")},
+	}
+	w := sendJSON(router, "POST", "/api/v1/workflows", cycle)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "dependency cycle")
+
+	unknown := map[string]any{
+		"tasks": []any{workflowTask("a", "missing")},
+	}
+	assert.Equal(t, http.StatusBadRequest, sendJSON(router, "POST", "/api/v1/workflows", unknown).Code)
+
+	duplicate := map[string]any{
+		"tasks": []any{workflowTask("a"), workflowTask("a")},
+	}
+	assert.Equal(t, http.StatusBadRequest, sendJSON(router, "POST", "/api/v1/workflows", duplicate).Code)
+
+	badPolicy := diamond()
+	badPolicy["failure_policy"] = "ignore"
+	assert.Equal(t, http.StatusBadRequest, sendJSON(router, "POST", "/api/v1/workflows", badPolicy).Code)
+
+	assert.Equal(t, http.StatusBadRequest, sendJSON(router, "POST", "/api/v1/workflows", map[string]any{}).Code)
+}
+
+func TestGetWorkflow_NotFound(t *testing.T) {
+	router := setupWorkflowRouter(t)
+
+	w := sendJSON(router, "GET", "/api/v1/workflows/nonexistent-id", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreateTask_DependsOn(t *testing.T) {
+	router := setupWorkflowRouter(t)
+
+	parent := map[string]any{"type": models.TaskTypeEmailSend, "payload": map[string]any{}}
+	w := sendJSON(router, "POST", "/api/v1/tasks", parent)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created models.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	child := map[string]any{
+		"type":       models.TaskTypeEmailSend,
+		"payload":    map[string]any{},
+		"depends_on": []string{created.ID},
+	}
+	w = sendJSON(router, "POST", "/api/v1/tasks", child)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var blocked models.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &blocked))
+	assert.Equal(t, models.TaskStateBlocked, blocked.State)
+	assert.Equal(t, []string{created.ID}, blocked.DependsOn)
+
+	// a parent that already failed fails the new task right away
+	require.Equal(t, http.StatusOK, sendJSON(router, "DELETE", "/api/v1/tasks/"+created.ID, nil).Code)
+	w = sendJSON(router, "POST", "/api/v1/tasks", child)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var failed models.Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &failed))
+	assert.Equal(t, models.TaskStateFailed, failed.State)
+
+	child["depends_on"] = []string{"nonexistent-id"}
+	assert.Equal(t, http.StatusBadRequest, sendJSON(router, "POST", "/api/v1/tasks", child).Code)
+}
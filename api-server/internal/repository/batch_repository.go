@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/lifecycle"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/taskstore"
+	"github.com/lib/pq"
+)
+
+// ErrBatchNotFound is returned when a batch id does not match any row.
+var ErrBatchNotFound = errors.New("batch not found")
+
+type BatchRepository struct {
+	db *database.DB
+}
+
+func NewBatchRepository(db *database.DB) *BatchRepository {
+	return &BatchRepository{db: db}
+}
+
+func (r *BatchRepository) DB() *database.DB {
+	return r.db
+}
+
+// CreateBatch inserts a batch and all of its tasks, with their outbox
+// entries, in one transaction. The tasks must not be blocked. The group the
+// tasks are members of, if any, is inserted along with them.
+func (r *BatchRepository) CreateBatch(
+	ctx context.Context,
+	batch *models.Batch,
+	group *models.Group,
+	tasks []*models.Task,
+) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO task_batches (id, size, created_at) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, query, batch.ID, batch.Size, batch.CreatedAt); err != nil {
+		return fmt.Errorf("failed to create batch: %w", err)
+	}
+
+	if group != nil {
+		if err := insertGroup(ctx, tx, group); err != nil {
+			return err
+		}
+	}
+
+	if err := taskstore.InsertTasks(ctx, tx, tasks); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit batch: %w", err)
+	}
+	return nil
+}
+
+// GetBatchByID retrieves a single batch by its ID.
+func (r *BatchRepository) GetBatchByID(ctx context.Context, id string) (*models.Batch, error) {
+	query := `SELECT id, size, created_at FROM task_batches WHERE id = $1`
+
+	var batch models.Batch
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&batch.ID, &batch.Size, &batch.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrBatchNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+	return &batch, nil
+}
+
+// CancelBatch cancels every task of a batch that can still be cancelled and
+// returns their ids. The tasks depending on them are resolved in the same
+// transaction, like for a single cancellation.
+func (r *BatchRepository) CancelBatch(ctx context.Context, id string) ([]string, error) {
+	if _, err := r.GetBatchByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	cancellable := make([]string, 0)
+	for _, state := range models.StatesTo(models.TaskStateCancelled) {
+		cancellable = append(cancellable, string(state))
+	}
+
+	query := `
+		UPDATE tasks
+		SET state = 'cancelled',
+		    completed_at = NOW()
+		WHERE batch_id = $1
+		  AND state = ANY($2)
+		RETURNING id
+	`
+	rows, err := tx.QueryContext(ctx, query, id, pq.Array(cancellable))
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel batch: %w", err)
+	}
+
+	var cancelled []string
+	for rows.Next() {
+		var taskID string
+		if err := rows.Scan(&taskID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan task id: %w", err)
+		}
+		cancelled = append(cancelled, taskID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cancelled tasks: %w", err)
+	}
+
+	for _, taskID := range cancelled {
+		if _, err := lifecycle.Finish(ctx, tx, taskID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit batch cancellation: %w", err)
+	}
+	return cancelled, nil
+}
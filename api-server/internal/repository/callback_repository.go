@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/models"
+)
+
+type CallbackRepository struct {
+	db *database.DB
+}
+
+func NewCallbackRepository(db *database.DB) *CallbackRepository {
+	return &CallbackRepository{db: db}
+}
+
+func (r *CallbackRepository) DB() *database.DB {
+	return r.db
+}
+
+// ListCallbacks returns the callbacks of a task, oldest first, each with the
+// log of its delivery attempts.
+func (r *CallbackRepository) ListCallbacks(ctx context.Context, taskID string) ([]models.Callback, error) {
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1)`, taskID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+
+	query := `
+		SELECT id, task_id, event, url, payload, state, attempts,
+		       next_attempt_at, created_at, delivered_at
+		FROM task_callbacks
+		WHERE task_id = $1
+		ORDER BY id
+	`
+	rows, err := r.db.QueryContext(ctx, query, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list callbacks: %w", err)
+	}
+	defer rows.Close()
+
+	callbacks := []models.Callback{}
+	byID := make(map[int64]int)
+	for rows.Next() {
+		var (
+			c           models.Callback
+			nextAttempt sql.NullTime
+			deliveredAt sql.NullTime
+		)
+		err := rows.Scan(&c.ID, &c.TaskID, &c.Event, &c.URL, &c.Payload, &c.State, &c.Attempts,
+			&nextAttempt, &c.CreatedAt, &deliveredAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan callback: %w", err)
+		}
+		// only a pending callback has a next attempt
+		if nextAttempt.Valid && c.State == models.CallbackStatePending {
+			c.NextAttemptAt = &nextAttempt.Time
+		}
+		if deliveredAt.Valid {
+			c.DeliveredAt = &deliveredAt.Time
+		}
+		c.AttemptLog = []models.CallbackAttempt{}
+		byID[c.ID] = len(callbacks)
+		callbacks = append(callbacks, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read callbacks: %w", err)
+	}
+	if len(callbacks) == 0 {
+		return callbacks, nil
+	}
+
+	query = `
+		SELECT a.callback_id, a.attempt, a.status_code, a.error, a.duration_ms, a.attempted_at
+		FROM task_callback_attempts a
+		JOIN task_callbacks c ON c.id = a.callback_id
+		WHERE c.task_id = $1
+		ORDER BY a.callback_id, a.attempt
+	`
+	rows, err = r.db.QueryContext(ctx, query, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list callback attempts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			callbackID int64
+			attempt    models.CallbackAttempt
+			statusCode sql.NullInt64
+			attemptErr sql.NullString
+		)
+		err := rows.Scan(&callbackID, &attempt.Attempt, &statusCode, &attemptErr, &attempt.DurationMs, &attempt.AttemptedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan callback attempt: %w", err)
+		}
+		attempt.StatusCode = int(statusCode.Int64)
+		attempt.Error = attemptErr.String
+
+		c := &callbacks[byID[callbackID]]
+		c.AttemptLog = append(c.AttemptLog, attempt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read callback attempts: %w", err)
+	}
+	return callbacks, nil
+}
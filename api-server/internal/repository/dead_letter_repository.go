@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/models"
+)
+
+// ErrDeadLetterNotFound is returned when a task is not among the dead letters.
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetterFilter selects dead letters, an empty field matches everything.
+// After and Before bound when the tasks were dead-lettered.
+type DeadLetterFilter struct {
+	Type        models.TaskType
+	ErrorReason models.ErrorReason
+	After       *time.Time
+	Before      *time.Time
+	Limit       int
+	Offset      int
+}
+
+// where returns the conditions of the filter, appending their arguments
+func (f DeadLetterFilter) where(args *[]any) string {
+	var where strings.Builder
+	where.WriteString("WHERE 1=1")
+	if f.Type != "" {
+		*args = append(*args, f.Type)
+		where.WriteString(fmt.Sprintf(" AND task_type = $%d", len(*args)))
+	}
+	if f.ErrorReason != "" {
+		*args = append(*args, f.ErrorReason)
+		where.WriteString(fmt.Sprintf(" AND error_reason = $%d", len(*args)))
+	}
+	if f.After != nil {
+		*args = append(*args, *f.After)
+		where.WriteString(fmt.Sprintf(" AND created_at >= $%d", len(*args)))
+	}
+	if f.Before != nil {
+		*args = append(*args, *f.Before)
+		where.WriteString(fmt.Sprintf(" AND created_at < $%d", len(*args)))
+	}
+	return where.String()
+}
+
+type DeadLetterRepository struct {
+	db *database.DB
+}
+
+func NewDeadLetterRepository(db *database.DB) *DeadLetterRepository {
+	return &DeadLetterRepository{db: db}
+}
+
+func (r *DeadLetterRepository) DB() *database.DB {
+	return r.db
+}
+
+// ListDeadLetters returns the dead letters selected by filter, oldest first,
+// and how many match the filter regardless of its limit and offset.
+func (r *DeadLetterRepository) ListDeadLetters(ctx context.Context, filter DeadLetterFilter) ([]*models.DeadLetter, int, error) {
+	var args []any
+	where := filter.where(&args)
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM dead_letters `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count dead letters: %w", err)
+	}
+
+	query := `
+		SELECT task_id, task_type, error, error_reason, retry_count, created_at
+		FROM dead_letters
+	` + where + " ORDER BY created_at, task_id"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	deadLetters := []*models.DeadLetter{}
+	for rows.Next() {
+		deadLetter, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read dead letters: %w", err)
+	}
+	return deadLetters, total, nil
+}
+
+// GetDeadLetter returns the dead letter of a task, or ErrDeadLetterNotFound.
+func (r *DeadLetterRepository) GetDeadLetter(ctx context.Context, taskID string) (*models.DeadLetter, error) {
+	query := `
+		SELECT task_id, task_type, error, error_reason, retry_count, created_at
+		FROM dead_letters
+		WHERE task_id = $1
+	`
+
+	deadLetter, err := scanDeadLetter(r.db.QueryRowContext(ctx, query, taskID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrDeadLetterNotFound, taskID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return deadLetter, nil
+}
+
+// DeleteDeadLetter purges the dead letter of a task, the task itself stays
+// failed. It returns ErrDeadLetterNotFound when there is none.
+func (r *DeadLetterRepository) DeleteDeadLetter(ctx context.Context, taskID string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM dead_letters WHERE task_id = $1`, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to delete dead letter: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: %s", ErrDeadLetterNotFound, taskID)
+	}
+	return nil
+}
+
+// DeleteDeadLetters purges the dead letters selected by filter, regardless
+// of its limit and offset, and returns how many were purged.
+func (r *DeadLetterRepository) DeleteDeadLetters(ctx context.Context, filter DeadLetterFilter) (int64, error) {
+	var args []any
+	result, err := r.db.ExecContext(ctx, `DELETE FROM dead_letters `+filter.where(&args), args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete dead letters: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows, nil
+}
+
+func scanDeadLetter(scanner interface {
+	Scan(dest ...any) error
+}) (*models.DeadLetter, error) {
+	var (
+		deadLetter  models.DeadLetter
+		taskErr     sql.NullString
+		errorReason sql.NullString
+	)
+	err := scanner.Scan(&deadLetter.TaskID, &deadLetter.TaskType, &taskErr, &errorReason,
+		&deadLetter.RetryCount, &deadLetter.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan dead letter: %w", err)
+	}
+	deadLetter.Error = taskErr.String
+	deadLetter.ErrorReason = models.ErrorReason(errorReason.String)
+	return &deadLetter, nil
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/lib/pq"
+)
+
+type EventRepository struct {
+	db *database.DB
+}
+
+func NewEventRepository(db *database.DB) *EventRepository {
+	return &EventRepository{db: db}
+}
+
+func (r *EventRepository) DB() *database.DB {
+	return r.db
+}
+
+// ListEvents returns up to limit logged events selected by filter whose id
+// is greater than afterID, in the order they were logged.
+func (r *EventRepository) ListEvents(
+	ctx context.Context,
+	filter models.TaskEventFilter,
+	afterID int64,
+	limit int,
+) ([]*models.TaskEvent, error) {
+	var queryBuilder strings.Builder
+	queryBuilder.WriteString(`
+		SELECT id, type, task_id, task_type, state, previous_state,
+		       worker_id, progress, message, created_at
+		FROM task_events
+		WHERE id > $1
+	`)
+	args := []any{afterID}
+
+	if filter.TaskID != "" {
+		args = append(args, filter.TaskID)
+		queryBuilder.WriteString(fmt.Sprintf(" AND task_id = $%d", len(args)))
+	}
+	if len(filter.Types) > 0 {
+		args = append(args, pq.Array(filter.Types))
+		queryBuilder.WriteString(fmt.Sprintf(" AND type = ANY($%d)", len(args)))
+	}
+	if len(filter.TaskTypes) > 0 {
+		args = append(args, pq.Array(filter.TaskTypes))
+		queryBuilder.WriteString(fmt.Sprintf(" AND task_type = ANY($%d)", len(args)))
+	}
+	if len(filter.States) > 0 {
+		args = append(args, pq.Array(filter.States))
+		queryBuilder.WriteString(fmt.Sprintf(" AND state = ANY($%d)", len(args)))
+	}
+
+	args = append(args, limit)
+	queryBuilder.WriteString(fmt.Sprintf(" ORDER BY id LIMIT $%d", len(args)))
+
+	rows, err := r.db.QueryContext(ctx, queryBuilder.String(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list task events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*models.TaskEvent{}
+	for rows.Next() {
+		var (
+			event         models.TaskEvent
+			previousState sql.NullString
+			workerID      sql.NullString
+			progress      sql.NullFloat64
+			message       sql.NullString
+		)
+		err := rows.Scan(&event.ID, &event.Type, &event.TaskID, &event.TaskType, &event.State, &previousState,
+			&workerID, &progress, &message, &event.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task event: %w", err)
+		}
+		event.PreviousState = models.TaskState(previousState.String)
+		event.WorkerID = workerID.String
+		if progress.Valid {
+			event.Progress = &progress.Float64
+		}
+		event.Message = message.String
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read task events: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/lib/pq"
+)
+
+// ErrGroupNotFound is returned when a group id does not match any row.
+var ErrGroupNotFound = errors.New("group not found")
+
+// groupErrorSample bounds the task ids reported with each group error
+const groupErrorSample = 10
+
+type GroupRepository struct {
+	db *database.DB
+}
+
+func NewGroupRepository(db *database.DB) *GroupRepository {
+	return &GroupRepository{db: db}
+}
+
+func (r *GroupRepository) DB() *database.DB {
+	return r.db
+}
+
+func insertGroup(ctx context.Context, tx *sql.Tx, g *models.Group) error {
+	var onComplete []byte
+	if g.OnComplete != nil {
+		var err error
+		if onComplete, err = json.Marshal(g.OnComplete); err != nil {
+			return fmt.Errorf("failed to encode on_complete task: %w", err)
+		}
+	}
+
+	query := `INSERT INTO task_groups (id, name, on_complete, created_at) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.ExecContext(ctx, query, g.ID, g.Name, onComplete, g.CreatedAt); err != nil {
+		return fmt.Errorf("failed to create group: %w", err)
+	}
+	return nil
+}
+
+// GetGroupByID retrieves a single group by its ID.
+func (r *GroupRepository) GetGroupByID(ctx context.Context, id string) (*models.Group, error) {
+	query := `
+		SELECT id, name, on_complete, on_complete_task_id, created_at, completed_at
+		FROM task_groups
+		WHERE id = $1
+	`
+
+	var (
+		g           models.Group
+		onComplete  []byte
+		taskID      sql.NullString
+		completedAt sql.NullTime
+	)
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&g.ID, &g.Name, &onComplete, &taskID, &g.CreatedAt, &completedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+
+	if len(onComplete) > 0 {
+		if err := json.Unmarshal(onComplete, &g.OnComplete); err != nil {
+			return nil, fmt.Errorf("failed to decode on_complete task: %w", err)
+		}
+	}
+	g.OnCompleteTaskID = taskID.String
+	if completedAt.Valid {
+		g.CompletedAt = &completedAt.Time
+	}
+	return &g, nil
+}
+
+// GetGroupStatus returns a group with the counts of its members by state and
+// the errors of the members that failed for good, most frequent first.
+func (r *GroupRepository) GetGroupStatus(ctx context.Context, id string) (*models.GroupStatus, error) {
+	g, err := r.GetGroupByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT state, COUNT(*), COUNT(*) FILTER (WHERE ` + models.FinishedSQL("") + `)
+		FROM tasks
+		WHERE group_id = $1
+		GROUP BY state
+	`
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count group members: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[models.TaskState]int)
+	finished := 0
+	for rows.Next() {
+		var (
+			state          models.TaskState
+			count, inState int
+		)
+		if err := rows.Scan(&state, &count, &inState); err != nil {
+			return nil, fmt.Errorf("failed to scan group member count: %w", err)
+		}
+		counts[state] = count
+		finished += inState
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read group member counts: %w", err)
+	}
+
+	errs, err := r.getGroupErrors(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewGroupStatus(g, counts, finished, errs), nil
+}
+
+func (r *GroupRepository) getGroupErrors(ctx context.Context, id string) ([]models.GroupError, error) {
+	query := `
+		SELECT COALESCE(error_reason, ''), COALESCE(error, ''), COUNT(*),
+		       (ARRAY_AGG(id ORDER BY id))[1:$2]
+		FROM tasks
+		WHERE group_id = $1
+		  AND state = 'failed'
+		  AND ` + models.FinishedSQL("") + `
+		GROUP BY 1, 2
+		ORDER BY 3 DESC, 2
+	`
+	rows, err := r.db.QueryContext(ctx, query, id, groupErrorSample)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group errors: %w", err)
+	}
+	defer rows.Close()
+
+	var errs []models.GroupError
+	for rows.Next() {
+		var groupErr models.GroupError
+		if err := rows.Scan(&groupErr.Reason, &groupErr.Error, &groupErr.Count, pq.Array(&groupErr.TaskIDs)); err != nil {
+			return nil, fmt.Errorf("failed to scan group error: %w", err)
+		}
+		errs = append(errs, groupErr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read group errors: %w", err)
+	}
+	return errs, nil
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/models"
+)
+
+type HistoryRepository struct {
+	db *database.DB
+}
+
+func NewHistoryRepository(db *database.DB) *HistoryRepository {
+	return &HistoryRepository{db: db}
+}
+
+func (r *HistoryRepository) DB() *database.DB {
+	return r.db
+}
+
+// GetHistory returns the attempts of a task and the events logged for it but
+// progress, oldest first.
+func (r *HistoryRepository) GetHistory(ctx context.Context, taskID string) (*models.TaskHistory, error) {
+	history := &models.TaskHistory{
+		TaskID:   taskID,
+		Attempts: []*models.TaskAttempt{},
+		Events:   []*models.TaskEvent{},
+	}
+	err := r.db.QueryRowContext(ctx, `SELECT state FROM tasks WHERE id = $1`, taskID).Scan(&history.State)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	query := `
+		SELECT id, task_id, attempt, worker_id, state, started_at, finished_at,
+		       duration_ms, error, error_reason, result_size, retry_at
+		FROM task_attempts
+		WHERE task_id = $1
+		ORDER BY attempt
+	`
+	rows, err := r.db.QueryContext(ctx, query, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list task attempts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			a           models.TaskAttempt
+			workerID    sql.NullString
+			finishedAt  sql.NullTime
+			durationMs  sql.NullInt64
+			attemptErr  sql.NullString
+			errorReason sql.NullString
+			resultSize  sql.NullInt64
+			retryAt     sql.NullTime
+		)
+		err := rows.Scan(&a.ID, &a.TaskID, &a.Attempt, &workerID, &a.State, &a.StartedAt, &finishedAt,
+			&durationMs, &attemptErr, &errorReason, &resultSize, &retryAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task attempt: %w", err)
+		}
+		a.WorkerID = workerID.String
+		if finishedAt.Valid {
+			a.FinishedAt = &finishedAt.Time
+		}
+		if durationMs.Valid {
+			a.DurationMs = &durationMs.Int64
+		}
+		a.Error = attemptErr.String
+		a.ErrorReason = models.ErrorReason(errorReason.String)
+		if resultSize.Valid {
+			size := int(resultSize.Int64)
+			a.ResultSize = &size
+		}
+		if retryAt.Valid {
+			a.RetryAt = &retryAt.Time
+		}
+		history.Attempts = append(history.Attempts, &a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read task attempts: %w", err)
+	}
+
+	query = `
+		SELECT id, type, task_id, task_type, state, previous_state, worker_id, message, created_at
+		FROM task_events
+		WHERE task_id = $1
+		  AND type <> $2
+		ORDER BY id
+	`
+	// progress is left to the event stream, there can be any number of them
+	rows, err = r.db.QueryContext(ctx, query, taskID, models.TaskEventProgress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list task events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			event         models.TaskEvent
+			previousState sql.NullString
+			workerID      sql.NullString
+			message       sql.NullString
+		)
+		err := rows.Scan(&event.ID, &event.Type, &event.TaskID, &event.TaskType, &event.State, &previousState,
+			&workerID, &message, &event.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task event: %w", err)
+		}
+		event.PreviousState = models.TaskState(previousState.String)
+		event.WorkerID = workerID.String
+		event.Message = message.String
+		history.Events = append(history.Events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read task events: %w", err)
+	}
+	return history, nil
+}
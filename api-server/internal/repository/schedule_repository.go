@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/models"
+)
+
+// ErrScheduleNotFound is returned when a schedule id does not match any row.
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+const scheduleColumns = `
+	id, name, cron_expr, timezone, task_type, payload, priority,
+	max_retries, timeout_seconds, enabled, overlap_policy, misfire_policy,
+	next_run_at, last_run_at, last_task_id, created_at, updated_at
+`
+
+type ScheduleRepository struct {
+	db *database.DB
+}
+
+func NewScheduleRepository(db *database.DB) *ScheduleRepository {
+	return &ScheduleRepository{db: db}
+}
+
+func (r *ScheduleRepository) DB() *database.DB {
+	return r.db
+}
+
+// CreateSchedule inserts a new schedule.
+func (r *ScheduleRepository) CreateSchedule(ctx context.Context, schedule *models.Schedule) error {
+	query := `
+		INSERT INTO schedules (
+			id, name, cron_expr, timezone, task_type, payload, priority,
+			max_retries, timeout_seconds, enabled, overlap_policy, misfire_policy,
+			next_run_at, created_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		schedule.ID, schedule.Name, schedule.CronExpr, schedule.Timezone,
+		schedule.Task.Type, schedule.Task.Payload, schedule.Task.Priority,
+		schedule.Task.MaxRetries, schedule.Task.TimeoutSeconds, schedule.Enabled,
+		schedule.OverlapPolicy, schedule.MisfirePolicy, schedule.NextRunAt,
+		schedule.CreatedAt, schedule.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create schedule: %w", err)
+	}
+	return nil
+}
+
+// GetScheduleByID retrieves a single schedule by its ID.
+func (r *ScheduleRepository) GetScheduleByID(ctx context.Context, id string) (*models.Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM schedules WHERE id = $1`
+
+	schedule, err := scanSchedule(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrScheduleNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// ListSchedules lists schedules, oldest first.
+func (r *ScheduleRepository) ListSchedules(ctx context.Context, limit, offset int) ([]*models.Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM schedules ORDER BY created_at ASC`
+	args := []any{}
+	if limit > 0 {
+		args = append(args, limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if offset > 0 {
+		args = append(args, offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []*models.Schedule
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// UpdateSchedule replaces the definition of a schedule. The firing history,
+// last_run_at and last_task_id, is kept.
+func (r *ScheduleRepository) UpdateSchedule(ctx context.Context, schedule *models.Schedule) error {
+	query := `
+		UPDATE schedules
+		SET name = $2,
+		    cron_expr = $3,
+		    timezone = $4,
+		    task_type = $5,
+		    payload = $6,
+		    priority = $7,
+		    max_retries = $8,
+		    timeout_seconds = $9,
+		    enabled = $10,
+		    overlap_policy = $11,
+		    misfire_policy = $12,
+		    next_run_at = $13,
+		    updated_at = $14
+		WHERE id = $1
+	`
+
+	res, err := r.db.ExecContext(ctx, query,
+		schedule.ID, schedule.Name, schedule.CronExpr, schedule.Timezone,
+		schedule.Task.Type, schedule.Task.Payload, schedule.Task.Priority,
+		schedule.Task.MaxRetries, schedule.Task.TimeoutSeconds, schedule.Enabled,
+		schedule.OverlapPolicy, schedule.MisfirePolicy, schedule.NextRunAt,
+		schedule.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update schedule: %w", err)
+	}
+	return requireSchedule(res, schedule.ID)
+}
+
+// DeleteSchedule removes a schedule. Tasks it already created are kept.
+func (r *ScheduleRepository) DeleteSchedule(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM schedules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	return requireSchedule(res, id)
+}
+
+func requireSchedule(res sql.Result, id string) error {
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: %s", ErrScheduleNotFound, id)
+	}
+	return nil
+}
+
+// scanSchedule maps a row selected with scheduleColumns into a Schedule.
+func scanSchedule(scanner interface {
+	Scan(dest ...any) error
+}) (*models.Schedule, error) {
+	var (
+		schedule   models.Schedule
+		nextRunAt  sql.NullTime
+		lastRunAt  sql.NullTime
+		lastTaskID sql.NullString
+	)
+
+	err := scanner.Scan(
+		&schedule.ID, &schedule.Name, &schedule.CronExpr, &schedule.Timezone,
+		&schedule.Task.Type, &schedule.Task.Payload, &schedule.Task.Priority,
+		&schedule.Task.MaxRetries, &schedule.Task.TimeoutSeconds, &schedule.Enabled,
+		&schedule.OverlapPolicy, &schedule.MisfirePolicy,
+		&nextRunAt, &lastRunAt, &lastTaskID, &schedule.CreatedAt, &schedule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if nextRunAt.Valid {
+		schedule.NextRunAt = &nextRunAt.Time
+	}
+	if lastRunAt.Valid {
+		schedule.LastRunAt = &lastRunAt.Time
+	}
+	if lastTaskID.Valid {
+		schedule.LastTaskID = lastTaskID.String
+	}
+
+	return &schedule, nil
+}
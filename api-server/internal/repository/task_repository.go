@@ -3,11 +3,28 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/lifecycle"
 	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/outbox"
+	"github.com/alaajili/task-scheduler/shared/taskstore"
+	"github.com/alaajili/task-scheduler/shared/workflow"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrTaskNotFound is returned when a task id does not match any row.
+	ErrTaskNotFound = errors.New("task not found")
+
+	// ErrIdempotencyKeyReused is returned when an idempotency key that is still
+	// live comes with a different request than the one it was first used for.
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
 )
 
 type TaskRepository struct {
@@ -22,32 +39,227 @@ func (r *TaskRepository) DB() *database.DB {
 	return r.db
 }
 
-// CreateTask inserts a new task into the database.
-func (r *TaskRepository) CreateTask(ctx context.Context, task *models.Task) error {
+// CreateTask inserts a new task into the database together with its outbox
+// entry, so the task is guaranteed to be published to the queue eventually.
+// A blocked task only gets its outbox entry once its dependencies allow it to
+// run, which may already be the case when it is created.
+//
+// When the options make it return an existing task instead, e.g. because the
+// task's unique key is held, that task is returned. It returns nil when the
+// task was created.
+func (r *TaskRepository) CreateTask(ctx context.Context, task *models.Task, opts CreateOptions) (*models.Task, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	existing, err := r.createTask(ctx, tx, task, opts.OnConflict)
+	if err != nil {
+		return nil, err
+	}
+
+	if key := opts.IdempotencyKey; key != nil {
+		taskID := task.ID
+		if existing != nil {
+			taskID = existing.ID
+		}
+		claimed, err := claimIdempotencyKey(ctx, tx, *key, taskID)
+		if err != nil {
+			return nil, err
+		}
+		if !claimed {
+			tx.Rollback()
+			return r.getTaskByIdempotencyKey(ctx, *key)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit task: %w", err)
+	}
+	return existing, nil
+}
+
+// createTask inserts the task unless another task holds its unique key, in
+// which case onConflict decides and the holder may be returned instead
+func (r *TaskRepository) createTask(
+	ctx context.Context,
+	tx *sql.Tx,
+	task *models.Task,
+	onConflict models.ConflictPolicy,
+) (*models.Task, error) {
+	if task.UniqueKey != "" {
+		holder, err := r.lockUniqueKey(ctx, tx, task)
+		if err != nil {
+			return nil, err
+		}
+		if holder != nil {
+			return holder, resolveConflict(ctx, tx, holder, task, onConflict)
+		}
+	}
+
+	if err := taskstore.InsertTask(ctx, tx, task); err != nil {
+		return nil, err
+	}
+
+	if task.State == models.TaskStateBlocked {
+		if err := workflow.InsertDependencies(ctx, tx, task.ID, task.DependsOn); err != nil {
+			return nil, err
+		}
+		var err error
+		if task.State, err = workflow.ResolveTask(ctx, tx, task.ID); err != nil {
+			return nil, err
+		}
+		if task.State == models.TaskStateFailed || task.State == models.TaskStateSkipped {
+			// a dependency did not complete, the task finished right away
+			if _, err := lifecycle.Finish(ctx, tx, task.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, nil
+}
+
+// lockUniqueKey serializes the creations of tasks with the same type and
+// unique key for the rest of the transaction, and returns the task holding
+// the key, if any, locked for update
+func (r *TaskRepository) lockUniqueKey(ctx context.Context, tx *sql.Tx, task *models.Task) (*models.Task, error) {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, task.QueueUniqueKey()); err != nil {
+		return nil, fmt.Errorf("failed to lock unique key: %w", err)
+	}
+
+	// HoldsUniqueKey has the final say, this only leaves out the tasks
+	// that are done with their key whatever their scope
 	query := `
-		INSERT INTO tasks (
-			id, type, payload, priority, state,
-			retry_count, max_retries, created_at
-		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		SELECT ` + taskColumns + `
+		FROM tasks
+		WHERE type = $1
+		  AND unique_key = $2
+		  AND (state NOT IN ('completed', 'cancelled', 'skipped', 'failed')
+		       OR (state = 'failed' AND retry_count < max_retries)
+		       OR unique_until > NOW())
+		ORDER BY created_at
+		FOR UPDATE
 	`
+	rows, err := tx.QueryContext(ctx, query, task.Type, task.UniqueKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks holding unique key: %w", err)
+	}
+	defer rows.Close()
 
-	_, err := r.db.ExecContext(ctx, query,
-		task.ID, task.Type, task.Payload, task.Priority, task.State,
-		task.RetryCount, task.MaxRetries, task.CreatedAt,
-	)
+	now := time.Now()
+	var holder *models.Task
+	for rows.Next() {
+		candidate, err := r.scanTask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task: %w", err)
+		}
+		if holder == nil && candidate.HoldsUniqueKey(now) {
+			holder = candidate
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tasks holding unique key: %w", err)
+	}
+	return holder, nil
+}
+
+// resolveConflict applies the conflict policy of a task whose unique key is
+// held by another one
+func resolveConflict(
+	ctx context.Context,
+	tx *sql.Tx,
+	holder, task *models.Task,
+	onConflict models.ConflictPolicy,
+) error {
+	switch onConflict {
+	case models.ConflictReturnExisting:
+		return nil
+	case models.ConflictReplace:
+		if holder.State == models.TaskStateRunning {
+			return fmt.Errorf("%w: task %s holding unique key %s is already running",
+				models.ErrConflict, holder.ID, task.UniqueKey)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE tasks SET payload = $2 WHERE id = $1`, holder.ID, task.Payload); err != nil {
+			return fmt.Errorf("failed to replace task payload: %w", err)
+		}
+		holder.Payload = task.Payload
+		return nil
+	default:
+		return fmt.Errorf("%w: unique key %s is held by task %s", models.ErrDuplicateTask, task.UniqueKey, holder.ID)
+	}
+}
+
+// claimIdempotencyKey records the key for a task and reports whether it did.
+// A live key wins, an expired one is taken over. Concurrent requests with the
+// same key wait on the primary key until the first one commits.
+func claimIdempotencyKey(ctx context.Context, tx *sql.Tx, key IdempotencyKey, taskID string) (bool, error) {
+	query := `
+		INSERT INTO idempotency_keys (key, request_hash, task_id, expires_at)
+		VALUES ($1, $2, $3, NOW() + make_interval(secs => $4))
+		ON CONFLICT (key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+		    task_id = EXCLUDED.task_id,
+		    created_at = NOW(),
+		    expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+		RETURNING key
+	`
+	var claimed string
+	err := tx.QueryRowContext(ctx, query, key.Key, key.RequestHash, taskID, key.Retention.Seconds()).Scan(&claimed)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create task: %w", err)
+		return false, fmt.Errorf("failed to claim idempotency key: %w", err)
 	}
-	return nil
+	return true, nil
+}
+
+// getTaskByIdempotencyKey returns the task a live idempotency key was used for
+func (r *TaskRepository) getTaskByIdempotencyKey(ctx context.Context, key IdempotencyKey) (*models.Task, error) {
+	query := `
+		SELECT request_hash, task_id
+		FROM idempotency_keys
+		WHERE key = $1
+		  AND expires_at > NOW()
+	`
+
+	var requestHash, taskID string
+	err := r.db.QueryRowContext(ctx, query, key.Key).Scan(&requestHash, &taskID)
+	if err == sql.ErrNoRows {
+		// it expired after our insert lost to it, the client may retry
+		return nil, fmt.Errorf("%w: idempotency key %s expired concurrently", models.ErrConflict, key.Key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	if requestHash != key.RequestHash {
+		return nil, fmt.Errorf("%w: %s", ErrIdempotencyKeyReused, key.Key)
+	}
+	return r.GetTaskByID(ctx, taskID)
+}
+
+// DeleteExpiredIdempotencyKeys removes the idempotency keys past their
+// retention and returns how many were removed.
+func (r *TaskRepository) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return deleted, nil
 }
 
 // GetTaskByID retrieves a single task by its ID.
 func (r *TaskRepository) GetTaskByID(ctx context.Context, id string) (*models.Task, error) {
 	query := `
-		SELECT id, type, payload, priority, state, result, error,
-		       retry_count, max_retries, created_at, started_at,
-		       completed_at, worker_id
+		SELECT ` + taskColumns + `
 		FROM tasks
 		WHERE id = $1
 	`
@@ -55,11 +267,15 @@ func (r *TaskRepository) GetTaskByID(ctx context.Context, id string) (*models.Ta
 	row := r.db.QueryRowContext(ctx, query, id)
 	task, err := r.scanTask(row)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("task not found: %s", id)
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, id)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get task: %w", err)
 	}
+
+	if task.DependsOn, err = workflow.Dependencies(ctx, r.db.DB, id); err != nil {
+		return nil, err
+	}
 	return task, nil
 }
 
@@ -72,9 +288,7 @@ func (r *TaskRepository) ListTasks(ctx context.Context, filters ListFilters) ([]
 	)
 
 	queryBuilder.WriteString(`
-		SELECT id, type, payload, priority, state, result, error,
-		       retry_count, max_retries, created_at, started_at,
-		       completed_at, worker_id
+		SELECT ` + taskColumns + `
 		FROM tasks
 		WHERE 1=1
 	`)
@@ -89,6 +303,21 @@ func (r *TaskRepository) ListTasks(ctx context.Context, filters ListFilters) ([]
 		args = append(args, filters.Type)
 		argIndex++
 	}
+	if filters.WorkflowID != "" {
+		queryBuilder.WriteString(fmt.Sprintf(" AND workflow_id = $%d", argIndex))
+		args = append(args, filters.WorkflowID)
+		argIndex++
+	}
+	if filters.BatchID != "" {
+		queryBuilder.WriteString(fmt.Sprintf(" AND batch_id = $%d", argIndex))
+		args = append(args, filters.BatchID)
+		argIndex++
+	}
+	if filters.GroupID != "" {
+		queryBuilder.WriteString(fmt.Sprintf(" AND group_id = $%d", argIndex))
+		args = append(args, filters.GroupID)
+		argIndex++
+	}
 
 	queryBuilder.WriteString(" ORDER BY priority DESC, created_at ASC")
 
@@ -120,11 +349,30 @@ func (r *TaskRepository) ListTasks(ctx context.Context, filters ListFilters) ([]
 	return tasks, nil
 }
 
-// UpdateTaskState updates the state of a task by ID.
-func (r *TaskRepository) UpdateTaskState(ctx context.Context, id string, state models.TaskState) error {
-	query := `UPDATE tasks SET state = $1 WHERE id = $2`
+// TransitionState moves a task from one state to another with a
+// compare-and-set update. It returns models.ErrInvalidTransition when the move
+// is not allowed, and models.ErrConflict when the task is no longer in the
+// expected state.
+func (r *TaskRepository) TransitionState(ctx context.Context, id string, from, to models.TaskState) error {
+	if err := models.ValidateTransition(from, to); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE tasks
+		SET state = $3,
+		    completed_at = CASE WHEN $4 THEN NOW() ELSE completed_at END
+		WHERE id = $1
+		  AND state = $2
+	`
 
-	res, err := r.db.ExecContext(ctx, query, state, id)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, query, id, from, to, to.IsTerminal())
 	if err != nil {
 		return fmt.Errorf("failed to update task state: %w", err)
 	}
@@ -134,11 +382,327 @@ func (r *TaskRepository) UpdateTaskState(ctx context.Context, id string, state m
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("task not found: %s", id)
+		// tell a missing task apart from one that moved on
+		if _, err := r.GetTaskByID(ctx, id); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s is no longer %s", models.ErrConflict, id, from)
+	}
+
+	// the tasks waiting for this one will not see it complete anymore
+	if to.IsTerminal() {
+		if _, err := lifecycle.Finish(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit task state: %w", err)
 	}
 	return nil
 }
 
+// RetryOptions change the tasks retried by hand.
+type RetryOptions struct {
+	// ResetRetries gives the tasks all of their retries again
+	ResetRetries bool
+	Priority     *int
+	Payload      json.RawMessage
+	// Message is logged with the retried event of every task
+	Message string
+}
+
+// RetryFilter selects the tasks RetryTasks retries among the ones that can be
+// retried, an empty field matches everything. FinishedAfter and
+// FinishedBefore bound when the tasks finished.
+type RetryFilter struct {
+	IDs            []string
+	Type           models.TaskType
+	States         []models.TaskState
+	FinishedAfter  *time.Time
+	FinishedBefore *time.Time
+	Limit          int
+}
+
+// RetryTask moves a failed or terminal task back to pending and returns it. It
+// returns models.ErrInvalidTransition when the task cannot be retried.
+func (r *TaskRepository) RetryTask(ctx context.Context, id string, opts RetryOptions) (*models.Task, error) {
+	retried, err := r.RetryTasks(ctx, RetryFilter{IDs: []string{id}}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := r.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(retried) == 0 {
+		return nil, fmt.Errorf("%w: %s -> %s", models.ErrInvalidTransition, task.State, models.TaskStatePending)
+	}
+	return task, nil
+}
+
+// RetryTasks moves the failed or terminal tasks selected by filter back to
+// pending, oldest finished first, and returns their ids. The outcome of their
+// last run is cleared, the retried event is logged, the tasks leave the dead
+// letters and get their outbox entries in the same transaction.
+func (r *TaskRepository) RetryTasks(ctx context.Context, filter RetryFilter, opts RetryOptions) ([]string, error) {
+	states := models.RetryableStates()
+	if len(filter.States) > 0 {
+		states = filter.States
+	}
+
+	var queryBuilder strings.Builder
+	queryBuilder.WriteString(`
+		WITH picked AS (
+			SELECT id, state
+			FROM tasks
+			WHERE state = ANY($1)
+	`)
+	args := []any{pq.Array(states)}
+
+	if len(filter.IDs) > 0 {
+		args = append(args, pq.Array(filter.IDs))
+		queryBuilder.WriteString(fmt.Sprintf(" AND id = ANY($%d)", len(args)))
+	}
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		queryBuilder.WriteString(fmt.Sprintf(" AND type = $%d", len(args)))
+	}
+	if filter.FinishedAfter != nil {
+		args = append(args, *filter.FinishedAfter)
+		queryBuilder.WriteString(fmt.Sprintf(" AND completed_at >= $%d", len(args)))
+	}
+	if filter.FinishedBefore != nil {
+		args = append(args, *filter.FinishedBefore)
+		queryBuilder.WriteString(fmt.Sprintf(" AND completed_at < $%d", len(args)))
+	}
+	queryBuilder.WriteString(" ORDER BY completed_at, id")
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		queryBuilder.WriteString(fmt.Sprintf(" LIMIT $%d", len(args)))
+	}
+
+	var payload any
+	if len(opts.Payload) > 0 {
+		payload = []byte(opts.Payload)
+	}
+	args = append(args, opts.ResetRetries, opts.Priority, payload)
+	n := len(args)
+	queryBuilder.WriteString(fmt.Sprintf(`
+			FOR UPDATE
+		)
+		UPDATE tasks t
+		SET state = 'pending',
+		    retry_count = CASE WHEN $%d THEN 0 ELSE t.retry_count END,
+		    priority = COALESCE($%d, t.priority),
+		    payload = COALESCE($%d, t.payload),
+		    result = NULL,
+		    error = NULL,
+		    error_reason = NULL,
+		    started_at = NULL,
+		    completed_at = NULL,
+		    worker_id = NULL,
+		    not_before = NULL
+		FROM picked p
+		WHERE t.id = p.id
+		RETURNING t.id, t.type, t.priority, p.state
+	`, n-2, n-1, n))
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, queryBuilder.String(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		ids            []string
+		types          []string
+		previousStates []string
+		entries        []outbox.Entry
+	)
+	for rows.Next() {
+		var (
+			entry         outbox.Entry
+			taskType      string
+			previousState string
+		)
+		if err := rows.Scan(&entry.TaskID, &taskType, &entry.Priority, &previousState); err != nil {
+			return nil, fmt.Errorf("failed to scan retried task: %w", err)
+		}
+		ids = append(ids, entry.TaskID)
+		types = append(types, taskType)
+		previousStates = append(previousStates, previousState)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read retried tasks: %w", err)
+	}
+	rows.Close()
+	if len(ids) == 0 {
+		return []string{}, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO task_events (task_id, task_type, type, state, previous_state, message)
+		SELECT id, task_type, $4, 'pending', previous_state, NULLIF($5, '')
+		FROM unnest($1::varchar[], $2::varchar[], $3::varchar[]) AS r(id, task_type, previous_state)
+	`, pq.Array(ids), pq.Array(types), pq.Array(previousStates), models.TaskEventRetried, opts.Message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record task retries: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM dead_letters WHERE task_id = ANY($1)`, pq.Array(ids)); err != nil {
+		return nil, fmt.Errorf("failed to remove retried dead letters: %w", err)
+	}
+
+	if err := outbox.InsertEntries(ctx, tx, entries); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit task retries: %w", err)
+	}
+	return ids, nil
+}
+
+// TaskUpdate changes a task that has not started yet, nil fields are kept.
+type TaskUpdate struct {
+	Priority   *int
+	MaxRetries *int
+	// RunAt holds the task back until then, a past time makes it due
+	RunAt   *time.Time
+	Payload json.RawMessage
+	// Message is logged with the updated event
+	Message string
+}
+
+// UpdateHook runs inside the transaction of UpdateTask with the task as it
+// was and as it is updated, an error rolls the update back. When the commit
+// fails it runs again with the two swapped to undo its work.
+type UpdateHook func(ctx context.Context, before, after *models.Task) error
+
+// UpdateTask changes a pending, scheduled or blocked task and returns it. It
+// returns models.ErrInvalidTransition for tasks that have started or
+// finished, and for payload changes to tasks that are not pending. The outbox
+// entries of the task follow the update, a new due time replaces them, and
+// requeue, if set, moves the task in the queue before the update commits.
+// Blocked tasks are not queued yet and skip it.
+func (r *TaskRepository) UpdateTask(ctx context.Context, id string, update TaskUpdate, requeue UpdateHook) (*models.Task, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `SELECT `+taskColumns+` FROM tasks WHERE id = $1 FOR UPDATE`, id)
+	before, err := r.scanTask(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+	if !models.CanEdit(before.State) {
+		return nil, fmt.Errorf("%w: %s tasks cannot be updated", models.ErrInvalidTransition, before.State)
+	}
+	if len(update.Payload) > 0 && before.State != models.TaskStatePending {
+		return nil, fmt.Errorf("%w: the payload of %s tasks cannot be updated", models.ErrInvalidTransition, before.State)
+	}
+
+	var payload any
+	if len(update.Payload) > 0 {
+		payload = []byte(update.Payload)
+	}
+	query := `
+		UPDATE tasks
+		SET priority = COALESCE($2, priority),
+		    max_retries = COALESCE($3, max_retries),
+		    payload = COALESCE($4, payload),
+		    not_before = COALESCE($5, not_before),
+		    state = CASE
+		        WHEN state = 'blocked' OR $5::timestamp IS NULL THEN state
+		        WHEN $5 > NOW() THEN 'scheduled'
+		        ELSE 'pending'
+		    END
+		WHERE id = $1
+		RETURNING ` + taskColumns
+	row = tx.QueryRowContext(ctx, query, id, update.Priority, update.MaxRetries, payload, update.RunAt)
+	after, err := r.scanTask(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update task: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO task_events (task_id, task_type, type, state, previous_state, message)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+	`, id, after.Type, models.TaskEventUpdated, after.State, before.State, update.Message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record task update: %w", err)
+	}
+
+	requeued := false
+	if after.State != models.TaskStateBlocked {
+		if err := r.updateOutbox(ctx, tx, after, update.RunAt != nil); err != nil {
+			return nil, err
+		}
+		if requeue != nil {
+			if err := requeue(ctx, before, after); err != nil {
+				return nil, err
+			}
+			requeued = true
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		err = fmt.Errorf("failed to commit task update: %w", err)
+		if requeued {
+			// the queue goes back to the task as it still is
+			if undoErr := requeue(ctx, after, before); undoErr != nil {
+				return nil, errors.Join(err, undoErr)
+			}
+		}
+		return nil, err
+	}
+	return after, nil
+}
+
+// updateOutbox carries the new priority over to the outbox entries of the
+// task that were not published yet. When the due time changed the entries are
+// replaced, the task may have been leased by a worker that can no longer
+// start it
+func (r *TaskRepository) updateOutbox(ctx context.Context, tx *sql.Tx, task *models.Task, rescheduled bool) error {
+	if !rescheduled {
+		_, err := tx.ExecContext(ctx, `UPDATE task_outbox SET priority = $2 WHERE task_id = $1`, task.ID, task.Priority)
+		if err != nil {
+			return fmt.Errorf("failed to update outbox entries: %w", err)
+		}
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM task_outbox WHERE task_id = $1`, task.ID); err != nil {
+		return fmt.Errorf("failed to delete outbox entries: %w", err)
+	}
+	if task.State == models.TaskStateScheduled {
+		return outbox.InsertScheduled(ctx, tx, task.ID, task.Priority, *task.NotBefore)
+	}
+	return outbox.Insert(ctx, tx, task.ID, task.Priority)
+}
+
+// taskColumns are the columns scanTask reads, in order
+const taskColumns = `id, type, payload, priority, state, result, error,
+		       retry_count, max_retries, created_at, started_at,
+		       completed_at, worker_id, timeout_seconds, error_reason,
+		       not_before, failure_policy, workflow_id, workflow_key,
+		       unique_key, unique_scope, unique_until, batch_id, group_id,
+		       callback_url`
+
 // scanTask maps SQL row data into a Task struct, handling nullable fields.
 func (r *TaskRepository) scanTask(scanner interface {
 	Scan(dest ...any) error
@@ -150,12 +714,26 @@ func (r *TaskRepository) scanTask(scanner interface {
 		result      sql.NullString
 		errorMsg    sql.NullString
 		workerID    sql.NullString
+		errorReason sql.NullString
+		notBefore   sql.NullTime
+		workflowID  sql.NullString
+		workflowKey sql.NullString
+		uniqueKey   sql.NullString
+		uniqueScope sql.NullString
+		uniqueUntil sql.NullTime
+		batchID     sql.NullString
+		groupID     sql.NullString
+		callbackURL sql.NullString
 	)
 
 	err := scanner.Scan(
 		&task.ID, &task.Type, &task.Payload, &task.Priority, &task.State,
 		&result, &errorMsg, &task.RetryCount, &task.MaxRetries,
 		&task.CreatedAt, &startedAt, &completedAt, &workerID,
+		&task.TimeoutSeconds, &errorReason, &notBefore,
+		&task.FailurePolicy, &workflowID, &workflowKey,
+		&uniqueKey, &uniqueScope, &uniqueUntil, &batchID, &groupID,
+		&callbackURL,
 	)
 	if err != nil {
 		return nil, err
@@ -167,6 +745,9 @@ func (r *TaskRepository) scanTask(scanner interface {
 	if errorMsg.Valid {
 		task.Error = errorMsg.String
 	}
+	if errorReason.Valid {
+		task.ErrorReason = models.ErrorReason(errorReason.String)
+	}
 	if workerID.Valid {
 		task.WorkerID = workerID.String
 	}
@@ -176,14 +757,48 @@ func (r *TaskRepository) scanTask(scanner interface {
 	if completedAt.Valid {
 		task.CompletedAt = &completedAt.Time
 	}
+	if notBefore.Valid {
+		task.NotBefore = &notBefore.Time
+	}
+	task.WorkflowID = workflowID.String
+	task.WorkflowKey = workflowKey.String
+	task.UniqueKey = uniqueKey.String
+	task.UniqueScope = models.UniqueScope(uniqueScope.String)
+	if uniqueUntil.Valid {
+		task.UniqueUntil = &uniqueUntil.Time
+	}
+	task.BatchID = batchID.String
+	task.GroupID = groupID.String
+	task.CallbackURL = callbackURL.String
 
 	return &task, nil
 }
 
+// CreateOptions tune what CreateTask does when the task may already exist.
+type CreateOptions struct {
+	// OnConflict applies when another task holds the task's unique key,
+	// rejecting the task by default
+	OnConflict models.ConflictPolicy
+	// IdempotencyKey, when set, makes a retried creation return the task the
+	// key was first used for
+	IdempotencyKey *IdempotencyKey
+}
+
+// IdempotencyKey is a client supplied key for a task creation, RequestHash
+// identifies the request it came with.
+type IdempotencyKey struct {
+	Key         string
+	RequestHash string
+	Retention   time.Duration
+}
+
 // ListFilters defines optional filters for listing tasks.
 type ListFilters struct {
-	State  models.TaskState
-	Type   models.TaskType
-	Limit  int
-	Offset int
+	State      models.TaskState
+	Type       models.TaskType
+	WorkflowID string
+	BatchID    string
+	GroupID    string
+	Limit      int
+	Offset     int
 }
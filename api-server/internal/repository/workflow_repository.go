@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/taskstore"
+	"github.com/alaajili/task-scheduler/shared/workflow"
+)
+
+// ErrWorkflowNotFound is returned when a workflow id does not match any row.
+var ErrWorkflowNotFound = errors.New("workflow not found")
+
+type WorkflowRepository struct {
+	db *database.DB
+}
+
+func NewWorkflowRepository(db *database.DB) *WorkflowRepository {
+	return &WorkflowRepository{db: db}
+}
+
+func (r *WorkflowRepository) DB() *database.DB {
+	return r.db
+}
+
+// CreateWorkflow inserts a workflow and all of its tasks in one transaction.
+// Tasks must come after the tasks they depend on. The tasks without
+// dependencies get their outbox entries, the others start out blocked.
+func (r *WorkflowRepository) CreateWorkflow(ctx context.Context, wf *models.Workflow, tasks []*models.Task) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO workflows (id, name, failure_policy, created_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	if _, err := tx.ExecContext(ctx, query, wf.ID, wf.Name, wf.FailurePolicy, wf.CreatedAt); err != nil {
+		return fmt.Errorf("failed to create workflow: %w", err)
+	}
+
+	for _, task := range tasks {
+		if err := taskstore.InsertTask(ctx, tx, task); err != nil {
+			return err
+		}
+		if task.State == models.TaskStateBlocked {
+			if err := workflow.InsertDependencies(ctx, tx, task.ID, task.DependsOn); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit workflow: %w", err)
+	}
+	return nil
+}
+
+// GetWorkflowByID retrieves a single workflow by its ID.
+func (r *WorkflowRepository) GetWorkflowByID(ctx context.Context, id string) (*models.Workflow, error) {
+	query := `SELECT id, name, failure_policy, created_at FROM workflows WHERE id = $1`
+
+	var wf models.Workflow
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&wf.ID, &wf.Name, &wf.FailurePolicy, &wf.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrWorkflowNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow: %w", err)
+	}
+	return &wf, nil
+}
+
+// GetWorkflowDependencies returns the dependencies of every task of a workflow
+// that has some, keyed by task id.
+func (r *WorkflowRepository) GetWorkflowDependencies(ctx context.Context, id string) (map[string][]string, error) {
+	query := `
+		SELECT d.task_id, d.depends_on
+		FROM task_dependencies d
+		JOIN tasks t ON t.id = d.task_id
+		WHERE t.workflow_id = $1
+		ORDER BY d.task_id, d.depends_on
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow dependencies: %w", err)
+	}
+	defer rows.Close()
+
+	deps := make(map[string][]string)
+	for rows.Next() {
+		var taskID, dependsOn string
+		if err := rows.Scan(&taskID, &dependsOn); err != nil {
+			return nil, fmt.Errorf("failed to scan dependency: %w", err)
+		}
+		deps[taskID] = append(deps[taskID], dependsOn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read workflow dependencies: %w", err)
+	}
+	return deps, nil
+}
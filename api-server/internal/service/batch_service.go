@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidBatch is returned when a batch request does not validate as a whole.
+var ErrInvalidBatch = errors.New("invalid batch")
+
+// MaxBatchSize bounds the number of tasks of a single batch request.
+const MaxBatchSize = 10000
+
+type BatchService struct {
+	repo     *repository.BatchRepository
+	taskRepo *repository.TaskRepository
+	tasks    *TaskService
+}
+
+func NewBatchService(
+	repo *repository.BatchRepository,
+	taskRepo *repository.TaskRepository,
+	tasks *TaskService,
+) *BatchService {
+	return &BatchService{repo: repo, taskRepo: taskRepo, tasks: tasks}
+}
+
+// CreateBatch validates every task of a batch request and creates the valid
+// ones in a single transaction. The invalid ones are reported in the result
+// without failing the others, no batch is created when none is valid.
+func (s *BatchService) CreateBatch(ctx context.Context, req CreateBatchRequest) (*BatchResult, error) {
+	if len(req.Tasks) > MaxBatchSize {
+		return nil, fmt.Errorf("%w: at most %d tasks per batch, got %d", ErrInvalidBatch, MaxBatchSize, len(req.Tasks))
+	}
+	if req.Group != nil {
+		if err := req.Group.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &BatchResult{Results: make([]BatchItemResult, len(req.Tasks))}
+	tasks := make([]*models.Task, 0, len(req.Tasks))
+	for i := range req.Tasks {
+		item := &result.Results[i]
+		item.Index = i
+
+		if err := req.Tasks[i].validateBatchItem(); err != nil {
+			item.Error = err.Error()
+			result.Failed++
+			continue
+		}
+		task := req.Tasks[i].newTask()
+		item.TaskID = task.ID
+		item.State = task.State
+		tasks = append(tasks, task)
+	}
+	if len(tasks) == 0 {
+		return result, nil
+	}
+
+	batch := models.NewBatch(len(tasks))
+	var group *models.Group
+	if req.Group != nil {
+		group = models.NewGroup(req.Group.Name, req.Group.OnComplete)
+		result.GroupID = group.ID
+	}
+	for _, task := range tasks {
+		task.BatchID = batch.ID
+		if group != nil {
+			task.GroupID = group.ID
+		}
+	}
+
+	if err := s.repo.CreateBatch(ctx, batch, group, tasks); err != nil {
+		logger.Error("Failed to create batch",
+			zap.String("batch_id", batch.ID),
+			zap.Int("tasks", len(tasks)),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("failed to create batch: %w", err)
+	}
+
+	logger.Info("Batch created successfully",
+		zap.String("batch_id", batch.ID),
+		zap.String("group_id", result.GroupID),
+		zap.Int("tasks", len(tasks)),
+		zap.Int("failed", result.Failed),
+	)
+
+	ids := make([]string, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	s.tasks.publishAll(ctx, ids)
+
+	result.BatchID = batch.ID
+	result.Created = len(tasks)
+	return result, nil
+}
+
+// GetBatch returns a batch with the current state of its tasks.
+func (s *BatchService) GetBatch(ctx context.Context, batchID string) (*models.BatchStatus, error) {
+	batch, err := s.repo.GetBatchByID(ctx, batchID)
+	if err != nil {
+		logger.Error("Failed to get batch",
+			zap.String("batch_id", batchID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	tasks, err := s.taskRepo.ListTasks(ctx, repository.ListFilters{BatchID: batchID})
+	if err != nil {
+		return nil, err
+	}
+	return models.NewBatchStatus(batch, tasks), nil
+}
+
+// CancelBatch cancels the tasks of a batch that have not finished yet and
+// returns their ids.
+func (s *BatchService) CancelBatch(ctx context.Context, batchID string) ([]string, error) {
+	cancelled, err := s.repo.CancelBatch(ctx, batchID)
+	if err != nil {
+		logger.Warn("Failed to cancel batch",
+			zap.String("batch_id", batchID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	logger.Info("Batch cancelled successfully",
+		zap.String("batch_id", batchID),
+		zap.Int("cancelled", len(cancelled)),
+	)
+
+	for _, taskID := range cancelled {
+		s.tasks.notifyCancelled(ctx, taskID)
+	}
+	return cancelled, nil
+}
+
+type CreateBatchRequest struct {
+	// Tasks are validated one by one, an invalid task does not fail the batch
+	Tasks []CreateTaskRequest `json:"tasks" binding:"required,min=1"`
+	// Group, when set, makes the created tasks the members of a new group
+	Group *GroupRequest `json:"group"`
+}
+
+type GroupRequest struct {
+	Name string `json:"name"`
+	// OnComplete is created once every member of the group has finished
+	OnComplete *models.TaskTemplate `json:"on_complete"`
+}
+
+func (r *GroupRequest) validate() error {
+	if r.OnComplete == nil {
+		return nil
+	}
+
+	// the template must describe a task the API would accept
+	template := CreateTaskRequest{
+		Type:           r.OnComplete.Type,
+		Payload:        r.OnComplete.Payload,
+		Priority:       r.OnComplete.Priority,
+		MaxRetries:     r.OnComplete.MaxRetries,
+		TimeoutSeconds: r.OnComplete.TimeoutSeconds,
+	}
+	if len(template.Payload) == 0 {
+		return fmt.Errorf("%w: on_complete task payload is required", ErrInvalidBatch)
+	}
+	if err := template.Validate(); err != nil {
+		return fmt.Errorf("%w: on_complete task: %v", ErrInvalidBatch, err)
+	}
+	r.OnComplete.MaxRetries = template.MaxRetries
+	return nil
+}
+
+// validateBatchItem is Validate for a task of a batch, which is not checked
+// by the request binding and cannot use the options that need a task by task
+// insert
+func (r *CreateTaskRequest) validateBatchItem() error {
+	if len(r.Payload) == 0 {
+		return fmt.Errorf("payload is required")
+	}
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if len(r.DependsOn) > 0 || r.IdempotencyKey != "" || r.UniqueKey != "" {
+		return fmt.Errorf("depends_on, idempotency and unique keys are not supported in batches")
+	}
+	return nil
+}
+
+// BatchResult reports what became of each task of a batch request, in order.
+type BatchResult struct {
+	BatchID string            `json:"batch_id,omitempty"`
+	GroupID string            `json:"group_id,omitempty"`
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Results []BatchItemResult `json:"results"`
+}
+
+type BatchItemResult struct {
+	Index  int              `json:"index"`
+	TaskID string           `json:"task_id,omitempty"`
+	State  models.TaskState `json:"state,omitempty"`
+	Error  string           `json:"error,omitempty"`
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"go.uber.org/zap"
+)
+
+type CallbackService struct {
+	repo *repository.CallbackRepository
+}
+
+func NewCallbackService(repo *repository.CallbackRepository) *CallbackService {
+	return &CallbackService{repo: repo}
+}
+
+// ListCallbacks returns the callbacks of a task with their delivery attempts.
+func (s *CallbackService) ListCallbacks(ctx context.Context, taskID string) ([]models.Callback, error) {
+	callbacks, err := s.repo.ListCallbacks(ctx, taskID)
+	if err != nil {
+		logger.Error("Failed to list callbacks",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	return callbacks, nil
+}
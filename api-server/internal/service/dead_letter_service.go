@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"go.uber.org/zap"
+)
+
+// replayReason is recorded in the history of a replayed task when the request
+// gives no reason
+const replayReason = "replayed from dead letters"
+
+type DeadLetterService struct {
+	repo        *repository.DeadLetterRepository
+	historyRepo *repository.HistoryRepository
+	taskRepo    *repository.TaskRepository
+	tasks       *TaskService
+}
+
+func NewDeadLetterService(
+	repo *repository.DeadLetterRepository,
+	historyRepo *repository.HistoryRepository,
+	taskRepo *repository.TaskRepository,
+	tasks *TaskService,
+) *DeadLetterService {
+	return &DeadLetterService{
+		repo:        repo,
+		historyRepo: historyRepo,
+		taskRepo:    taskRepo,
+		tasks:       tasks,
+	}
+}
+
+// ListDeadLetters returns the dead letters selected by filter and how many
+// match it in total.
+func (s *DeadLetterService) ListDeadLetters(ctx context.Context, filter repository.DeadLetterFilter) ([]*models.DeadLetter, int, error) {
+	deadLetters, total, err := s.repo.ListDeadLetters(ctx, filter)
+	if err != nil {
+		logger.Error("Failed to list dead letters",
+			zap.Error(err),
+		)
+		return nil, 0, err
+	}
+	return deadLetters, total, nil
+}
+
+// GetDeadLetter returns the dead letter of a task with the task and its
+// attempts.
+func (s *DeadLetterService) GetDeadLetter(ctx context.Context, taskID string) (*models.DeadLetter, error) {
+	deadLetter, err := s.repo.GetDeadLetter(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadLetter.Task, err = s.taskRepo.GetTaskByID(ctx, taskID); err != nil {
+		return nil, err
+	}
+	history, err := s.historyRepo.GetHistory(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	deadLetter.Attempts = history.Attempts
+	return deadLetter, nil
+}
+
+// ReplayDeadLetter retries a dead-lettered task with all of its retries,
+// which takes it out of the dead letters.
+func (s *DeadLetterService) ReplayDeadLetter(ctx context.Context, taskID string, reason string) (*models.Task, error) {
+	if _, err := s.repo.GetDeadLetter(ctx, taskID); err != nil {
+		return nil, err
+	}
+	if reason == "" {
+		reason = replayReason
+	}
+	return s.tasks.RetryTask(ctx, taskID, RetryTaskRequest{ResetRetries: true, Reason: reason})
+}
+
+// ReplayDeadLetters replays the dead letters selected by filter, at most
+// MaxBulkRetry at once, and returns the ids of the replayed tasks.
+func (s *DeadLetterService) ReplayDeadLetters(ctx context.Context, filter repository.DeadLetterFilter, reason string) ([]string, error) {
+	if filter.Limit <= 0 || filter.Limit > MaxBulkRetry {
+		filter.Limit = MaxBulkRetry
+	}
+	filter.Offset = 0
+	if reason == "" {
+		reason = replayReason
+	}
+	retry := RetryTaskRequest{ResetRetries: true, Reason: reason}
+	if err := retry.validate(); err != nil {
+		return nil, err
+	}
+
+	deadLetters, _, err := s.repo.ListDeadLetters(ctx, filter)
+	if err != nil {
+		logger.Error("Failed to list dead letters to replay",
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	if len(deadLetters) == 0 {
+		return []string{}, nil
+	}
+
+	ids := make([]string, len(deadLetters))
+	for i, deadLetter := range deadLetters {
+		ids[i] = deadLetter.TaskID
+	}
+	retryFilter := repository.RetryFilter{IDs: ids, States: []models.TaskState{models.TaskStateFailed}}
+	replayed, err := s.taskRepo.RetryTasks(ctx, retryFilter, retry.options())
+	if err != nil {
+		logger.Error("Failed to replay dead letters",
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	logger.Info("Dead letters replayed",
+		zap.Int("tasks", len(replayed)),
+	)
+
+	s.tasks.publishAll(ctx, replayed)
+	return replayed, nil
+}
+
+// PurgeDeadLetter drops the dead letter of a task, the task stays failed.
+func (s *DeadLetterService) PurgeDeadLetter(ctx context.Context, taskID string) error {
+	if err := s.repo.DeleteDeadLetter(ctx, taskID); err != nil {
+		return err
+	}
+
+	logger.Info("Dead letter purged",
+		zap.String("task_id", taskID),
+	)
+	return nil
+}
+
+// PurgeDeadLetters drops the dead letters selected by filter and returns how
+// many were dropped.
+func (s *DeadLetterService) PurgeDeadLetters(ctx context.Context, filter repository.DeadLetterFilter) (int64, error) {
+	purged, err := s.repo.DeleteDeadLetters(ctx, filter)
+	if err != nil {
+		logger.Error("Failed to purge dead letters",
+			zap.Error(err),
+		)
+		return 0, err
+	}
+
+	logger.Info("Dead letters purged",
+		zap.Int64("count", purged),
+	)
+	return purged, nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/notify"
+)
+
+// ErrStreamClosed is returned by EventStream.Next once the stream fell behind
+// or lost the database, the client resumes from the last event it received.
+var ErrStreamClosed = errors.New("event stream closed")
+
+// eventPageSize is how many logged events a resumed stream reads at a time
+const eventPageSize = 500
+
+type EventService struct {
+	repo     *repository.EventRepository
+	taskRepo *repository.TaskRepository
+	listener *notify.Listener
+}
+
+func NewEventService(
+	repo *repository.EventRepository,
+	taskRepo *repository.TaskRepository,
+	listener *notify.Listener,
+) *EventService {
+	return &EventService{repo: repo, taskRepo: taskRepo, listener: listener}
+}
+
+// OpenStream starts streaming the events selected by filter as they are
+// logged. Unless lastEventID is negative, the stream first replays the logged
+// events that came after it.
+func (s *EventService) OpenStream(ctx context.Context, filter models.TaskEventFilter, lastEventID int64) (*EventStream, error) {
+	if filter.TaskID != "" {
+		if _, err := s.taskRepo.GetTaskByID(ctx, filter.TaskID); err != nil {
+			return nil, err
+		}
+	}
+
+	// subscribe before reading the log, an event logged in between is in
+	// one or both and the duplicates are skipped
+	live, unsubscribe := s.listener.SubscribeEvents()
+	return &EventStream{
+		repo:        s.repo,
+		filter:      filter,
+		live:        live,
+		unsubscribe: unsubscribe,
+		replaying:   lastEventID >= 0,
+		cursor:      lastEventID,
+	}, nil
+}
+
+// EventStream is a stream of task events, see EventService.OpenStream.
+type EventStream struct {
+	repo        *repository.EventRepository
+	filter      models.TaskEventFilter
+	live        <-chan *models.TaskEvent
+	unsubscribe func()
+
+	// the page of logged events being replayed, cursor is the id of the
+	// last one read, the live events up to it were replayed already
+	replaying bool
+	page      []*models.TaskEvent
+	cursor    int64
+}
+
+// Next returns the next event, or nil when none arrived within wait.
+func (st *EventStream) Next(ctx context.Context, wait time.Duration) (*models.TaskEvent, error) {
+	if st.replaying && len(st.page) == 0 {
+		page, err := st.repo.ListEvents(ctx, st.filter, st.cursor, eventPageSize)
+		if err != nil {
+			return nil, err
+		}
+		st.page = page
+		st.replaying = len(page) == eventPageSize
+	}
+	if len(st.page) > 0 {
+		event := st.page[0]
+		st.page = st.page[1:]
+		st.cursor = event.ID
+		return event, nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case event, ok := <-st.live:
+			if !ok {
+				return nil, ErrStreamClosed
+			}
+			if event.ID <= st.cursor || !st.filter.Matches(event) {
+				continue
+			}
+			return event, nil
+		case <-timer.C:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Close ends the stream.
+func (st *EventStream) Close() {
+	st.unsubscribe()
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"go.uber.org/zap"
+)
+
+type GroupService struct {
+	repo *repository.GroupRepository
+}
+
+func NewGroupService(repo *repository.GroupRepository) *GroupService {
+	return &GroupService{repo: repo}
+}
+
+// GetGroup returns a group with the progress of its members.
+func (s *GroupService) GetGroup(ctx context.Context, groupID string) (*models.GroupStatus, error) {
+	status, err := s.repo.GetGroupStatus(ctx, groupID)
+	if err != nil {
+		logger.Error("Failed to get group",
+			zap.String("group_id", groupID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	return status, nil
+}
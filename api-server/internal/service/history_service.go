@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"go.uber.org/zap"
+)
+
+type HistoryService struct {
+	repo *repository.HistoryRepository
+}
+
+func NewHistoryService(repo *repository.HistoryRepository) *HistoryService {
+	return &HistoryService{repo: repo}
+}
+
+// GetHistory returns the timeline of a task.
+func (s *HistoryService) GetHistory(ctx context.Context, taskID string) (*models.TaskHistory, error) {
+	history, err := s.repo.GetHistory(ctx, taskID)
+	if err != nil {
+		logger.Error("Failed to get task history",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	return history, nil
+}
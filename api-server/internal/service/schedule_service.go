@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidSchedule is returned when a schedule request does not validate.
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+type ScheduleService struct {
+	repo *repository.ScheduleRepository
+}
+
+func NewScheduleService(repo *repository.ScheduleRepository) *ScheduleService {
+	return &ScheduleService{repo: repo}
+}
+
+func (s *ScheduleService) CreateSchedule(ctx context.Context, req ScheduleRequest) (*models.Schedule, error) {
+	schedule := models.NewSchedule(req.Name, req.Cron, req.Timezone, req.Task)
+	if err := req.apply(schedule); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateSchedule(ctx, schedule); err != nil {
+		logger.Error("Failed to create schedule",
+			zap.String("schedule_id", schedule.ID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	logger.Info("Schedule created successfully",
+		zap.String("schedule_id", schedule.ID),
+		zap.String("cron", schedule.CronExpr),
+		zap.String("timezone", schedule.Timezone),
+	)
+	return schedule, nil
+}
+
+func (s *ScheduleService) GetSchedule(ctx context.Context, scheduleID string) (*models.Schedule, error) {
+	schedule, err := s.repo.GetScheduleByID(ctx, scheduleID)
+	if err != nil {
+		logger.Error("Failed to get schedule",
+			zap.String("schedule_id", scheduleID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func (s *ScheduleService) ListSchedules(ctx context.Context, limit, offset int) ([]*models.Schedule, error) {
+	schedules, err := s.repo.ListSchedules(ctx, limit, offset)
+	if err != nil {
+		logger.Error("Failed to list schedules", zap.Error(err))
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// UpdateSchedule replaces the definition of a schedule. Its next run is
+// computed again from now, so ticks that were due but not fired yet are dropped.
+func (s *ScheduleService) UpdateSchedule(ctx context.Context, scheduleID string, req ScheduleRequest) (*models.Schedule, error) {
+	schedule, err := s.repo.GetScheduleByID(ctx, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.Name = req.Name
+	schedule.CronExpr = req.Cron
+	schedule.Timezone = req.Timezone
+	if schedule.Timezone == "" {
+		schedule.Timezone = "UTC"
+	}
+	schedule.Task = req.Task
+	schedule.Enabled = true
+	schedule.OverlapPolicy = models.OverlapAllow
+	schedule.MisfirePolicy = models.MisfireFireOnce
+	schedule.UpdatedAt = time.Now().UTC()
+	if err := req.apply(schedule); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateSchedule(ctx, schedule); err != nil {
+		logger.Error("Failed to update schedule",
+			zap.String("schedule_id", scheduleID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	logger.Info("Schedule updated successfully", zap.String("schedule_id", scheduleID))
+	return schedule, nil
+}
+
+func (s *ScheduleService) DeleteSchedule(ctx context.Context, scheduleID string) error {
+	if err := s.repo.DeleteSchedule(ctx, scheduleID); err != nil {
+		logger.Error("Failed to delete schedule",
+			zap.String("schedule_id", scheduleID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	logger.Info("Schedule deleted successfully", zap.String("schedule_id", scheduleID))
+	return nil
+}
+
+type ScheduleRequest struct {
+	Name     string              `json:"name"`
+	Cron     string              `json:"cron" binding:"required"`
+	Timezone string              `json:"timezone"`
+	Task     models.TaskTemplate `json:"task"`
+	// Enabled defaults to true
+	Enabled       *bool                `json:"enabled"`
+	OverlapPolicy models.OverlapPolicy `json:"overlap_policy"`
+	MisfirePolicy models.MisfirePolicy `json:"misfire_policy"`
+}
+
+// apply copies the optional fields of the request onto the schedule, validates
+// it and computes its next run.
+func (r *ScheduleRequest) apply(schedule *models.Schedule) error {
+	if r.Enabled != nil {
+		schedule.Enabled = *r.Enabled
+	}
+	if r.OverlapPolicy != "" {
+		schedule.OverlapPolicy = r.OverlapPolicy
+	}
+	if r.MisfirePolicy != "" {
+		schedule.MisfirePolicy = r.MisfirePolicy
+	}
+
+	// the template must describe a task the API would accept
+	template := CreateTaskRequest{
+		Type:           r.Task.Type,
+		Payload:        r.Task.Payload,
+		Priority:       r.Task.Priority,
+		MaxRetries:     r.Task.MaxRetries,
+		TimeoutSeconds: r.Task.TimeoutSeconds,
+	}
+	if len(template.Payload) == 0 {
+		return fmt.Errorf("%w: task payload is required", ErrInvalidSchedule)
+	}
+	if err := template.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	schedule.Task.MaxRetries = template.MaxRetries
+
+	if err := schedule.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+
+	schedule.NextRunAt = nil
+	if schedule.Enabled {
+		next, err := schedule.NextRun(time.Now())
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+		}
+		if next.IsZero() {
+			return fmt.Errorf("%w: cron expression %q never fires", ErrInvalidSchedule, schedule.CronExpr)
+		}
+		schedule.NextRunAt = &next
+	}
+	return nil
+}
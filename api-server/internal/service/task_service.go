@@ -2,38 +2,121 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/alaajili/task-scheduler/api-server/internal/repository"
 	"github.com/alaajili/task-scheduler/shared/logger"
 	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/notify"
+	"github.com/alaajili/task-scheduler/shared/outbox"
+	"github.com/alaajili/task-scheduler/shared/queue"
 	"go.uber.org/zap"
 )
 
+// defaultIdempotencyRetention is how long an idempotency key is remembered
+// unless SetIdempotencyRetention says otherwise
+const defaultIdempotencyRetention = 24 * time.Hour
+
+// MaxWaitTimeout bounds how long WaitForTask blocks.
+const MaxWaitTimeout = 5 * time.Minute
+
+// MaxBulkRetry bounds the tasks a bulk retry moves at once.
+const MaxBulkRetry = 1000
+
+// MaxRetryReasonLength bounds the reason recorded with a retry.
+const MaxRetryReasonLength = 1000
+
+// ErrInvalidTask is returned when a task creation request does not validate.
+var ErrInvalidTask = errors.New("invalid task")
+
+// ErrInvalidRetry is returned when a retry request does not validate.
+var ErrInvalidRetry = errors.New("invalid retry")
+
+// ErrInvalidUpdate is returned when a task update does not validate.
+var ErrInvalidUpdate = errors.New("invalid task update")
+
+// waitPollInterval is how often WaitForTask looks at a task when no listener
+// tells it when the task finishes
+const waitPollInterval = time.Second
+
 type TaskService struct {
-	repo *repository.TaskRepository
+	repo                 *repository.TaskRepository
+	relay                *outbox.Relay
+	queue                *queue.RedisQueue
+	idempotencyRetention time.Duration
+	listener             *notify.Listener
+}
+
+func NewTaskService(
+	repo *repository.TaskRepository,
+	relay *outbox.Relay,
+	queue *queue.RedisQueue,
+) *TaskService {
+	return &TaskService{
+		repo:                 repo,
+		relay:                relay,
+		queue:                queue,
+		idempotencyRetention: defaultIdempotencyRetention,
+	}
 }
 
-func NewTaskService(repo *repository.TaskRepository) *TaskService {
-	return &TaskService{repo: repo}
+// SetIdempotencyRetention sets how long an idempotency key keeps returning the
+// task it was first used for
+func (s *TaskService) SetIdempotencyRetention(retention time.Duration) {
+	if retention > 0 {
+		s.idempotencyRetention = retention
+	}
 }
 
-func (s *TaskService) CreateTask(ctx context.Context, req CreateTaskRequest) (*models.Task, error) {
+// SetListener makes WaitForTask wake up on the task finished notifications
+// instead of polling
+func (s *TaskService) SetListener(listener *notify.Listener) {
+	s.listener = listener
+}
+
+// CreateTask creates a task. created is false when an existing task is
+// returned instead: the one a reused idempotency key was first used for, or
+// the one holding the task's unique key under the return_existing and
+// replace conflict policies.
+func (s *TaskService) CreateTask(ctx context.Context, req CreateTaskRequest) (task *models.Task, created bool, err error) {
 	if err := req.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid request: %w", err)
+		return nil, false, fmt.Errorf("%w: %v", ErrInvalidTask, err)
 	}
 
-	task := models.NewTask(req.Type, req.Payload, req.Priority)
-	task.MaxRetries = req.MaxRetries
+	task = req.newTask()
+
+	opts := repository.CreateOptions{OnConflict: req.OnConflict}
+	if req.IdempotencyKey != "" {
+		opts.IdempotencyKey = &repository.IdempotencyKey{
+			Key:         req.IdempotencyKey,
+			RequestHash: req.hash(),
+			Retention:   s.idempotencyRetention,
+		}
+	}
 
 	// Save task to database
-	if err := s.repo.CreateTask(ctx, task); err != nil {
+	existing, err := s.repo.CreateTask(ctx, task, opts)
+	if err != nil {
 		logger.Error("Failed to create task",
 			zap.String("task_id", task.ID),
 			zap.Error(err),
 		)
-		return nil, fmt.Errorf("failed to create task: %w", err)
+		return nil, false, fmt.Errorf("failed to create task: %w", err)
+	}
+	if existing != nil {
+		logger.Info("Returning existing task instead of creating one",
+			zap.String("task_id", existing.ID),
+			zap.String("idempotency_key", req.IdempotencyKey),
+			zap.String("unique_key", req.UniqueKey),
+		)
+		return existing, false, nil
 	}
 
 	logger.Info("Task created successfully",
@@ -42,9 +125,32 @@ func (s *TaskService) CreateTask(ctx context.Context, req CreateTaskRequest) (*m
 		zap.Int("priority", task.Priority),
 	)
 
-	// TODO: publish to the queue (redis queue)
+	s.publish(ctx, task)
 
-	return task, nil
+	return task, true, nil
+}
+
+// PurgeIdempotencyKeys deletes expired idempotency keys every interval until
+// the context is cancelled
+func (s *TaskService) PurgeIdempotencyKeys(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.repo.DeleteExpiredIdempotencyKeys(ctx)
+			if err != nil {
+				logger.Warn("Failed to purge expired idempotency keys", zap.Error(err))
+				continue
+			}
+			if deleted > 0 {
+				logger.Info("Purged expired idempotency keys", zap.Int64("deleted", deleted))
+			}
+		}
+	}
 }
 
 func (s *TaskService) GetTask(ctx context.Context, taskID string) (*models.Task, error) {
@@ -59,6 +165,48 @@ func (s *TaskService) GetTask(ctx context.Context, taskID string) (*models.Task,
 	return task, nil
 }
 
+// WaitForTask blocks until the task has finished or the timeout passes and
+// returns it, finished says which of the two happened.
+func (s *TaskService) WaitForTask(ctx context.Context, taskID string, timeout time.Duration) (task *models.Task, finished bool, err error) {
+	// subscribe before the first look, a task finishing in between still
+	// wakes us up
+	var (
+		wake <-chan struct{}
+		poll <-chan time.Time
+	)
+	if s.listener != nil {
+		ch, unsubscribe := s.listener.Subscribe(taskID)
+		defer unsubscribe()
+		wake = ch
+	} else {
+		ticker := time.NewTicker(waitPollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		task, err := s.GetTask(ctx, taskID)
+		if err != nil {
+			return nil, false, err
+		}
+		if task.IsFinished() {
+			return task, true, nil
+		}
+
+		select {
+		case <-wake:
+		case <-poll:
+		case <-deadline.C:
+			return task, false, nil
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+}
+
 func (s *TaskService) ListTasks(ctx context.Context, filter repository.ListFilters) ([]*models.Task, error) {
 	tasks, err := s.repo.ListTasks(ctx, filter)
 	if err != nil {
@@ -80,33 +228,353 @@ func (s *TaskService) CancelTask(ctx context.Context, taskID string) error {
 		return err
 	}
 
-	if task.State != models.TaskStatePending && task.State != models.TaskStateRunning {
-		logger.Warn("Attempted to cancel a task that is not pending or running",
+	// the update only applies if nobody moved the task since we read it
+	if err := s.repo.TransitionState(ctx, taskID, task.State, models.TaskStateCancelled); err != nil {
+		logger.Warn("Failed to cancel task",
 			zap.String("task_id", taskID),
 			zap.String("state", string(task.State)),
+			zap.Error(err),
 		)
-		return fmt.Errorf("only pending or running tasks can be cancelled")
+		return err
+	}
+
+	logger.Info("Task cancelled successfully",
+		zap.String("task_id", taskID),
+	)
+
+	s.notifyCancelled(ctx, taskID)
+	return nil
+}
+
+// RetryTask moves a failed or terminal task back to pending and publishes it.
+func (s *TaskService) RetryTask(ctx context.Context, taskID string, req RetryTaskRequest) (*models.Task, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
 	}
 
-	if err := s.repo.UpdateTaskState(ctx, taskID, models.TaskStateCancelled); err != nil {
-		logger.Error("Failed to cancel task",
+	task, err := s.repo.RetryTask(ctx, taskID, req.options())
+	if err != nil {
+		logger.Warn("Failed to retry task",
 			zap.String("task_id", taskID),
 			zap.Error(err),
 		)
-		return err
+		return nil, err
 	}
 
-	logger.Info("Task cancelled successfully",
+	logger.Info("Task retried by hand",
 		zap.String("task_id", taskID),
+		zap.Bool("reset_retries", req.ResetRetries),
 	)
+
+	s.publish(ctx, task)
+	return task, nil
+}
+
+// RetryTasks retries the tasks selected by the request, at most
+// MaxBulkRetry at once, and returns their ids.
+func (s *TaskService) RetryTasks(ctx context.Context, req BulkRetryRequest) ([]string, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	filter := repository.RetryFilter{
+		Type:           req.Type,
+		States:         []models.TaskState{req.State},
+		FinishedAfter:  req.FinishedAfter,
+		FinishedBefore: req.FinishedBefore,
+		Limit:          req.Limit,
+	}
+	ids, err := s.repo.RetryTasks(ctx, filter, req.options())
+	if err != nil {
+		logger.Error("Failed to retry tasks",
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	logger.Info("Tasks retried by hand",
+		zap.Int("tasks", len(ids)),
+		zap.String("type", string(req.Type)),
+		zap.String("state", string(req.State)),
+	)
+
+	s.publishAll(ctx, ids)
+	return ids, nil
+}
+
+// UpdateTask changes a task that has not started yet. A queued task is
+// re-scored in the queue before the update commits, so both change together.
+func (s *TaskService) UpdateTask(ctx context.Context, taskID string, req UpdateTaskRequest) (*models.Task, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	task, err := s.repo.UpdateTask(ctx, taskID, req.update(), s.requeue)
+	if err != nil {
+		logger.Warn("Failed to update task",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	logger.Info("Task updated",
+		zap.String("task_id", taskID),
+		zap.String("state", string(task.State)),
+		zap.Int("priority", task.Priority),
+	)
+
+	// a task whose due time moved has a new outbox entry
+	s.publish(ctx, task)
+	return task, nil
+}
+
+// requeue moves an updated task to its new priority and due time in the queue
+func (s *TaskService) requeue(ctx context.Context, before, after *models.Task) error {
+	if s.queue == nil {
+		return nil
+	}
+
+	var dueAt *time.Time
+	if after.State == models.TaskStateScheduled {
+		dueAt = after.NotBefore
+	}
+	return s.queue.RescheduleTask(ctx, after.ID, before.Priority, after.Priority, dueAt, after.QueueUniqueKey())
+}
+
+// notifyCancelled drops a cancelled task from the queue and tells the worker
+// running it, if any, to stop. Failures are only logged: the row is already
+// cancelled, so no worker can start or complete the task anymore.
+func (s *TaskService) notifyCancelled(ctx context.Context, taskID string) {
+	if s.queue == nil {
+		return
+	}
+
+	if err := s.queue.RemoveTask(ctx, taskID); err != nil {
+		logger.Warn("Failed to remove cancelled task from the queue",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+	}
+	if err := s.queue.PublishCancellation(ctx, taskID); err != nil {
+		logger.Warn("Failed to publish task cancellation",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+	}
+}
+
+// publish pushes a freshly committed task to the queue. Failures are not
+// returned to the caller, the outbox relay retries them in the background.
+func (s *TaskService) publish(ctx context.Context, task *models.Task) {
+	if s.relay == nil {
+		return
+	}
+
+	if err := s.relay.PublishTask(ctx, task.ID); err != nil {
+		logger.Warn("Failed to publish task, leaving it to the outbox relay",
+			zap.String("task_id", task.ID),
+			zap.Error(err),
+		)
+	}
+}
+
+// publishAll is publish for many tasks at once
+func (s *TaskService) publishAll(ctx context.Context, taskIDs []string) {
+	if s.relay == nil || len(taskIDs) == 0 {
+		return
+	}
+
+	if err := s.relay.PublishTasks(ctx, taskIDs); err != nil {
+		logger.Warn("Failed to publish tasks, leaving them to the outbox relay",
+			zap.Int("tasks", len(taskIDs)),
+			zap.Error(err),
+		)
+	}
+}
+
+// RetryTaskRequest changes a task as it is retried, every field is optional.
+type RetryTaskRequest struct {
+	// ResetRetries gives the task all of its retries again, otherwise a task
+	// that used them up gets a single run
+	ResetRetries bool `json:"reset_retries"`
+	// Priority and Payload replace the ones of the task
+	Priority *int            `json:"priority"`
+	Payload  json.RawMessage `json:"payload"`
+	// Reason is recorded in the history of the task
+	Reason string `json:"reason"`
+}
+
+func (r *RetryTaskRequest) validate() error {
+	if r.Priority != nil && (*r.Priority < 0 || *r.Priority > 10) {
+		return fmt.Errorf("%w: priority must be between 0 and 10", ErrInvalidRetry)
+	}
+	if len(r.Reason) > MaxRetryReasonLength {
+		return fmt.Errorf("%w: reason must be at most %d characters", ErrInvalidRetry, MaxRetryReasonLength)
+	}
+	if string(r.Payload) == "null" {
+		r.Payload = nil
+	}
+	return nil
+}
+
+// options returns the repository options, the message logged with the
+// retried event lists what was changed
+func (r *RetryTaskRequest) options() repository.RetryOptions {
+	var changes []string
+	if r.ResetRetries {
+		changes = append(changes, "retries reset")
+	}
+	if r.Priority != nil {
+		changes = append(changes, fmt.Sprintf("priority %d", *r.Priority))
+	}
+	if len(r.Payload) > 0 {
+		changes = append(changes, "payload replaced")
+	}
+	message := strings.Join(changes, ", ")
+	if r.Reason != "" && message != "" {
+		message += ": "
+	}
+	message += r.Reason
+
+	return repository.RetryOptions{
+		ResetRetries: r.ResetRetries,
+		Priority:     r.Priority,
+		Payload:      r.Payload,
+		Message:      message,
+	}
+}
+
+// UpdateTaskRequest changes a pending, scheduled or blocked task, every field
+// is optional but one must be set.
+type UpdateTaskRequest struct {
+	Priority   *int `json:"priority"`
+	MaxRetries *int `json:"max_retries"`
+	// RunAt (RFC3339) holds the task back until then, a time in the past
+	// makes it due right away
+	RunAt   *time.Time      `json:"run_at"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+func (r *UpdateTaskRequest) validate() error {
+	if string(r.Payload) == "null" {
+		r.Payload = nil
+	}
+	if r.Priority == nil && r.MaxRetries == nil && r.RunAt == nil && len(r.Payload) == 0 {
+		return fmt.Errorf("%w: nothing to update", ErrInvalidUpdate)
+	}
+	if r.Priority != nil && (*r.Priority < 0 || *r.Priority > 10) {
+		return fmt.Errorf("%w: priority must be between 0 and 10", ErrInvalidUpdate)
+	}
+	if r.MaxRetries != nil && *r.MaxRetries < 0 {
+		return fmt.Errorf("%w: max_retries must not be negative", ErrInvalidUpdate)
+	}
+	if r.RunAt != nil {
+		runAt := r.RunAt.UTC()
+		r.RunAt = &runAt
+	}
 	return nil
 }
 
+// update returns the repository update, the message logged with the updated
+// event lists what was changed
+func (r *UpdateTaskRequest) update() repository.TaskUpdate {
+	var changes []string
+	if r.Priority != nil {
+		changes = append(changes, fmt.Sprintf("priority %d", *r.Priority))
+	}
+	if r.MaxRetries != nil {
+		changes = append(changes, fmt.Sprintf("max_retries %d", *r.MaxRetries))
+	}
+	if r.RunAt != nil {
+		changes = append(changes, "run_at "+r.RunAt.Format(time.RFC3339))
+	}
+	if len(r.Payload) > 0 {
+		changes = append(changes, "payload replaced")
+	}
+
+	return repository.TaskUpdate{
+		Priority:   r.Priority,
+		MaxRetries: r.MaxRetries,
+		RunAt:      r.RunAt,
+		Payload:    r.Payload,
+		Message:    strings.Join(changes, ", "),
+	}
+}
+
+// BulkRetryRequest retries the tasks of a type, or of any type, in a state
+// that finished within a time range. State defaults to failed.
+type BulkRetryRequest struct {
+	Type           models.TaskType  `json:"type"`
+	State          models.TaskState `json:"state"`
+	FinishedAfter  *time.Time       `json:"finished_after"`
+	FinishedBefore *time.Time       `json:"finished_before"`
+	// Limit defaults to and is at most MaxBulkRetry, retrying again picks up
+	// where the previous request stopped
+	Limit        int    `json:"limit"`
+	ResetRetries bool   `json:"reset_retries"`
+	Priority     *int   `json:"priority"`
+	Reason       string `json:"reason"`
+}
+
+func (r *BulkRetryRequest) validate() error {
+	if r.State == "" {
+		r.State = models.TaskStateFailed
+	}
+	if !models.CanRetry(r.State) {
+		return fmt.Errorf("%w: tasks in state %s cannot be retried", ErrInvalidRetry, r.State)
+	}
+	if r.FinishedAfter != nil && r.FinishedBefore != nil && !r.FinishedAfter.Before(*r.FinishedBefore) {
+		return fmt.Errorf("%w: finished_after must be before finished_before", ErrInvalidRetry)
+	}
+	if r.Limit < 0 || r.Limit > MaxBulkRetry {
+		return fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidRetry, MaxBulkRetry)
+	}
+	if r.Limit == 0 {
+		r.Limit = MaxBulkRetry
+	}
+	return r.retry().validate()
+}
+
+// retry returns the changes applied to every task
+func (r *BulkRetryRequest) retry() *RetryTaskRequest {
+	return &RetryTaskRequest{ResetRetries: r.ResetRetries, Priority: r.Priority, Reason: r.Reason}
+}
+
+func (r *BulkRetryRequest) options() repository.RetryOptions {
+	return r.retry().options()
+}
+
 type CreateTaskRequest struct {
 	Type       models.TaskType `json:"type" binding:"required"`
 	Payload    json.RawMessage `json:"payload" binding:"required"`
 	Priority   int             `json:"priority"`
 	MaxRetries int             `json:"max_retries"`
+	// TimeoutSeconds overrides the worker's timeout for this task, 0 keeps it
+	TimeoutSeconds int `json:"timeout_seconds"`
+	// RunAt (RFC3339) or Delay (e.g. "90s") hold the task back until it is due
+	RunAt *time.Time `json:"run_at"`
+	Delay string     `json:"delay"`
+	// DependsOn holds the task back until these tasks have finished,
+	// FailurePolicy (fail, skip or continue) applies when one of them fails
+	DependsOn     []string             `json:"depends_on"`
+	FailurePolicy models.FailurePolicy `json:"failure_policy"`
+	// IdempotencyKey makes retries of the same request return the task it
+	// created, the Idempotency-Key header takes precedence over it
+	IdempotencyKey string `json:"idempotency_key"`
+	// UniqueKey keeps another task of the same type with the same key from
+	// being created while this one holds it, UniqueScope (pending,
+	// pending_or_running or window) says how long. UniqueFor (e.g. "10m") is
+	// the length of a window, OnConflict (reject, return_existing or replace)
+	// what happens to a task whose key is held.
+	UniqueKey   string                `json:"unique_key"`
+	UniqueScope models.UniqueScope    `json:"unique_scope"`
+	UniqueFor   string                `json:"unique_for"`
+	OnConflict  models.ConflictPolicy `json:"on_conflict"`
+	// CallbackURL is posted an event when the task completes, fails for good
+	// or is cancelled, signed with CallbackSecret if set
+	CallbackURL    string `json:"callback_url"`
+	CallbackSecret string `json:"callback_secret"`
 }
 
 func (r *CreateTaskRequest) Validate() error {
@@ -126,5 +594,118 @@ func (r *CreateTaskRequest) Validate() error {
 	if r.MaxRetries < 0 {
 		r.MaxRetries = 3 // Default to 3 retries
 	}
+	if r.TimeoutSeconds < 0 {
+		return fmt.Errorf("timeout_seconds must not be negative")
+	}
+	if r.RunAt != nil && r.Delay != "" {
+		return fmt.Errorf("run_at and delay are mutually exclusive")
+	}
+	if r.Delay != "" {
+		delay, err := time.ParseDuration(r.Delay)
+		if err != nil {
+			return fmt.Errorf("invalid delay: %w", err)
+		}
+		if delay < 0 {
+			return fmt.Errorf("delay must not be negative")
+		}
+	}
+	if r.FailurePolicy != "" && !r.FailurePolicy.Valid() {
+		return fmt.Errorf("invalid failure policy: %s", r.FailurePolicy)
+	}
+	if len(r.IdempotencyKey) > 255 {
+		return fmt.Errorf("idempotency key must be at most 255 characters")
+	}
+	if err := r.validateCallback(); err != nil {
+		return err
+	}
+	return r.validateUniqueness()
+}
+
+func (r *CreateTaskRequest) validateCallback() error {
+	if r.CallbackURL == "" {
+		if r.CallbackSecret != "" {
+			return fmt.Errorf("callback_secret requires a callback_url")
+		}
+		return nil
+	}
+	u, err := url.Parse(r.CallbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("callback_url must be an absolute http or https url")
+	}
+	return nil
+}
+
+func (r *CreateTaskRequest) validateUniqueness() error {
+	if r.UniqueKey == "" {
+		if r.UniqueScope != "" || r.UniqueFor != "" || r.OnConflict != "" {
+			return fmt.Errorf("unique_scope, unique_for and on_conflict require a unique_key")
+		}
+		return nil
+	}
+	if len(r.UniqueKey) > 255 {
+		return fmt.Errorf("unique key must be at most 255 characters")
+	}
+	if r.UniqueScope != "" && !r.UniqueScope.Valid() {
+		return fmt.Errorf("invalid unique scope: %s", r.UniqueScope)
+	}
+	if r.OnConflict != "" && !r.OnConflict.Valid() {
+		return fmt.Errorf("invalid conflict policy: %s", r.OnConflict)
+	}
+
+	if r.UniqueScope != models.UniqueScopeWindow {
+		if r.UniqueFor != "" {
+			return fmt.Errorf("unique_for only applies to the window scope")
+		}
+		return nil
+	}
+	window, err := time.ParseDuration(r.UniqueFor)
+	if err != nil {
+		return fmt.Errorf("invalid unique_for: %w", err)
+	}
+	if window <= 0 {
+		return fmt.Errorf("unique_for must be positive")
+	}
 	return nil
 }
+
+// hash identifies the request an idempotency key came with, the key itself
+// and the way it was sent are left out
+func (r CreateTaskRequest) hash() string {
+	r.IdempotencyKey = ""
+	// a struct of plain fields always marshals
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// newTask builds the task a validated request asks for
+func (r *CreateTaskRequest) newTask() *models.Task {
+	task := models.NewTask(r.Type, r.Payload, r.Priority)
+	task.MaxRetries = r.MaxRetries
+	task.TimeoutSeconds = r.TimeoutSeconds
+	if runAt, ok := r.RunTime(); ok {
+		task.ScheduleAt(runAt)
+	}
+	task.BlockOn(r.DependsOn, r.FailurePolicy)
+
+	if r.UniqueKey != "" {
+		window, _ := time.ParseDuration(r.UniqueFor)
+		task.MakeUnique(r.UniqueKey, r.UniqueScope, window)
+	}
+	task.CallbackURL = r.CallbackURL
+	task.CallbackSecret = r.CallbackSecret
+	return task
+}
+
+// RunTime returns when the task should run, ok is false when it was not
+// scheduled. Call it on a validated request.
+func (r *CreateTaskRequest) RunTime() (runAt time.Time, ok bool) {
+	if r.RunAt != nil {
+		return *r.RunAt, true
+	}
+	if r.Delay != "" {
+		delay, _ := time.ParseDuration(r.Delay)
+		return time.Now().Add(delay), true
+	}
+	return time.Time{}, false
+}
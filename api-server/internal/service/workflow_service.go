@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alaajili/task-scheduler/api-server/internal/repository"
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidWorkflow is returned when a workflow request does not validate.
+var ErrInvalidWorkflow = errors.New("invalid workflow")
+
+type WorkflowService struct {
+	repo     *repository.WorkflowRepository
+	taskRepo *repository.TaskRepository
+	tasks    *TaskService
+}
+
+func NewWorkflowService(
+	repo *repository.WorkflowRepository,
+	taskRepo *repository.TaskRepository,
+	tasks *TaskService,
+) *WorkflowService {
+	return &WorkflowService{repo: repo, taskRepo: taskRepo, tasks: tasks}
+}
+
+// CreateWorkflow validates the DAG of a workflow request and creates all of
+// its tasks at once. The tasks without dependencies are published right away,
+// the others stay blocked until the tasks they depend on finish.
+func (s *WorkflowService) CreateWorkflow(ctx context.Context, req CreateWorkflowRequest) (*models.WorkflowStatus, error) {
+	order, err := req.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	wf := models.NewWorkflow(req.Name, req.FailurePolicy)
+
+	byKey := make(map[string]*WorkflowTaskRequest, len(req.Tasks))
+	for i := range req.Tasks {
+		byKey[req.Tasks[i].Key] = &req.Tasks[i]
+	}
+
+	ids := make(map[string]string, len(order))
+	tasks := make([]*models.Task, 0, len(order))
+	for _, key := range order {
+		taskReq := byKey[key]
+
+		task := models.NewTask(taskReq.Type, taskReq.Payload, taskReq.Priority)
+		task.MaxRetries = taskReq.MaxRetries
+		task.TimeoutSeconds = taskReq.TimeoutSeconds
+		task.WorkflowID = wf.ID
+		task.WorkflowKey = key
+		task.CallbackURL = taskReq.CallbackURL
+		task.CallbackSecret = taskReq.CallbackSecret
+		if runAt, ok := taskReq.RunTime(); ok {
+			task.ScheduleAt(runAt)
+		}
+
+		dependsOn := make([]string, len(taskReq.DependsOn))
+		for i, dep := range taskReq.DependsOn {
+			dependsOn[i] = ids[dep]
+		}
+		policy := taskReq.FailurePolicy
+		if policy == "" {
+			policy = wf.FailurePolicy
+		}
+		task.BlockOn(dependsOn, policy)
+
+		ids[key] = task.ID
+		tasks = append(tasks, task)
+	}
+
+	if err := s.repo.CreateWorkflow(ctx, wf, tasks); err != nil {
+		logger.Error("Failed to create workflow",
+			zap.String("workflow_id", wf.ID),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("failed to create workflow: %w", err)
+	}
+
+	logger.Info("Workflow created successfully",
+		zap.String("workflow_id", wf.ID),
+		zap.Int("tasks", len(tasks)),
+	)
+
+	for _, task := range tasks {
+		if task.State != models.TaskStateBlocked {
+			s.tasks.publish(ctx, task)
+		}
+	}
+
+	return models.NewWorkflowStatus(wf, tasks), nil
+}
+
+// GetWorkflow returns a workflow with the current state of its tasks.
+func (s *WorkflowService) GetWorkflow(ctx context.Context, workflowID string) (*models.WorkflowStatus, error) {
+	wf, err := s.repo.GetWorkflowByID(ctx, workflowID)
+	if err != nil {
+		logger.Error("Failed to get workflow",
+			zap.String("workflow_id", workflowID),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+
+	tasks, err := s.taskRepo.ListTasks(ctx, repository.ListFilters{WorkflowID: workflowID})
+	if err != nil {
+		return nil, err
+	}
+	deps, err := s.repo.GetWorkflowDependencies(ctx, workflowID)
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		task.DependsOn = deps[task.ID]
+	}
+
+	return models.NewWorkflowStatus(wf, tasks), nil
+}
+
+type CreateWorkflowRequest struct {
+	Name string `json:"name"`
+	// FailurePolicy applies to every task that does not set its own
+	FailurePolicy models.FailurePolicy  `json:"failure_policy"`
+	Tasks         []WorkflowTaskRequest `json:"tasks" binding:"required,min=1,dive"`
+}
+
+// WorkflowTaskRequest is a task of a workflow. Key names the task within the
+// workflow, DependsOn refers to the keys of other tasks of the same workflow.
+type WorkflowTaskRequest struct {
+	Key string `json:"key" binding:"required"`
+	CreateTaskRequest
+}
+
+// validate checks every task and the dependency graph, and returns the task
+// keys in an order where each task comes after the ones it depends on
+func (r *CreateWorkflowRequest) validate() ([]string, error) {
+	if r.FailurePolicy != "" && !r.FailurePolicy.Valid() {
+		return nil, fmt.Errorf("%w: invalid failure policy: %s", ErrInvalidWorkflow, r.FailurePolicy)
+	}
+
+	graph := make(map[string][]string, len(r.Tasks))
+	for i := range r.Tasks {
+		task := &r.Tasks[i]
+		if _, ok := graph[task.Key]; ok {
+			return nil, fmt.Errorf("%w: duplicate task key %s", ErrInvalidWorkflow, task.Key)
+		}
+		if err := task.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: task %s: %v", ErrInvalidWorkflow, task.Key, err)
+		}
+		if task.IdempotencyKey != "" || task.UniqueKey != "" {
+			return nil, fmt.Errorf("%w: task %s: idempotency and unique keys are not supported in workflows",
+				ErrInvalidWorkflow, task.Key)
+		}
+		graph[task.Key] = task.DependsOn
+	}
+
+	order, err := models.TopologicalOrder(graph)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidWorkflow, err)
+	}
+	return order, nil
+}
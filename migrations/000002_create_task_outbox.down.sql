@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_task_outbox_task_id;
+DROP INDEX IF EXISTS idx_task_outbox_created_at;
+DROP TABLE IF EXISTS task_outbox;
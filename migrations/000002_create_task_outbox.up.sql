@@ -0,0 +1,16 @@
+-- Create task outbox table
+-- Rows are written in the same transaction as the task they point at and are
+-- removed once the task has been published to the redis queue.
+CREATE TABLE IF NOT EXISTS task_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    task_id VARCHAR(36) NOT NULL,
+    priority INTEGER NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_outbox_task FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+);
+
+-- Relay drains the outbox in insertion order
+CREATE INDEX idx_task_outbox_created_at ON task_outbox(created_at ASC);
+CREATE INDEX idx_task_outbox_task_id ON task_outbox(task_id);
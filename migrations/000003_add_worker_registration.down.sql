@@ -0,0 +1,7 @@
+ALTER TABLE workers
+    DROP COLUMN IF EXISTS current_task_ids,
+    DROP COLUMN IF EXISTS version,
+    DROP COLUMN IF EXISTS host;
+
+ALTER TABLE workers ADD COLUMN current_task_id VARCHAR(36);
+ALTER TABLE workers ADD CONSTRAINT fk_current_task FOREIGN KEY (current_task_id) REFERENCES tasks(id);
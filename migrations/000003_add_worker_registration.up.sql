@@ -0,0 +1,8 @@
+-- Workers run several tasks at once, so they report a list of task ids
+ALTER TABLE workers DROP CONSTRAINT IF EXISTS fk_current_task;
+ALTER TABLE workers DROP COLUMN IF EXISTS current_task_id;
+
+ALTER TABLE workers
+    ADD COLUMN host VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN version VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN current_task_ids TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,2 @@
+ALTER TABLE tasks DROP COLUMN IF EXISTS error_reason;
+ALTER TABLE tasks DROP COLUMN IF EXISTS timeout_seconds;
@@ -0,0 +1,7 @@
+-- Per-task execution timeout, 0 falls back to the worker defaults
+ALTER TABLE tasks
+    ADD COLUMN timeout_seconds INTEGER NOT NULL DEFAULT 0 CHECK (timeout_seconds >= 0);
+
+-- Why the last attempt failed, e.g. handler_error or timed_out
+ALTER TABLE tasks
+    ADD COLUMN error_reason VARCHAR(32);
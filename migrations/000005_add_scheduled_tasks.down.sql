@@ -0,0 +1,10 @@
+ALTER TABLE task_outbox DROP COLUMN IF EXISTS not_before;
+
+DROP INDEX IF EXISTS idx_tasks_scheduled;
+
+UPDATE tasks SET state = 'pending' WHERE state = 'scheduled';
+ALTER TABLE tasks DROP COLUMN IF EXISTS not_before;
+
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS valid_state;
+ALTER TABLE tasks
+    ADD CONSTRAINT valid_state CHECK (state IN ('pending', 'running', 'completed', 'failed', 'cancelled'));
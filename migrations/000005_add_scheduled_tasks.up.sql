@@ -0,0 +1,15 @@
+-- Tasks submitted with run_at or delay wait in the scheduled state until due
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS valid_state;
+ALTER TABLE tasks
+    ADD CONSTRAINT valid_state CHECK (state IN ('pending', 'scheduled', 'running', 'completed', 'failed', 'cancelled'));
+
+ALTER TABLE tasks
+    ADD COLUMN not_before TIMESTAMP;
+
+-- Workers polling the database pick up scheduled tasks once they are due
+CREATE INDEX idx_tasks_scheduled ON tasks(not_before ASC)
+WHERE state = 'scheduled';
+
+-- The relay publishes entries with a not_before to the delayed queue
+ALTER TABLE task_outbox
+    ADD COLUMN not_before TIMESTAMP;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_schedules_due;
+DROP TABLE IF EXISTS schedules;
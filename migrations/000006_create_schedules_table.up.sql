@@ -0,0 +1,26 @@
+-- Cron schedules, the scheduler creates a task from the template on every tick
+CREATE TABLE IF NOT EXISTS schedules (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    cron_expr VARCHAR(255) NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    task_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 5 CHECK (priority >= 0 AND priority <= 10),
+    max_retries INTEGER NOT NULL DEFAULT 3,
+    timeout_seconds INTEGER NOT NULL DEFAULT 0 CHECK (timeout_seconds >= 0),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    overlap_policy VARCHAR(20) NOT NULL DEFAULT 'allow',
+    misfire_policy VARCHAR(20) NOT NULL DEFAULT 'fire_once',
+    next_run_at TIMESTAMP,
+    last_run_at TIMESTAMP,
+    last_task_id VARCHAR(36),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT valid_overlap_policy CHECK (overlap_policy IN ('allow', 'skip')),
+    CONSTRAINT valid_misfire_policy CHECK (misfire_policy IN ('fire_once', 'fire_all', 'skip'))
+);
+
+-- The scheduler polls for enabled schedules that are due
+CREATE INDEX idx_schedules_due ON schedules(next_run_at ASC)
+WHERE enabled;
@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS task_dependencies;
+
+DROP INDEX IF EXISTS idx_tasks_workflow_id;
+
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS valid_task_failure_policy;
+ALTER TABLE tasks DROP COLUMN IF EXISTS workflow_key;
+ALTER TABLE tasks DROP COLUMN IF EXISTS workflow_id;
+ALTER TABLE tasks DROP COLUMN IF EXISTS failure_policy;
+
+UPDATE tasks SET state = 'pending' WHERE state = 'blocked';
+UPDATE tasks SET state = 'cancelled' WHERE state = 'skipped';
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS valid_state;
+ALTER TABLE tasks
+    ADD CONSTRAINT valid_state CHECK (state IN ('pending', 'scheduled', 'running', 'completed', 'failed', 'cancelled'));
+
+DROP TABLE IF EXISTS workflows;
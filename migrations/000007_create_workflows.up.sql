@@ -0,0 +1,34 @@
+-- Workflows group tasks that are submitted together as a DAG
+CREATE TABLE IF NOT EXISTS workflows (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    failure_policy VARCHAR(20) NOT NULL DEFAULT 'fail',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT valid_failure_policy CHECK (failure_policy IN ('fail', 'skip', 'continue'))
+);
+
+-- Tasks with dependencies wait in the blocked state, the ones whose
+-- dependencies failed end up skipped under the skip policy
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS valid_state;
+ALTER TABLE tasks
+    ADD CONSTRAINT valid_state CHECK (state IN ('pending', 'scheduled', 'blocked', 'running', 'completed', 'failed', 'cancelled', 'skipped'));
+
+ALTER TABLE tasks
+    ADD COLUMN failure_policy VARCHAR(20) NOT NULL DEFAULT 'fail',
+    ADD COLUMN workflow_id VARCHAR(36) REFERENCES workflows(id) ON DELETE CASCADE,
+    ADD COLUMN workflow_key VARCHAR(255),
+    ADD CONSTRAINT valid_task_failure_policy CHECK (failure_policy IN ('fail', 'skip', 'continue'));
+
+CREATE INDEX idx_tasks_workflow_id ON tasks(workflow_id)
+WHERE workflow_id IS NOT NULL;
+
+-- task_id runs once every depends_on task has finished
+CREATE TABLE IF NOT EXISTS task_dependencies (
+    task_id VARCHAR(36) NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    depends_on VARCHAR(36) NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    PRIMARY KEY (task_id, depends_on),
+    CONSTRAINT no_self_dependency CHECK (task_id <> depends_on)
+);
+
+-- A finishing task looks up the tasks waiting for it
+CREATE INDEX idx_task_dependencies_depends_on ON task_dependencies(depends_on);
@@ -0,0 +1,12 @@
+-- Idempotency keys map a client supplied key to the task its first request
+-- created, until they expire
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key VARCHAR(255) PRIMARY KEY,
+    request_hash CHAR(64) NOT NULL,
+    task_id VARCHAR(36) NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Expired keys are purged in the background
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at ASC);
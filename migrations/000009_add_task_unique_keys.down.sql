@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_task_outbox_next_attempt_at;
+
+ALTER TABLE task_outbox DROP COLUMN IF EXISTS next_attempt_at;
+
+DROP INDEX IF EXISTS idx_tasks_unique_key;
+
+ALTER TABLE tasks DROP CONSTRAINT IF EXISTS valid_unique_scope;
+ALTER TABLE tasks DROP COLUMN IF EXISTS unique_until;
+ALTER TABLE tasks DROP COLUMN IF EXISTS unique_scope;
+ALTER TABLE tasks DROP COLUMN IF EXISTS unique_key;
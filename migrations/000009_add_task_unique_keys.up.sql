@@ -0,0 +1,20 @@
+-- A task with a unique_key conflicts with new tasks of the same type and key
+-- while it holds the key, see unique_scope. unique_until ends a window scope.
+ALTER TABLE tasks
+    ADD COLUMN unique_key VARCHAR(255),
+    ADD COLUMN unique_scope VARCHAR(20),
+    ADD COLUMN unique_until TIMESTAMP,
+    ADD CONSTRAINT valid_unique_scope CHECK (unique_scope IN ('pending', 'pending_or_running', 'window'));
+
+-- Creating a unique task looks up the tasks holding its key
+CREATE INDEX idx_tasks_unique_key ON tasks(type, unique_key)
+WHERE unique_key IS NOT NULL;
+
+-- Entries whose unique key is held by another queued task are retried with a
+-- backoff, the relay skips them until next_attempt_at so they do not hold up
+-- the rest of the outbox
+ALTER TABLE task_outbox
+    ADD COLUMN next_attempt_at TIMESTAMP;
+
+CREATE INDEX idx_task_outbox_next_attempt_at ON task_outbox(next_attempt_at)
+WHERE next_attempt_at IS NOT NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_tasks_batch_id;
+
+ALTER TABLE tasks DROP COLUMN IF EXISTS batch_id;
+
+DROP TABLE IF EXISTS task_batches;
@@ -0,0 +1,13 @@
+-- Batches group the tasks submitted together through POST /tasks/batch, so
+-- they can be queried and cancelled as a whole
+CREATE TABLE IF NOT EXISTS task_batches (
+    id VARCHAR(36) PRIMARY KEY,
+    size INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE tasks
+    ADD COLUMN batch_id VARCHAR(36) REFERENCES task_batches(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_tasks_batch_id ON tasks(batch_id)
+WHERE batch_id IS NOT NULL;
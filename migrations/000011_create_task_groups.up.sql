@@ -0,0 +1,16 @@
+-- Groups track a set of tasks until all of them have finished, on_complete
+-- is the template of the task created exactly once at that point
+CREATE TABLE IF NOT EXISTS task_groups (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    on_complete JSONB,
+    on_complete_task_id VARCHAR(36) REFERENCES tasks(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP
+);
+
+ALTER TABLE tasks
+    ADD COLUMN group_id VARCHAR(36) REFERENCES task_groups(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_tasks_group_id ON tasks(group_id)
+WHERE group_id IS NOT NULL;
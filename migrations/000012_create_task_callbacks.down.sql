@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS task_callback_attempts;
+
+DROP TABLE IF EXISTS task_callbacks;
+
+ALTER TABLE tasks
+    DROP COLUMN IF EXISTS callback_secret,
+    DROP COLUMN IF EXISTS callback_url;
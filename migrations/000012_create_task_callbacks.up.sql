@@ -0,0 +1,38 @@
+ALTER TABLE tasks
+    ADD COLUMN callback_url TEXT,
+    ADD COLUMN callback_secret TEXT;
+
+-- A callback is an event waiting to be delivered to the callback url of its
+-- task, it is inserted in the transaction that finishes the task
+CREATE TABLE IF NOT EXISTS task_callbacks (
+    id BIGSERIAL PRIMARY KEY,
+    task_id VARCHAR(36) NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    event VARCHAR(50) NOT NULL,
+    url TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    state VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP,
+
+    CONSTRAINT valid_callback_state CHECK (state IN ('pending', 'delivered', 'failed'))
+);
+
+CREATE INDEX idx_task_callbacks_task_id ON task_callbacks(task_id);
+
+CREATE INDEX idx_task_callbacks_due ON task_callbacks(next_attempt_at)
+WHERE state = 'pending';
+
+-- every delivery attempt, successful or not
+CREATE TABLE IF NOT EXISTS task_callback_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    callback_id BIGINT NOT NULL REFERENCES task_callbacks(id) ON DELETE CASCADE,
+    attempt INT NOT NULL,
+    status_code INT,
+    error TEXT,
+    duration_ms INT NOT NULL,
+    attempted_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_task_callback_attempts_callback_id ON task_callback_attempts(callback_id);
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS tasks_record_event ON tasks;
+
+DROP FUNCTION IF EXISTS record_task_event();
+
+DROP TABLE IF EXISTS task_events;
+
+DROP FUNCTION IF EXISTS notify_task_event();
@@ -0,0 +1,54 @@
+-- Task events log what happened to tasks, they back the event streams and let
+-- a client that reconnects resume after the last event it saw
+CREATE TABLE IF NOT EXISTS task_events (
+    id BIGSERIAL PRIMARY KEY,
+    task_id VARCHAR(36) NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    task_type VARCHAR(50) NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    state VARCHAR(20) NOT NULL,
+    previous_state VARCHAR(20),
+    worker_id VARCHAR(255),
+    progress DOUBLE PRECISION,
+    message TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_task_events_task_id ON task_events(task_id, id);
+
+-- Tasks change state from the api server, the workers and the scheduler, a
+-- trigger records every transition and worker assignment whichever updates
+-- the row
+CREATE OR REPLACE FUNCTION record_task_event() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' OR NEW.state IS DISTINCT FROM OLD.state THEN
+        INSERT INTO task_events (task_id, task_type, type, state, previous_state, worker_id)
+        VALUES (
+            NEW.id, NEW.type, 'state_changed', NEW.state,
+            CASE WHEN TG_OP = 'UPDATE' THEN OLD.state END,
+            NEW.worker_id
+        );
+    END IF;
+    IF TG_OP = 'UPDATE' AND NEW.worker_id IS NOT NULL AND NEW.worker_id IS DISTINCT FROM OLD.worker_id THEN
+        INSERT INTO task_events (task_id, task_type, type, state, worker_id)
+        VALUES (NEW.id, NEW.type, 'worker_assigned', NEW.state, NEW.worker_id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tasks_record_event
+AFTER INSERT OR UPDATE OF state, worker_id ON tasks
+FOR EACH ROW EXECUTE FUNCTION record_task_event();
+
+-- Every event id is sent to the listeners once its transaction commits, they
+-- read the event itself as a notification payload is limited to 8000 bytes
+CREATE OR REPLACE FUNCTION notify_task_event() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('task_events', NEW.id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER task_events_notify
+AFTER INSERT ON task_events
+FOR EACH ROW EXECUTE FUNCTION notify_task_event();
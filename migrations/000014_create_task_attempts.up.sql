@@ -0,0 +1,23 @@
+-- An attempt is one run of a task by a worker, from the moment the worker
+-- starts it until it completes, fails, is cancelled, released or lost
+CREATE TABLE IF NOT EXISTS task_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    task_id VARCHAR(36) NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    attempt INT NOT NULL,
+    worker_id VARCHAR(255),
+    state VARCHAR(20) NOT NULL DEFAULT 'running',
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP,
+    duration_ms BIGINT,
+    error TEXT,
+    error_reason VARCHAR(50),
+    result_size INT,
+    -- set when the failed attempt is retried after a delay
+    retry_at TIMESTAMP,
+
+    CONSTRAINT unique_task_attempt UNIQUE (task_id, attempt),
+    CONSTRAINT valid_attempt_state CHECK (state IN ('running', 'completed', 'failed', 'cancelled', 'released', 'lost'))
+);
+
+CREATE INDEX idx_task_attempts_open ON task_attempts(task_id)
+WHERE finished_at IS NULL;
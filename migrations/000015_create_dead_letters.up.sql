@@ -0,0 +1,15 @@
+-- A dead letter is a task that failed for good: it ran out of retries, failed
+-- in a way retrying cannot help or its dependency did not complete. It is
+-- inserted in the transaction that fails the task and removed when the task is
+-- retried or the dead letter purged.
+CREATE TABLE IF NOT EXISTS dead_letters (
+    task_id VARCHAR(36) PRIMARY KEY REFERENCES tasks(id) ON DELETE CASCADE,
+    task_type VARCHAR(50) NOT NULL,
+    error TEXT,
+    error_reason VARCHAR(50),
+    retry_count INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_dead_letters_created_at ON dead_letters(created_at);
+CREATE INDEX idx_dead_letters_task_type ON dead_letters(task_type);
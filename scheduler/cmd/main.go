@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/alaajili/task-scheduler/scheduler/internal/repository"
+	"github.com/alaajili/task-scheduler/scheduler/internal/service"
+	"github.com/alaajili/task-scheduler/shared/config"
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/metrics"
+	"github.com/alaajili/task-scheduler/shared/outbox"
+	"github.com/alaajili/task-scheduler/shared/queue"
+	"go.uber.org/zap"
+)
+
+func main() {
+	if err := logger.Init("development"); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer logger.Sync()
+
+	cfg, err := config.LoadConfig("../config.yaml")
+	if err != nil {
+		logger.Fatal("Failed to load config", zap.Error(err))
+	}
+
+	db, err := database.NewPostgresDB(cfg.Database)
+	if err != nil {
+		logger.Fatal("Failed to connect to the database", zap.Error(err))
+	}
+	defer db.Close()
+
+	rq, err := queue.NewRedisQueue(cfg.Redis)
+	if err != nil {
+		logger.Fatal("Failed to connect to redis", zap.Error(err))
+	}
+	defer rq.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// fired tasks are published right away, the api server's relay picks up
+	// whatever this one fails to publish
+	relay := outbox.NewRelay(db, rq, cfg.Outbox)
+	scheduler := service.NewScheduler(repository.NewScheduleRepository(db), relay, cfg.Scheduler)
+	go scheduler.Run(ctx)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	metricsServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Scheduler.MetricsPort),
+		Handler: mux,
+	}
+	go func() {
+		if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Metrics server failed", zap.Error(err))
+		}
+	}()
+	defer metricsServer.Close()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-sigChan
+	logger.Info("Received Shutdown signal", zap.String("signal", sig.String()))
+
+	cancel()
+	logger.Info("Scheduler exiting")
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/taskstore"
+)
+
+// DueSchedule is a schedule whose next run has passed, together with the task
+// its previous firing created
+type DueSchedule struct {
+	Schedule *models.Schedule
+	// LastTask only has its id, state and retries set, nil when there is no
+	// previous task
+	LastTask *models.Task
+}
+
+type ScheduleRepository struct {
+	db *database.DB
+}
+
+func NewScheduleRepository(db *database.DB) *ScheduleRepository {
+	return &ScheduleRepository{db: db}
+}
+
+func (r *ScheduleRepository) DB() *database.DB {
+	return r.db
+}
+
+// GetDueSchedules returns the enabled schedules whose next run is at or before
+// now, the most overdue first
+func (r *ScheduleRepository) GetDueSchedules(ctx context.Context, now time.Time, limit int) ([]*DueSchedule, error) {
+	query := `
+		SELECT s.id, s.name, s.cron_expr, s.timezone, s.task_type, s.payload,
+		       s.priority, s.max_retries, s.timeout_seconds, s.enabled,
+		       s.overlap_policy, s.misfire_policy, s.next_run_at, s.last_run_at,
+		       s.last_task_id, s.created_at, s.updated_at,
+		       t.state, t.retry_count, t.max_retries
+		FROM schedules s
+		LEFT JOIN tasks t ON t.id = s.last_task_id
+		WHERE s.enabled
+		  AND s.next_run_at <= $1
+		ORDER BY s.next_run_at ASC
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var due []*DueSchedule
+	for rows.Next() {
+		var (
+			schedule   models.Schedule
+			nextRunAt  sql.NullTime
+			lastRunAt  sql.NullTime
+			lastTaskID sql.NullString
+			lastState  sql.NullString
+			lastRetry  sql.NullInt64
+			lastMax    sql.NullInt64
+		)
+
+		err := rows.Scan(
+			&schedule.ID, &schedule.Name, &schedule.CronExpr, &schedule.Timezone,
+			&schedule.Task.Type, &schedule.Task.Payload, &schedule.Task.Priority,
+			&schedule.Task.MaxRetries, &schedule.Task.TimeoutSeconds, &schedule.Enabled,
+			&schedule.OverlapPolicy, &schedule.MisfirePolicy,
+			&nextRunAt, &lastRunAt, &lastTaskID, &schedule.CreatedAt, &schedule.UpdatedAt,
+			&lastState, &lastRetry, &lastMax,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+
+		if nextRunAt.Valid {
+			schedule.NextRunAt = &nextRunAt.Time
+		}
+		if lastRunAt.Valid {
+			schedule.LastRunAt = &lastRunAt.Time
+		}
+		if lastTaskID.Valid {
+			schedule.LastTaskID = lastTaskID.String
+		}
+
+		var lastTask *models.Task
+		if lastState.Valid {
+			lastTask = &models.Task{
+				ID:         schedule.LastTaskID,
+				State:      models.TaskState(lastState.String),
+				RetryCount: int(lastRetry.Int64),
+				MaxRetries: int(lastMax.Int64),
+			}
+		}
+
+		due = append(due, &DueSchedule{
+			Schedule: &schedule,
+			LastTask: lastTask,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read due schedules: %w", err)
+	}
+
+	return due, nil
+}
+
+// FireSchedule moves the schedule on to nextRunAt and inserts the tasks of this
+// firing with their outbox entries, all in one transaction. The schedule is
+// only updated if its next run is still the one that was read, so when several
+// schedulers race for the same tick exactly one of them fires it. FireSchedule
+// returns false, and inserts nothing, when it lost that race.
+func (r *ScheduleRepository) FireSchedule(
+	ctx context.Context,
+	schedule *models.Schedule,
+	tasks []*models.Task,
+	nextRunAt *time.Time,
+) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var lastTaskID sql.NullString
+	var lastRunAt sql.NullTime
+	if len(tasks) > 0 {
+		lastTaskID = sql.NullString{String: tasks[len(tasks)-1].ID, Valid: true}
+		lastRunAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+
+	query := `
+		UPDATE schedules
+		SET next_run_at = $3,
+		    last_run_at = COALESCE($4, last_run_at),
+		    last_task_id = COALESCE($5, last_task_id)
+		WHERE id = $1
+		  AND enabled
+		  AND next_run_at = $2
+	`
+
+	res, err := tx.ExecContext(ctx, query, schedule.ID, schedule.NextRunAt, nextRunAt, lastRunAt, lastTaskID)
+	if err != nil {
+		return false, fmt.Errorf("failed to advance schedule: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	for _, task := range tasks {
+		if err := taskstore.InsertTask(ctx, tx, task); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit schedule firing: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func everyMinute(nextRunAt time.Time, misfire models.MisfirePolicy, overlap models.OverlapPolicy) *models.Schedule {
+	schedule := models.NewSchedule("test", "* * * * *", "UTC", models.TaskTemplate{Type: models.TaskTypeEmailSend})
+	schedule.NextRunAt = &nextRunAt
+	schedule.MisfirePolicy = misfire
+	schedule.OverlapPolicy = overlap
+	return schedule
+}
+
+// lastTask is the previous task of a schedule, with 3 retries
+func lastTask(state models.TaskState, retryCount int) *models.Task {
+	return &models.Task{State: state, RetryCount: retryCount, MaxRetries: 3}
+}
+
+func TestPlanTicks_OnTime(t *testing.T) {
+	now := time.Date(2025, 1, 15, 10, 0, 2, 0, time.UTC)
+	schedule := everyMinute(now.Truncate(time.Minute), models.MisfireFireOnce, models.OverlapAllow)
+
+	p, err := planTicks(schedule, nil, now, time.Minute, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.fire)
+	assert.Equal(t, time.Date(2025, 1, 15, 10, 1, 0, 0, time.UTC), p.next)
+	assert.Zero(t, p.misfireSkipped)
+}
+
+func TestPlanTicks_MisfirePolicies(t *testing.T) {
+	// the scheduler was down for five minutes
+	now := time.Date(2025, 1, 15, 10, 5, 2, 0, time.UTC)
+	missedSince := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		policy  models.MisfirePolicy
+		fire    int
+		skipped int
+	}{
+		// 10:00 to 10:04 were missed, only 10:05 is within the threshold
+		{models.MisfireFireAll, 6, 0},
+		{models.MisfireFireOnce, 1, 5},
+		{models.MisfireSkip, 1, 5},
+	}
+
+	for _, tt := range tests {
+		schedule := everyMinute(missedSince, tt.policy, models.OverlapAllow)
+		p, err := planTicks(schedule, nil, now, time.Minute, 10)
+		require.NoError(t, err)
+		assert.Equal(t, tt.fire, p.fire, tt.policy)
+		assert.Equal(t, tt.skipped, p.misfireSkipped, tt.policy)
+		assert.Equal(t, time.Date(2025, 1, 15, 10, 6, 0, 0, time.UTC), p.next, tt.policy)
+	}
+}
+
+func TestPlanTicks_CatchUpIsCapped(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	schedule := everyMinute(now.Add(-time.Hour), models.MisfireFireAll, models.OverlapAllow)
+
+	p, err := planTicks(schedule, nil, now, time.Minute, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 3, p.fire)
+	// the ticks from 11:03 to 12:00 are skipped
+	assert.Equal(t, 58, p.misfireSkipped)
+	assert.Equal(t, now.Add(time.Minute), p.next)
+}
+
+func TestPlanTicks_OverlapSkip(t *testing.T) {
+	now := time.Date(2025, 1, 15, 10, 0, 2, 0, time.UTC)
+	schedule := everyMinute(now.Truncate(time.Minute), models.MisfireFireAll, models.OverlapSkip)
+
+	// the previous task is still running, waiting or failed with retries left
+	for _, last := range []*models.Task{
+		lastTask(models.TaskStateRunning, 0),
+		lastTask(models.TaskStateBlocked, 0),
+		lastTask(models.TaskStateFailed, 1),
+	} {
+		p, err := planTicks(schedule, last, now, time.Minute, 10)
+		require.NoError(t, err)
+		assert.Zero(t, p.fire, last.State)
+		assert.Equal(t, 1, p.overlapSkipped, last.State)
+		assert.Equal(t, time.Date(2025, 1, 15, 10, 1, 0, 0, time.UTC), p.next)
+	}
+
+	// the previous task is done, or failed for good
+	p, err := planTicks(schedule, lastTask(models.TaskStateCompleted, 0), now, time.Minute, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.fire)
+	p, err = planTicks(schedule, lastTask(models.TaskStateFailed, 3), now, time.Minute, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.fire)
+
+	// catching up would make the new tasks overlap each other
+	schedule = everyMinute(now.Add(-5*time.Minute).Truncate(time.Minute), models.MisfireFireAll, models.OverlapSkip)
+	p, err = planTicks(schedule, lastTask(models.TaskStateCompleted, 0), now, time.Minute, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.fire)
+	assert.Equal(t, 5, p.overlapSkipped)
+}
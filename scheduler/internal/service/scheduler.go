@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/alaajili/task-scheduler/scheduler/internal/repository"
+	"github.com/alaajili/task-scheduler/shared/config"
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/metrics"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/outbox"
+	"go.uber.org/zap"
+)
+
+// Scheduler turns due cron schedules into tasks. Any number of schedulers can
+// run against the same database, every tick is fired by exactly one of them.
+type Scheduler struct {
+	repo             *repository.ScheduleRepository
+	relay            *outbox.Relay
+	interval         time.Duration
+	misfireThreshold time.Duration
+	maxCatchUp       int
+	batchSize        int
+}
+
+func NewScheduler(
+	repo *repository.ScheduleRepository,
+	relay *outbox.Relay,
+	cfg config.SchedulerConfig,
+) *Scheduler {
+	interval := cfg.TickInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	misfireThreshold := cfg.MisfireThreshold
+	if misfireThreshold <= 0 {
+		misfireThreshold = time.Minute
+	}
+	maxCatchUp := cfg.MaxCatchUp
+	if maxCatchUp <= 0 {
+		maxCatchUp = 10
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	return &Scheduler{
+		repo:             repo,
+		relay:            relay,
+		interval:         interval,
+		misfireThreshold: misfireThreshold,
+		maxCatchUp:       maxCatchUp,
+		batchSize:        batchSize,
+	}
+}
+
+// Run fires due schedules on every tick until the context is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	logger.Info("Scheduler started", zap.Duration("interval", s.interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Scheduler stopping")
+			return
+		case <-ticker.C:
+			if _, err := s.TickOnce(ctx); err != nil {
+				logger.Error("Failed to fire due schedules", zap.Error(err))
+			}
+		}
+	}
+}
+
+// TickOnce fires the schedules that are due and returns how many tasks it
+// created. A schedule that fails to fire is logged and retried on the next tick.
+func (s *Scheduler) TickOnce(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+
+	due, err := s.repo.GetDueSchedules(ctx, now, s.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, d := range due {
+		n, err := s.fire(ctx, d, now)
+		if err != nil {
+			logger.Error("Failed to fire schedule",
+				zap.String("schedule_id", d.Schedule.ID),
+				zap.Error(err),
+			)
+			continue
+		}
+		created += n
+	}
+	return created, nil
+}
+
+func (s *Scheduler) fire(ctx context.Context, due *repository.DueSchedule, now time.Time) (int, error) {
+	schedule := due.Schedule
+
+	p, err := planTicks(schedule, due.LastTask, now, s.misfireThreshold, s.maxCatchUp)
+	if err != nil {
+		return 0, err
+	}
+
+	tasks := make([]*models.Task, p.fire)
+	for i := range tasks {
+		tasks[i] = schedule.Task.NewTask()
+	}
+
+	var nextRunAt *time.Time
+	if !p.next.IsZero() {
+		nextRunAt = &p.next
+	}
+
+	fired, err := s.repo.FireSchedule(ctx, schedule, tasks, nextRunAt)
+	if err != nil {
+		return 0, err
+	}
+	if !fired {
+		// another scheduler got there first
+		return 0, nil
+	}
+
+	metrics.ScheduleTicks.WithLabelValues("fired").Add(float64(p.fire))
+	metrics.ScheduleTicks.WithLabelValues("overlap_skipped").Add(float64(p.overlapSkipped))
+	metrics.ScheduleTicks.WithLabelValues("misfire_skipped").Add(float64(p.misfireSkipped))
+
+	if p.overlapSkipped > 0 || p.misfireSkipped > 0 {
+		logger.Info("Skipped schedule ticks",
+			zap.String("schedule_id", schedule.ID),
+			zap.Int("overlap_skipped", p.overlapSkipped),
+			zap.Int("misfire_skipped", p.misfireSkipped),
+		)
+	}
+
+	for _, task := range tasks {
+		logger.Info("Schedule fired",
+			zap.String("schedule_id", schedule.ID),
+			zap.String("task_id", task.ID),
+		)
+		s.publish(ctx, task)
+	}
+	if nextRunAt == nil {
+		logger.Warn("Schedule will not fire again", zap.String("schedule_id", schedule.ID))
+	}
+
+	return len(tasks), nil
+}
+
+// publish pushes a freshly committed task to the queue, failures are left to
+// the outbox relay
+func (s *Scheduler) publish(ctx context.Context, task *models.Task) {
+	if s.relay == nil {
+		return
+	}
+
+	if err := s.relay.PublishTask(ctx, task.ID); err != nil {
+		logger.Warn("Failed to publish task, leaving it to the outbox relay",
+			zap.String("task_id", task.ID),
+			zap.Error(err),
+		)
+	}
+}
+
+// tickPlan is what a schedule does with the ticks that are due
+type tickPlan struct {
+	fire           int       // tasks to create
+	next           time.Time // the schedule's next run, zero if there is none
+	overlapSkipped int
+	misfireSkipped int
+}
+
+// planTicks applies the misfire and overlap policies to the ticks of a schedule
+// that are due at now. Ticks more than misfireThreshold old were missed, at
+// most maxCatchUp of them are considered and the ones past it are skipped.
+func planTicks(
+	schedule *models.Schedule,
+	lastTask *models.Task,
+	now time.Time,
+	misfireThreshold time.Duration,
+	maxCatchUp int,
+) (tickPlan, error) {
+	var p tickPlan
+
+	next, err := schedule.NextRun(now)
+	if err != nil {
+		return p, err
+	}
+	p.next = next
+
+	var onTime, missed int
+	tick := *schedule.NextRunAt
+	for !tick.IsZero() && !tick.After(now) && onTime+missed < maxCatchUp {
+		if now.Sub(tick) > misfireThreshold {
+			missed++
+		} else {
+			onTime++
+		}
+
+		if tick, err = schedule.NextRun(tick); err != nil {
+			return p, err
+		}
+	}
+
+	// the ticks past maxCatchUp never fire whatever the policy
+	var dropped int
+	for !tick.IsZero() && !tick.After(now) {
+		dropped++
+		if tick, err = schedule.NextRun(tick); err != nil {
+			return p, err
+		}
+	}
+
+	switch {
+	case missed == 0:
+		p.fire = onTime
+	case schedule.MisfirePolicy == models.MisfireFireAll:
+		p.fire = onTime + missed
+	case schedule.MisfirePolicy == models.MisfireSkip:
+		p.fire = onTime
+		p.misfireSkipped = missed
+	default:
+		// fire_once: a single task stands in for every due tick
+		p.fire = 1
+		p.misfireSkipped = onTime + missed - 1
+	}
+	p.misfireSkipped += dropped
+
+	if schedule.OverlapPolicy == models.OverlapSkip && p.fire > 0 {
+		if inFlight(lastTask) {
+			p.overlapSkipped = p.fire
+			p.fire = 0
+		} else if p.fire > 1 {
+			// the tasks of one firing would overlap each other
+			p.overlapSkipped = p.fire - 1
+			p.fire = 1
+		}
+	}
+
+	return p, nil
+}
+
+// inFlight reports whether the previous task may still run: it is waiting,
+// running or failed with retries left
+func inFlight(lastTask *models.Task) bool {
+	return lastTask != nil && !lastTask.IsFinished()
+}
@@ -0,0 +1,87 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alaajili/task-scheduler/scheduler/internal/repository"
+	"github.com/alaajili/task-scheduler/scheduler/internal/service"
+	"github.com/alaajili/task-scheduler/shared/config"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createDueSchedule(t *testing.T, repo *repository.ScheduleRepository) *models.Schedule {
+	t.Helper()
+
+	schedule := models.NewSchedule("every-minute", "* * * * *", "UTC", models.TaskTemplate{
+		Type:     models.TaskTypeEmailSend,
+		Payload:  json.RawMessage(`{"to": "a@example.com", "subject": "tick"}`),
+		Priority: 5,
+	})
+	due := time.Now().UTC().Truncate(time.Minute)
+	schedule.NextRunAt = &due
+
+	_, err := repo.DB().ExecContext(context.Background(), `
+		INSERT INTO schedules (id, name, cron_expr, timezone, task_type, payload, priority, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, schedule.ID, schedule.Name, schedule.CronExpr, schedule.Timezone,
+		schedule.Task.Type, schedule.Task.Payload, schedule.Task.Priority, schedule.NextRunAt)
+	require.NoError(t, err)
+	return schedule
+}
+
+func TestScheduler_FiresEachTickOnce(t *testing.T) {
+	db := testutil.TestDB(t)
+	repo := repository.NewScheduleRepository(db)
+	schedule := createDueSchedule(t, repo)
+	ctx := context.Background()
+
+	cfg := config.SchedulerConfig{MisfireThreshold: time.Minute, MaxCatchUp: 10, BatchSize: 10}
+
+	// several replicas race for the same tick
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		total int
+	)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			created, err := service.NewScheduler(repo, nil, cfg).TickOnce(ctx)
+			assert.NoError(t, err)
+			mu.Lock()
+			total += created
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, total)
+
+	var tasks int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks`).Scan(&tasks))
+	assert.Equal(t, 1, tasks)
+
+	var outboxEntries int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM task_outbox`).Scan(&outboxEntries))
+	assert.Equal(t, 1, outboxEntries)
+
+	var nextRunAt time.Time
+	var lastTaskID string
+	require.NoError(t, db.QueryRowContext(ctx,
+		`SELECT next_run_at, last_task_id FROM schedules WHERE id = $1`, schedule.ID,
+	).Scan(&nextRunAt, &lastTaskID))
+	assert.True(t, nextRunAt.After(*schedule.NextRunAt))
+	assert.NotEmpty(t, lastTaskID)
+
+	// nothing is due until the next minute
+	created, err := service.NewScheduler(repo, nil, cfg).TickOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, created)
+}
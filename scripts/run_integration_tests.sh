@@ -22,6 +22,13 @@ go run cmd/main.go &
 API_PID=$!
 cd ..
 
+# Start a worker in background
+echo "Starting worker..."
+cd worker
+go run cmd/main.go &
+WORKER_PID=$!
+cd ..
+
 # Wait for API to be ready
 echo "Waiting for API server to be ready..."
 for i in {1..30}; do
@@ -31,7 +38,7 @@ for i in {1..30}; do
     fi
     if [ $i -eq 30 ]; then
         echo "API server failed to start"
-        kill $API_PID
+        kill $API_PID $WORKER_PID
         exit 1
     fi
     sleep 1
@@ -45,7 +52,7 @@ TEST_RESULT=$?
 
 # Cleanup
 echo "Cleaning up..."
-kill $API_PID
+kill $API_PID $WORKER_PID
 
 if [ $TEST_RESULT -eq 0 ]; then
     echo "✅ All integration tests passed!"
@@ -0,0 +1,369 @@
+package callback
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/config"
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// SignatureHeader holds the HMAC-SHA256 of the request body keyed with the
+	// task's callback secret, see Sign
+	SignatureHeader = "X-Signature-256"
+	// IDHeader holds the callback id, the same for every attempt, receivers
+	// can use it to drop duplicate deliveries
+	IDHeader      = "X-Callback-ID"
+	EventHeader   = "X-Callback-Event"
+	AttemptHeader = "X-Callback-Attempt"
+)
+
+// Enqueue records the callback of a task that just finished, if the task has a
+// callback url. It must run in the transaction that finishes the task so that
+// the callback is delivered if and only if the state change commits.
+func Enqueue(ctx context.Context, tx *sql.Tx, taskID string) error {
+	query := `
+		SELECT type, state, callback_url, result, error, error_reason, retry_count
+		FROM tasks
+		WHERE id = $1
+	`
+
+	var (
+		payload     models.CallbackPayload
+		url         sql.NullString
+		result      []byte
+		taskErr     sql.NullString
+		errorReason sql.NullString
+	)
+	err := tx.QueryRowContext(ctx, query, taskID).Scan(
+		&payload.Type, &payload.State, &url, &result, &taskErr, &errorReason, &payload.RetryCount,
+	)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get task callback: %w", err)
+	}
+	if !url.Valid || url.String == "" {
+		return nil
+	}
+	event, ok := models.CallbackEventFor(payload.State)
+	if !ok {
+		return nil
+	}
+
+	payload.Event = event
+	payload.TaskID = taskID
+	payload.Result = result
+	payload.Error = taskErr.String
+	payload.ErrorReason = models.ErrorReason(errorReason.String)
+	payload.OccurredAt = time.Now().UTC()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode callback payload: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO task_callbacks (task_id, event, url, payload) VALUES ($1, $2, $3, $4)`,
+		taskID, event, url.String, body,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert task callback: %w", err)
+	}
+	return nil
+}
+
+// Sign returns the value of SignatureHeader for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher delivers pending callbacks to their urls and retries the failed
+// deliveries with an exponential backoff. Several dispatchers can run against
+// the same database, callbacks are claimed with FOR UPDATE SKIP LOCKED.
+type Dispatcher struct {
+	db          *database.DB
+	client      *http.Client
+	interval    time.Duration
+	batchSize   int
+	timeout     time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+func NewDispatcher(db *database.DB, cfg config.CallbackConfig) *Dispatcher {
+	d := &Dispatcher{
+		db:          db,
+		interval:    cfg.PollInterval,
+		batchSize:   cfg.BatchSize,
+		timeout:     cfg.Timeout,
+		maxAttempts: cfg.MaxAttempts,
+		backoff:     cfg.Backoff,
+		maxBackoff:  cfg.MaxBackoff,
+	}
+	if d.interval <= 0 {
+		d.interval = time.Second
+	}
+	if d.batchSize <= 0 {
+		d.batchSize = 50
+	}
+	if d.timeout <= 0 {
+		d.timeout = 10 * time.Second
+	}
+	if d.maxAttempts <= 0 {
+		d.maxAttempts = 8
+	}
+	if d.backoff <= 0 {
+		d.backoff = 5 * time.Second
+	}
+	if d.maxBackoff < d.backoff {
+		d.maxBackoff = max(d.backoff, time.Hour)
+	}
+	d.client = &http.Client{Timeout: d.timeout}
+	return d
+}
+
+// claim is a callback taken by DispatchOnce
+type claim struct {
+	id      int64
+	taskID  string
+	event   models.CallbackEvent
+	url     string
+	payload []byte
+	attempt int
+	secret  string
+}
+
+// outcome is the result of a delivery attempt
+type outcome struct {
+	statusCode int
+	err        error
+	duration   time.Duration
+}
+
+func (o outcome) delivered() bool {
+	return o.err == nil && o.statusCode >= 200 && o.statusCode < 300
+}
+
+// DispatchOnce attempts up to one batch of due callbacks and returns how many
+// of them were delivered.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	claims, err := d.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	outcomes := make([]outcome, len(claims))
+	var wg sync.WaitGroup
+	for i := range claims {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			outcomes[i] = d.deliver(ctx, claims[i])
+		}(i)
+	}
+	wg.Wait()
+
+	delivered := 0
+	for i, c := range claims {
+		if err := d.record(ctx, c, outcomes[i]); err != nil {
+			return delivered, err
+		}
+		if outcomes[i].delivered() {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// Run dispatches callbacks until the context is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	logger.Info("Callback dispatcher started", zap.Duration("interval", d.interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Callback dispatcher stopping")
+			return
+		case <-ticker.C:
+			delivered, err := d.DispatchOnce(ctx)
+			if err != nil {
+				logger.Error("Failed to dispatch callbacks", zap.Error(err))
+				continue
+			}
+			if delivered > 0 {
+				logger.Debug("Delivered callbacks", zap.Int("count", delivered))
+			}
+		}
+	}
+}
+
+// claim takes the due callbacks and pushes their next attempt past the
+// delivery timeout, so another dispatcher only picks them up again if this
+// one dies before recording the attempt
+func (d *Dispatcher) claim(ctx context.Context) ([]claim, error) {
+	query := `
+		UPDATE task_callbacks c
+		SET next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+		FROM tasks t
+		WHERE t.id = c.task_id
+		  AND c.id IN (
+			SELECT id
+			FROM task_callbacks
+			WHERE state = 'pending'
+			  AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		  )
+		RETURNING c.id, c.task_id, c.event, c.url, c.payload, c.attempts, t.callback_secret
+	`
+
+	lease := (2 * d.timeout).Seconds()
+	rows, err := d.db.QueryContext(ctx, query, d.batchSize, lease)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim callbacks: %w", err)
+	}
+	defer rows.Close()
+
+	var claims []claim
+	for rows.Next() {
+		var (
+			c      claim
+			secret sql.NullString
+		)
+		if err := rows.Scan(&c.id, &c.taskID, &c.event, &c.url, &c.payload, &c.attempt, &secret); err != nil {
+			return nil, fmt.Errorf("failed to scan callback: %w", err)
+		}
+		c.attempt++
+		c.secret = secret.String
+		claims = append(claims, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read callbacks: %w", err)
+	}
+	return claims, nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, c claim) outcome {
+	start := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(c.payload))
+	if err != nil {
+		return outcome{err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "task-scheduler-callbacks")
+	req.Header.Set(IDHeader, strconv.FormatInt(c.id, 10))
+	req.Header.Set(EventHeader, string(c.event))
+	req.Header.Set(AttemptHeader, strconv.Itoa(c.attempt))
+	if c.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(c.secret, c.payload))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return outcome{err: err, duration: time.Since(start)}
+	}
+	defer resp.Body.Close()
+	// drain a bit of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	o := outcome{statusCode: resp.StatusCode, duration: time.Since(start)}
+	if !o.delivered() {
+		o.err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return o
+}
+
+// record logs an attempt and moves the callback on: delivered, retried after
+// a backoff or failed for good once it ran out of attempts
+func (d *Dispatcher) record(ctx context.Context, c claim, o outcome) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin callback transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		statusCode sql.NullInt64
+		attemptErr sql.NullString
+	)
+	if o.statusCode != 0 {
+		statusCode = sql.NullInt64{Int64: int64(o.statusCode), Valid: true}
+	}
+	if o.err != nil {
+		attemptErr = sql.NullString{String: o.err.Error(), Valid: true}
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO task_callback_attempts (callback_id, attempt, status_code, error, duration_ms)
+		VALUES ($1, $2, $3, $4, $5)
+	`, c.id, c.attempt, statusCode, attemptErr, o.duration.Milliseconds())
+	if err != nil {
+		return fmt.Errorf("failed to record callback attempt: %w", err)
+	}
+
+	state := models.CallbackStatePending
+	switch {
+	case o.delivered():
+		state = models.CallbackStateDelivered
+	case c.attempt >= d.maxAttempts:
+		state = models.CallbackStateFailed
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE task_callbacks
+		SET attempts = $2,
+		    state = $3,
+		    next_attempt_at = NOW() + $4 * INTERVAL '1 second',
+		    delivered_at = CASE WHEN $3 = 'delivered' THEN NOW() END
+		WHERE id = $1
+	`, c.id, c.attempt, state, d.backoffFor(c.attempt).Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to update callback: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit callback attempt: %w", err)
+	}
+
+	if state == models.CallbackStateFailed {
+		logger.Warn("Callback failed for good",
+			zap.Int64("callback_id", c.id),
+			zap.String("task_id", c.taskID),
+			zap.Int("attempts", c.attempt),
+			zap.Error(o.err),
+		)
+	}
+	return nil
+}
+
+// backoffFor returns the delay after the given failed attempt, doubling from
+// the base backoff up to the max
+func (d *Dispatcher) backoffFor(attempt int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempt && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxBackoff)
+}
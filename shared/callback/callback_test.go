@@ -0,0 +1,30 @@
+package callback
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"task.completed"}`)
+
+	signature := Sign("secret", body)
+	assert.Equal(t, "sha256=", signature[:7])
+	assert.Len(t, signature, 7+64)
+	assert.Equal(t, signature, Sign("secret", body))
+	assert.NotEqual(t, signature, Sign("other", body))
+	assert.NotEqual(t, signature, Sign("secret", []byte(`{"event":"task.failed"}`)))
+}
+
+func TestBackoffFor(t *testing.T) {
+	d := NewDispatcher(nil, config.CallbackConfig{Backoff: 5 * time.Second, MaxBackoff: time.Minute})
+
+	assert.Equal(t, 5*time.Second, d.backoffFor(1))
+	assert.Equal(t, 10*time.Second, d.backoffFor(2))
+	assert.Equal(t, 40*time.Second, d.backoffFor(4))
+	assert.Equal(t, time.Minute, d.backoffFor(5))
+	assert.Equal(t, time.Minute, d.backoffFor(50))
+}
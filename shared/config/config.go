@@ -9,11 +9,15 @@ import (
 
 // Config holds the configuration settings for the application.
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	Etcd     EtcdConfig	    `mapstructure:"etcd"`
-	Worker   WorkerConfig   `mapstructure:"worker"`
+	Server     ServerConfig     `mapstructure:"server"`
+	Database   DatabaseConfig   `mapstructure:"database"`
+	Redis      RedisConfig      `mapstructure:"redis"`
+	Etcd       EtcdConfig       `mapstructure:"etcd"`
+	Worker     WorkerConfig     `mapstructure:"worker"`
+	Outbox     OutboxConfig     `mapstructure:"outbox"`
+	Scheduler  SchedulerConfig  `mapstructure:"scheduler"`
+	Callback   CallbackConfig   `mapstructure:"callback"`
+	DeadLetter DeadLetterConfig `mapstructure:"dead_letter"`
 }
 
 type ServerConfig struct {
@@ -21,6 +25,8 @@ type ServerConfig struct {
 	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
 	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
 	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	// IdempotencyRetention is how long an idempotency key maps to its task
+	IdempotencyRetention time.Duration `mapstructure:"idempotency_retention"`
 }
 
 type DatabaseConfig struct {
@@ -52,6 +58,53 @@ type WorkerConfig struct {
 	TaskTimeout             time.Duration `mapstructure:"task_timeout"`
 	MaxConcurrent           int           `mapstructure:"max_concurrent"`
 	GracefulShutdownTimeout time.Duration `mapstructure:"graceful_shutdown_timeout"`
+	VisibilityTimeout       time.Duration `mapstructure:"visibility_timeout"`
+	LeaseSweepInterval      time.Duration `mapstructure:"lease_sweep_interval"`
+	PromoteInterval         time.Duration `mapstructure:"promote_interval"`
+	MetricsPort             int           `mapstructure:"metrics_port"`
+	HeartbeatTimeout        time.Duration `mapstructure:"heartbeat_timeout"`
+	ReaperInterval          time.Duration `mapstructure:"reaper_interval"`
+	// TaskTypeTimeouts overrides TaskTimeout for the listed task types
+	TaskTypeTimeouts map[string]time.Duration `mapstructure:"task_type_timeouts"`
+}
+
+// OutboxConfig controls the relay that drains the task outbox into the queue.
+type OutboxConfig struct {
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+}
+
+// SchedulerConfig controls the process that turns cron schedules into tasks.
+type SchedulerConfig struct {
+	TickInterval time.Duration `mapstructure:"tick_interval"`
+	// ticks older than MisfireThreshold are handled by the misfire policy
+	MisfireThreshold time.Duration `mapstructure:"misfire_threshold"`
+	// MaxCatchUp caps the tasks created for one schedule in a single tick
+	MaxCatchUp  int `mapstructure:"max_catch_up"`
+	BatchSize   int `mapstructure:"batch_size"`
+	MetricsPort int `mapstructure:"metrics_port"`
+}
+
+// CallbackConfig controls the delivery of task callbacks to their urls.
+type CallbackConfig struct {
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	// Timeout bounds a single delivery attempt
+	Timeout time.Duration `mapstructure:"timeout"`
+	// MaxAttempts is how many times a callback is tried before it is failed,
+	// attempts back off exponentially from Backoff up to MaxBackoff
+	MaxAttempts int           `mapstructure:"max_attempts"`
+	Backoff     time.Duration `mapstructure:"backoff"`
+	MaxBackoff  time.Duration `mapstructure:"max_backoff"`
+}
+
+// DeadLetterConfig controls the monitoring of the dead letters.
+type DeadLetterConfig struct {
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+	// AlertThreshold is the number of dead letters above which every growth
+	// raises an alert, posted to AlertWebhookURL when set
+	AlertThreshold  int    `mapstructure:"alert_threshold"`
+	AlertWebhookURL string `mapstructure:"alert_webhook_url"`
 }
 
 // LoadConfig loads the configuration from config file or environment variables.
@@ -84,6 +137,7 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("server.read_timeout", "30s")
 	v.SetDefault("server.write_timeout", "30s")
 	v.SetDefault("server.shutdown_timeout", "10s")
+	v.SetDefault("server.idempotency_retention", "24h")
 
 	// Database defaults
 	v.SetDefault("database.host", "localhost")
@@ -110,6 +164,37 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("worker.task_poll_interval", "1s")
 	v.SetDefault("worker.task_timeout", "5m")
 	v.SetDefault("worker.max_concurrent", 10)
+	v.SetDefault("worker.graceful_shutdown_timeout", "30s")
+	v.SetDefault("worker.visibility_timeout", "1m")
+	v.SetDefault("worker.lease_sweep_interval", "10s")
+	v.SetDefault("worker.promote_interval", "1s")
+	v.SetDefault("worker.metrics_port", 9091)
+	v.SetDefault("worker.heartbeat_timeout", "30s")
+	v.SetDefault("worker.reaper_interval", "15s")
+
+	// Outbox defaults
+	v.SetDefault("outbox.poll_interval", "1s")
+	v.SetDefault("outbox.batch_size", 100)
+
+	// Scheduler defaults
+	v.SetDefault("scheduler.tick_interval", "1s")
+	v.SetDefault("scheduler.misfire_threshold", "1m")
+	v.SetDefault("scheduler.max_catch_up", 10)
+	v.SetDefault("scheduler.batch_size", 100)
+	v.SetDefault("scheduler.metrics_port", 9092)
+
+	// Callback defaults
+	v.SetDefault("callback.poll_interval", "1s")
+	v.SetDefault("callback.batch_size", 50)
+	v.SetDefault("callback.timeout", "10s")
+	v.SetDefault("callback.max_attempts", 8)
+	v.SetDefault("callback.backoff", "5s")
+	v.SetDefault("callback.max_backoff", "1h")
+
+	// Dead letter defaults
+	v.SetDefault("dead_letter.check_interval", "30s")
+	v.SetDefault("dead_letter.alert_threshold", 100)
+	v.SetDefault("dead_letter.alert_webhook_url", "")
 }
 
 // DSN returns the Data Source Name for database connection
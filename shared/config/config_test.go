@@ -15,6 +15,7 @@ func TestLoadConfig(t *testing.T) {
 	assert.Equal(t, 30*time.Second, config.Server.ReadTimeout)
 	assert.Equal(t, 30*time.Second, config.Server.WriteTimeout)
 	assert.Equal(t, 10*time.Second, config.Server.ShutdownTimeout)
+	assert.Equal(t, 24*time.Hour, config.Server.IdempotencyRetention)
 
 	assert.Equal(t, "localhost", config.Database.Host)
 	assert.Equal(t, 5432, config.Database.Port)
@@ -37,6 +38,26 @@ func TestLoadConfig(t *testing.T) {
 	assert.Equal(t, 1*time.Second, config.Worker.TaskPollInterval)
 	assert.Equal(t, 5*time.Minute, config.Worker.TaskTimeout)
 	assert.Equal(t, 10, config.Worker.MaxConcurrent)
+	assert.Equal(t, 30*time.Second, config.Worker.GracefulShutdownTimeout)
+	assert.Equal(t, 1*time.Minute, config.Worker.VisibilityTimeout)
+	assert.Equal(t, 10*time.Second, config.Worker.LeaseSweepInterval)
+	assert.Equal(t, 1*time.Second, config.Worker.PromoteInterval)
+	assert.Equal(t, 9091, config.Worker.MetricsPort)
+	assert.Equal(t, 30*time.Second, config.Worker.HeartbeatTimeout)
+	assert.Equal(t, 15*time.Second, config.Worker.ReaperInterval)
+	assert.Equal(t, map[string]time.Duration{
+		"http_request": time.Minute,
+		"long_running": 30 * time.Minute,
+	}, config.Worker.TaskTypeTimeouts)
+
+	assert.Equal(t, 1*time.Second, config.Outbox.PollInterval)
+	assert.Equal(t, 100, config.Outbox.BatchSize)
+
+	assert.Equal(t, 1*time.Second, config.Scheduler.TickInterval)
+	assert.Equal(t, 1*time.Minute, config.Scheduler.MisfireThreshold)
+	assert.Equal(t, 10, config.Scheduler.MaxCatchUp)
+	assert.Equal(t, 100, config.Scheduler.BatchSize)
+	assert.Equal(t, 9092, config.Scheduler.MetricsPort)
 }
 
 func TestDSN(t *testing.T) {
@@ -0,0 +1,27 @@
+package deadletter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Record moves a task that just failed for good to the dead letters. It must
+// run in the transaction that finishes the task, tasks that completed, were
+// cancelled or skipped, or still have retries left are left alone.
+func Record(ctx context.Context, tx *sql.Tx, taskID string) error {
+	query := `
+		INSERT INTO dead_letters (task_id, task_type, error, error_reason, retry_count)
+		SELECT id, type, error, error_reason, retry_count
+		FROM tasks
+		WHERE id = $1
+		  AND state = 'failed'
+		  AND retry_count >= max_retries
+		ON CONFLICT (task_id) DO NOTHING
+	`
+
+	if _, err := tx.ExecContext(ctx, query, taskID); err != nil {
+		return fmt.Errorf("failed to record dead letter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO dead_letters .* retry_count >= max_retries").
+		WithArgs("task-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := mockDB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, Record(context.Background(), tx, "task-1"))
+	require.NoError(t, tx.Commit())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecord_Error(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO dead_letters").
+		WithArgs("task-1").
+		WillReturnError(errors.New("connection reset"))
+
+	tx, err := mockDB.Begin()
+	require.NoError(t, err)
+	err = Record(context.Background(), tx, "task-1")
+	assert.ErrorContains(t, err, "failed to record dead letter")
+}
@@ -0,0 +1,69 @@
+package group
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/taskstore"
+)
+
+// Resolve runs in the transaction that finishes a task. When the task was the
+// last unfinished member of its group it completes the group and creates the
+// group's on_complete task, whose outbox entry is inserted in the same
+// transaction. It returns the id of that task, if any.
+//
+// The group row is locked, members finishing concurrently wait for each other
+// and only the last one sees the group done, so the task is created once.
+func Resolve(ctx context.Context, tx *sql.Tx, taskID string) (string, error) {
+	var groupID sql.NullString
+	err := tx.QueryRowContext(ctx, `SELECT group_id FROM tasks WHERE id = $1`, taskID).Scan(&groupID)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to get task group: %w", err)
+	}
+	if !groupID.Valid {
+		return "", nil
+	}
+
+	var (
+		onComplete  []byte
+		completedAt sql.NullTime
+	)
+	query := `SELECT on_complete, completed_at FROM task_groups WHERE id = $1 FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, groupID.String).Scan(&onComplete, &completedAt); err != nil {
+		return "", fmt.Errorf("failed to lock task group: %w", err)
+	}
+	if completedAt.Valid {
+		return "", nil
+	}
+
+	var unfinished int
+	query = `SELECT COUNT(*) FROM tasks WHERE group_id = $1 AND NOT ` + models.FinishedSQL("")
+	if err := tx.QueryRowContext(ctx, query, groupID.String).Scan(&unfinished); err != nil {
+		return "", fmt.Errorf("failed to count unfinished group members: %w", err)
+	}
+	if unfinished > 0 {
+		return "", nil
+	}
+
+	var created sql.NullString
+	if len(onComplete) > 0 && string(onComplete) != "null" {
+		var template models.TaskTemplate
+		if err := json.Unmarshal(onComplete, &template); err != nil {
+			return "", fmt.Errorf("failed to decode on_complete task: %w", err)
+		}
+		task := template.NewTask()
+		if err := taskstore.InsertTask(ctx, tx, task); err != nil {
+			return "", err
+		}
+		created = sql.NullString{String: task.ID, Valid: true}
+	}
+
+	query = `UPDATE task_groups SET completed_at = NOW(), on_complete_task_id = $2 WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, groupID.String, created); err != nil {
+		return "", fmt.Errorf("failed to complete task group: %w", err)
+	}
+	return created.String, nil
+}
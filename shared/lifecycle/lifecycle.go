@@ -0,0 +1,53 @@
+// Package lifecycle runs what follows a task finishing, whichever service
+// finishes it.
+package lifecycle
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/alaajili/task-scheduler/shared/callback"
+	"github.com/alaajili/task-scheduler/shared/deadletter"
+	"github.com/alaajili/task-scheduler/shared/group"
+	"github.com/alaajili/task-scheduler/shared/notify"
+	"github.com/alaajili/task-scheduler/shared/workflow"
+)
+
+// Finish runs in the transaction that finishes a task, i.e. moves it to a
+// terminal state or fails it for the last time. The task wakes its waiters,
+// gets its callback enqueued, may complete its group, see group.Resolve, and
+// goes to the dead letters when it failed. Its dependents are resolved next,
+// see workflow.ResolveDependents, and the ones failed or skipped along the way
+// are finished the same way, down the graph. It returns the ids of the
+// released dependents.
+func Finish(ctx context.Context, tx *sql.Tx, taskID string) ([]string, error) {
+	var released []string
+
+	finished := []string{taskID}
+	for len(finished) > 0 {
+		id := finished[0]
+		finished = finished[1:]
+
+		if err := notify.TaskFinished(ctx, tx, id); err != nil {
+			return nil, err
+		}
+		if err := callback.Enqueue(ctx, tx, id); err != nil {
+			return nil, err
+		}
+		if _, err := group.Resolve(ctx, tx, id); err != nil {
+			return nil, err
+		}
+		if err := deadletter.Record(ctx, tx, id); err != nil {
+			return nil, err
+		}
+
+		dependentsReleased, dependentsFinished, err := workflow.ResolveDependents(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		released = append(released, dependentsReleased...)
+		finished = append(finished, dependentsFinished...)
+	}
+
+	return released, nil
+}
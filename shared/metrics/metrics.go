@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "task_scheduler"
+
+var (
+	// TasksPromoted counts delayed tasks moved back into the task queue.
+	TasksPromoted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "delayed_tasks_promoted_total",
+		Help:      "Number of delayed tasks promoted to the task queue.",
+	})
+
+	// PromotionLag observes how late delayed tasks were promoted after their due time.
+	PromotionLag = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "delayed_task_promotion_lag_seconds",
+		Help:      "Delay between a delayed task becoming due and its promotion.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+	})
+
+	// WorkerSlotsBusy tracks how many pool slots of this worker are running a task.
+	WorkerSlotsBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "worker_slots_busy",
+		Help:      "Number of worker pool slots currently running a task.",
+	})
+
+	// TasksRecovered counts tasks the reaper took back from dead workers, by outcome.
+	TasksRecovered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_recovered_total",
+		Help:      "Number of tasks recovered from workers that stopped heartbeating.",
+	}, []string{"outcome"})
+
+	// ScheduleTicks counts cron schedule ticks, by whether they created a task.
+	ScheduleTicks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "schedule_ticks_total",
+		Help:      "Number of schedule ticks processed, by outcome (fired, overlap_skipped, misfire_skipped).",
+	}, []string{"outcome"})
+
+	// DeadLetters tracks the tasks waiting in the dead letters, by task type.
+	DeadLetters = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "dead_letters",
+		Help:      "Number of tasks that failed for good and wait in the dead letters.",
+	}, []string{"task_type"})
+
+	// DeadLetterAlerts counts the alerts raised because the dead letters grew.
+	DeadLetterAlerts = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dead_letter_alerts_total",
+		Help:      "Number of alerts raised because the dead letters grew past the threshold.",
+	})
+)
+
+// Handler exposes the registered metrics for scraping.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
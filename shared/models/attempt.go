@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// AttemptState is the outcome of an attempt, running until it finished.
+type AttemptState string
+
+const (
+	AttemptStateRunning   AttemptState = "running"
+	AttemptStateCompleted AttemptState = "completed"
+	AttemptStateFailed    AttemptState = "failed"
+	AttemptStateCancelled AttemptState = "cancelled"
+	// AttemptStateReleased is set when the worker handed the task back
+	// without running it to the end, e.g. while shutting down
+	AttemptStateReleased AttemptState = "released"
+	// AttemptStateLost is set when the worker died and the reaper recovered
+	// the task
+	AttemptStateLost AttemptState = "lost"
+)
+
+// TaskAttempt is one run of a task by a worker.
+type TaskAttempt struct {
+	ID          int64        `json:"id" db:"id"`
+	TaskID      string       `json:"task_id" db:"task_id"`
+	Attempt     int          `json:"attempt" db:"attempt"`
+	WorkerID    string       `json:"worker_id,omitempty" db:"worker_id"`
+	State       AttemptState `json:"state" db:"state"`
+	StartedAt   time.Time    `json:"started_at" db:"started_at"`
+	FinishedAt  *time.Time   `json:"finished_at,omitempty" db:"finished_at"`
+	DurationMs  *int64       `json:"duration_ms,omitempty" db:"duration_ms"`
+	Error       string       `json:"error,omitempty" db:"error"`
+	ErrorReason ErrorReason  `json:"error_reason,omitempty" db:"error_reason"`
+	// ResultSize is the size of the result in bytes, for completed attempts
+	ResultSize *int       `json:"result_size,omitempty" db:"result_size"`
+	RetryAt    *time.Time `json:"retry_at,omitempty" db:"retry_at"`
+}
+
+// TaskHistory is the timeline of a task: every attempt and the events logged
+// for it, oldest first.
+type TaskHistory struct {
+	TaskID   string         `json:"task_id"`
+	State    TaskState      `json:"state"`
+	Attempts []*TaskAttempt `json:"attempts"`
+	Events   []*TaskEvent   `json:"events"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Batch is a set of tasks submitted in a single request, Size counts the
+// tasks that were created.
+type Batch struct {
+	ID        string    `json:"id" db:"id"`
+	Size      int       `json:"size" db:"size"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+func NewBatch(size int) *Batch {
+	return &Batch{
+		ID:        uuid.New().String(),
+		Size:      size,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// BatchStatus is a batch together with its tasks and their aggregate state.
+type BatchStatus struct {
+	*Batch
+	State  TaskState         `json:"state"`
+	Counts map[TaskState]int `json:"counts"`
+	Tasks  []*Task           `json:"tasks"`
+}
+
+func NewBatchStatus(batch *Batch, tasks []*Task) *BatchStatus {
+	counts := make(map[TaskState]int)
+	for _, task := range tasks {
+		counts[task.State]++
+	}
+
+	return &BatchStatus{
+		Batch:  batch,
+		State:  AggregateState(tasks),
+		Counts: counts,
+		Tasks:  tasks,
+	}
+}
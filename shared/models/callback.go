@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// CallbackState is the delivery state of a callback.
+type CallbackState string
+
+const (
+	CallbackStatePending   CallbackState = "pending"
+	CallbackStateDelivered CallbackState = "delivered"
+	// CallbackStateFailed is set once a callback ran out of attempts
+	CallbackStateFailed CallbackState = "failed"
+)
+
+// CallbackEvent names the state change a callback reports.
+type CallbackEvent string
+
+const (
+	CallbackEventCompleted CallbackEvent = "task.completed"
+	CallbackEventFailed    CallbackEvent = "task.failed"
+	CallbackEventCancelled CallbackEvent = "task.cancelled"
+)
+
+// CallbackEventFor returns the event reported when a task reaches state, ok
+// is false for the states that are not reported.
+func CallbackEventFor(state TaskState) (event CallbackEvent, ok bool) {
+	switch state {
+	case TaskStateCompleted:
+		return CallbackEventCompleted, true
+	case TaskStateFailed:
+		return CallbackEventFailed, true
+	case TaskStateCancelled:
+		return CallbackEventCancelled, true
+	}
+	return "", false
+}
+
+// CallbackPayload is the JSON body posted to the callback url of a task.
+type CallbackPayload struct {
+	Event       CallbackEvent   `json:"event"`
+	TaskID      string          `json:"task_id"`
+	Type        TaskType        `json:"type"`
+	State       TaskState       `json:"state"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	ErrorReason ErrorReason     `json:"error_reason,omitempty"`
+	RetryCount  int             `json:"retry_count"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+}
+
+// Callback is the delivery of an event to the callback url of a task, with
+// the log of its attempts.
+type Callback struct {
+	ID            int64             `json:"id" db:"id"`
+	TaskID        string            `json:"task_id" db:"task_id"`
+	Event         CallbackEvent     `json:"event" db:"event"`
+	URL           string            `json:"url" db:"url"`
+	Payload       json.RawMessage   `json:"payload" db:"payload"`
+	State         CallbackState     `json:"state" db:"state"`
+	Attempts      int               `json:"attempts" db:"attempts"`
+	NextAttemptAt *time.Time        `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	CreatedAt     time.Time         `json:"created_at" db:"created_at"`
+	DeliveredAt   *time.Time        `json:"delivered_at,omitempty" db:"delivered_at"`
+	AttemptLog    []CallbackAttempt `json:"attempt_log" db:"-"`
+}
+
+// CallbackAttempt records a single delivery attempt. StatusCode is 0 when no
+// response was received.
+type CallbackAttempt struct {
+	Attempt     int       `json:"attempt" db:"attempt"`
+	StatusCode  int       `json:"status_code,omitempty" db:"status_code"`
+	Error       string    `json:"error,omitempty" db:"error"`
+	DurationMs  int64     `json:"duration_ms" db:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at" db:"attempted_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// DeadLetter is a task that failed for good and waits to be inspected,
+// replayed or purged.
+type DeadLetter struct {
+	TaskID      string      `json:"task_id" db:"task_id"`
+	TaskType    TaskType    `json:"task_type" db:"task_type"`
+	Error       string      `json:"error,omitempty" db:"error"`
+	ErrorReason ErrorReason `json:"error_reason,omitempty" db:"error_reason"`
+	RetryCount  int         `json:"retry_count" db:"retry_count"`
+	CreatedAt   time.Time   `json:"dead_lettered_at" db:"created_at"`
+	// Task and Attempts are set when a single dead letter is inspected
+	Task     *Task          `json:"task,omitempty"`
+	Attempts []*TaskAttempt `json:"attempts,omitempty"`
+}
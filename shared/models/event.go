@@ -0,0 +1,76 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// TaskEventType identifies what happened to a task.
+type TaskEventType string
+
+const (
+	// TaskEventRecovered is logged when a task orphaned by a dead worker is
+	// requeued or failed by the reaper, the message says why
+	TaskEventRecovered TaskEventType = "recovered"
+	// TaskEventStateChanged is logged on every state transition, including
+	// the creation of the task
+	TaskEventStateChanged TaskEventType = "state_changed"
+	// TaskEventWorkerAssigned is logged when a worker picks up the task
+	TaskEventWorkerAssigned TaskEventType = "worker_assigned"
+	// TaskEventProgress is logged when a running task reports its progress
+	TaskEventProgress TaskEventType = "progress"
+	// TaskEventRetried is logged when a task is retried by hand, the message
+	// says what was changed and why
+	TaskEventRetried TaskEventType = "retried"
+	// TaskEventUpdated is logged when a task that has not started yet is
+	// changed, the message says what was changed
+	TaskEventUpdated TaskEventType = "updated"
+)
+
+// TaskEvent describes something that happened to a task. ID is set on the
+// events of the task event log, in the order they were logged.
+type TaskEvent struct {
+	ID            int64         `json:"id,omitempty"`
+	Type          TaskEventType `json:"type"`
+	TaskID        string        `json:"task_id"`
+	TaskType      TaskType      `json:"task_type,omitempty"`
+	State         TaskState     `json:"state"`
+	PreviousState TaskState     `json:"previous_state,omitempty"`
+	WorkerID      string        `json:"worker_id,omitempty"`
+	Reason        string        `json:"reason,omitempty"`
+	// Progress is a percentage, Message describes the step, for progress events
+	Progress  *float64  `json:"progress,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func NewTaskEvent(eventType TaskEventType, taskID string, state TaskState) *TaskEvent {
+	return &TaskEvent{
+		Type:      eventType,
+		TaskID:    taskID,
+		State:     state,
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+// TaskEventFilter selects events, an empty field matches everything.
+type TaskEventFilter struct {
+	TaskID    string
+	Types     []TaskEventType
+	TaskTypes []TaskType
+	States    []TaskState
+}
+
+// Matches reports whether the event is selected by the filter.
+func (f TaskEventFilter) Matches(event *TaskEvent) bool {
+	if f.TaskID != "" && event.TaskID != f.TaskID {
+		return false
+	}
+	return matches(f.Types, event.Type) &&
+		matches(f.TaskTypes, event.TaskType) &&
+		matches(f.States, event.State)
+}
+
+func matches[T comparable](values []T, value T) bool {
+	return len(values) == 0 || slices.Contains(values, value)
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskEventFilter_Matches(t *testing.T) {
+	event := &TaskEvent{
+		Type:     TaskEventStateChanged,
+		TaskID:   "task-1",
+		TaskType: TaskTypeEmailSend,
+		State:    TaskStateRunning,
+	}
+
+	assert.True(t, TaskEventFilter{}.Matches(event))
+	assert.True(t, TaskEventFilter{TaskID: "task-1"}.Matches(event))
+	assert.False(t, TaskEventFilter{TaskID: "task-2"}.Matches(event))
+	assert.True(t, TaskEventFilter{
+		Types:     []TaskEventType{TaskEventProgress, TaskEventStateChanged},
+		TaskTypes: []TaskType{TaskTypeEmailSend},
+		States:    []TaskState{TaskStateRunning, TaskStateCompleted},
+	}.Matches(event))
+	assert.False(t, TaskEventFilter{Types: []TaskEventType{TaskEventProgress}}.Matches(event))
+	assert.False(t, TaskEventFilter{TaskTypes: []TaskType{TaskTypeHTTPRequest}}.Matches(event))
+	assert.False(t, TaskEventFilter{States: []TaskState{TaskStateCompleted}}.Matches(event))
+}
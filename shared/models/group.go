@@ -0,0 +1,73 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Group tracks a set of tasks until all of them have finished. OnComplete,
+// when set, is created exactly once at that point, OnCompleteTaskID is the
+// task it became.
+type Group struct {
+	ID               string        `json:"id" db:"id"`
+	Name             string        `json:"name" db:"name"`
+	OnComplete       *TaskTemplate `json:"on_complete,omitempty" db:"on_complete"`
+	OnCompleteTaskID string        `json:"on_complete_task_id,omitempty" db:"on_complete_task_id"`
+	CreatedAt        time.Time     `json:"created_at" db:"created_at"`
+	CompletedAt      *time.Time    `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+func NewGroup(name string, onComplete *TaskTemplate) *Group {
+	return &Group{
+		ID:         uuid.New().String(),
+		Name:       name,
+		OnComplete: onComplete,
+		CreatedAt:  time.Now().UTC(),
+	}
+}
+
+// GroupError aggregates the members of a group that failed the same way,
+// TaskIDs holds a sample of them.
+type GroupError struct {
+	Reason  ErrorReason `json:"reason,omitempty"`
+	Error   string      `json:"error"`
+	Count   int         `json:"count"`
+	TaskIDs []string    `json:"task_ids"`
+}
+
+// GroupStatus is a group together with the progress of its members.
+// Progress is the percentage of members that finished.
+type GroupStatus struct {
+	*Group
+	Total    int               `json:"total"`
+	Finished int               `json:"finished"`
+	Progress float64           `json:"progress"`
+	Counts   map[TaskState]int `json:"counts"`
+	Errors   []GroupError      `json:"errors"`
+}
+
+func NewGroupStatus(group *Group, counts map[TaskState]int, finished int, errors []GroupError) *GroupStatus {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+
+	progress := 100.0
+	if total > 0 {
+		progress = math.Round(float64(finished)*1000/float64(total)) / 10
+	}
+	if errors == nil {
+		errors = []GroupError{}
+	}
+
+	return &GroupStatus{
+		Group:    group,
+		Total:    total,
+		Finished: finished,
+		Progress: progress,
+		Counts:   counts,
+		Errors:   errors,
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewGroupStatus(t *testing.T) {
+	group := NewGroup("import", nil)
+
+	status := NewGroupStatus(group, map[TaskState]int{
+		TaskStateCompleted: 1,
+		TaskStateFailed:    1,
+		TaskStateRunning:   1,
+	}, 2, nil)
+	assert.Equal(t, 3, status.Total)
+	assert.Equal(t, 66.7, status.Progress)
+	assert.NotNil(t, status.Errors)
+
+	empty := NewGroupStatus(group, map[TaskState]int{}, 0, nil)
+	assert.Equal(t, 100.0, empty.Progress)
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/cron"
+	"github.com/google/uuid"
+)
+
+// OverlapPolicy decides whether a schedule fires while the task created by its
+// previous firing has not finished yet.
+type OverlapPolicy string
+
+const (
+	OverlapAllow OverlapPolicy = "allow"
+	OverlapSkip  OverlapPolicy = "skip"
+)
+
+// MisfirePolicy decides what happens to the ticks a schedule missed, e.g.
+// because no scheduler was running when they were due.
+type MisfirePolicy string
+
+const (
+	// MisfireFireOnce creates a single task for all the missed ticks
+	MisfireFireOnce MisfirePolicy = "fire_once"
+	// MisfireFireAll creates one task per missed tick, up to the catch-up limit
+	MisfireFireAll MisfirePolicy = "fire_all"
+	// MisfireSkip drops the missed ticks and waits for the next one
+	MisfireSkip MisfirePolicy = "skip"
+)
+
+// TaskTemplate describes the tasks a schedule creates.
+type TaskTemplate struct {
+	Type           TaskType        `json:"type"`
+	Payload        json.RawMessage `json:"payload"`
+	Priority       int             `json:"priority"`
+	MaxRetries     int             `json:"max_retries"`
+	TimeoutSeconds int             `json:"timeout_seconds,omitempty"`
+}
+
+// NewTask creates a pending task from the template.
+func (t TaskTemplate) NewTask() *Task {
+	task := NewTask(t.Type, t.Payload, t.Priority)
+	task.MaxRetries = t.MaxRetries
+	task.TimeoutSeconds = t.TimeoutSeconds
+	return task
+}
+
+// Schedule creates a task from its template every time its cron expression
+// fires.
+type Schedule struct {
+	ID            string        `json:"id" db:"id"`
+	Name          string        `json:"name" db:"name"`
+	CronExpr      string        `json:"cron" db:"cron_expr"`
+	Timezone      string        `json:"timezone" db:"timezone"`
+	Task          TaskTemplate  `json:"task"`
+	Enabled       bool          `json:"enabled" db:"enabled"`
+	OverlapPolicy OverlapPolicy `json:"overlap_policy" db:"overlap_policy"`
+	MisfirePolicy MisfirePolicy `json:"misfire_policy" db:"misfire_policy"`
+	NextRunAt     *time.Time    `json:"next_run_at,omitempty" db:"next_run_at"`
+	LastRunAt     *time.Time    `json:"last_run_at,omitempty" db:"last_run_at"`
+	LastTaskID    string        `json:"last_task_id,omitempty" db:"last_task_id"`
+	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+func NewSchedule(name, cronExpr, timezone string, task TaskTemplate) *Schedule {
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	now := time.Now().UTC()
+	return &Schedule{
+		ID:            uuid.New().String(),
+		Name:          name,
+		CronExpr:      cronExpr,
+		Timezone:      timezone,
+		Task:          task,
+		Enabled:       true,
+		OverlapPolicy: OverlapAllow,
+		MisfirePolicy: MisfireFireOnce,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// Validate checks the cron expression, timezone and policies.
+func (s *Schedule) Validate() error {
+	if _, err := cron.Parse(s.CronExpr); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", s.Timezone, err)
+	}
+
+	switch s.OverlapPolicy {
+	case OverlapAllow, OverlapSkip:
+	default:
+		return fmt.Errorf("invalid overlap policy: %s", s.OverlapPolicy)
+	}
+	switch s.MisfirePolicy {
+	case MisfireFireOnce, MisfireFireAll, MisfireSkip:
+	default:
+		return fmt.Errorf("invalid misfire policy: %s", s.MisfirePolicy)
+	}
+	return nil
+}
+
+// NextRun returns the first tick after t, evaluated in the schedule's timezone.
+// It returns the zero time when the expression never fires.
+func (s *Schedule) NextRun(after time.Time) (time.Time, error) {
+	spec, err := cron.Parse(s.CronExpr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timezone %q: %w", s.Timezone, err)
+	}
+
+	next := spec.Next(after.In(loc))
+	if next.IsZero() {
+		return next, nil
+	}
+	return next.UTC(), nil
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleValidate(t *testing.T) {
+	template := TaskTemplate{Type: TaskTypeEmailSend, Payload: json.RawMessage(`{}`), Priority: 5}
+
+	schedule := NewSchedule("nightly", "0 2 * * *", "", template)
+	assert.Equal(t, "UTC", schedule.Timezone)
+	assert.NoError(t, schedule.Validate())
+
+	schedule.CronExpr = "0 2 * *"
+	assert.Error(t, schedule.Validate())
+
+	schedule.CronExpr = "0 2 * * *"
+	schedule.Timezone = "Mars/Olympus_Mons"
+	assert.Error(t, schedule.Validate())
+
+	schedule.Timezone = "UTC"
+	schedule.MisfirePolicy = "sometimes"
+	assert.Error(t, schedule.Validate())
+}
+
+func TestScheduleNextRun(t *testing.T) {
+	schedule := NewSchedule("hourly", "@hourly", "UTC", TaskTemplate{Type: TaskTypeEmailSend})
+
+	next, err := schedule.NextRun(time.Date(2025, 1, 15, 10, 7, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC), next)
+}
+
+func TestTaskTemplateNewTask(t *testing.T) {
+	template := TaskTemplate{
+		Type:           TaskTypeHTTPRequest,
+		Payload:        json.RawMessage(`{"url": "https://example.com"}`),
+		Priority:       7,
+		MaxRetries:     1,
+		TimeoutSeconds: 30,
+	}
+
+	task := template.NewTask()
+	assert.NotEmpty(t, task.ID)
+	assert.Equal(t, TaskStatePending, task.State)
+	assert.Equal(t, 7, task.Priority)
+	assert.Equal(t, 1, task.MaxRetries)
+	assert.Equal(t, 30, task.TimeoutSeconds)
+}
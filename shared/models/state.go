@@ -0,0 +1,94 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	// ErrInvalidTransition is returned when a task is asked to move to a state
+	// that is not reachable from its current one.
+	ErrInvalidTransition = errors.New("invalid task state transition")
+
+	// ErrConflict is returned when a compare-and-set transition did not apply
+	// because the task changed state concurrently.
+	ErrConflict = errors.New("task state changed concurrently")
+)
+
+// transitions lists the states reachable from each state. States without an
+// entry are terminal.
+var transitions = map[TaskState][]TaskState{
+	// a task can fail before it ever runs, e.g. when it cannot be started, and
+	// is held back again when its run_at is moved to the future
+	TaskStatePending: {TaskStateRunning, TaskStateCancelled, TaskStateFailed, TaskStateScheduled},
+	// scheduled tasks become pending once due, workers polling the database
+	// start them directly
+	TaskStateScheduled: {TaskStatePending, TaskStateRunning, TaskStateCancelled},
+	// blocked tasks wait for their dependencies, they are released or, when a
+	// dependency fails, failed or skipped depending on their failure policy
+	TaskStateBlocked: {TaskStatePending, TaskStateScheduled, TaskStateFailed, TaskStateSkipped, TaskStateCancelled},
+	// running goes back to pending when a worker hands the task back
+	TaskStateRunning: {TaskStateCompleted, TaskStateFailed, TaskStatePending, TaskStateCancelled},
+	TaskStateFailed:  {TaskStatePending},
+}
+
+// CanTransition reports whether a task may move from one state to another.
+func CanTransition(from, to TaskState) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatesTo returns the states a task may move to the given state from, sorted.
+func StatesTo(to TaskState) []TaskState {
+	var from []TaskState
+	for state := range transitions {
+		if CanTransition(state, to) {
+			from = append(from, state)
+		}
+	}
+	slices.Sort(from)
+	return from
+}
+
+// ValidateTransition returns ErrInvalidTransition when the move is not allowed.
+func ValidateTransition(from, to TaskState) error {
+	if !CanTransition(from, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+	}
+	return nil
+}
+
+// IsTerminal reports whether no transition leaves the state.
+func (s TaskState) IsTerminal() bool {
+	return len(transitions[s]) == 0
+}
+
+// retryableStates are the states a task can be retried from by hand, which
+// moves it back to pending outside of the transitions above: failed, whether
+// retries are left or not, and the terminal states
+var retryableStates = []TaskState{TaskStateCancelled, TaskStateCompleted, TaskStateFailed, TaskStateSkipped}
+
+// editableStates are the states of the tasks that have not started yet, whose
+// priority, retries and due time may still change, and their payload while
+// pending
+var editableStates = []TaskState{TaskStateBlocked, TaskStatePending, TaskStateScheduled}
+
+// CanEdit reports whether a task in the state may still be changed.
+func CanEdit(state TaskState) bool {
+	return slices.Contains(editableStates, state)
+}
+
+// CanRetry reports whether a task in the state may be retried by hand.
+func CanRetry(state TaskState) bool {
+	return slices.Contains(retryableStates, state)
+}
+
+// RetryableStates returns the states a task may be retried from, sorted.
+func RetryableStates() []TaskState {
+	return slices.Clone(retryableStates)
+}
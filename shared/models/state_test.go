@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to TaskState
+		allowed  bool
+	}{
+		{TaskStatePending, TaskStateRunning, true},
+		{TaskStatePending, TaskStateCancelled, true},
+		{TaskStatePending, TaskStateScheduled, true},
+		{TaskStateRunning, TaskStateCompleted, true},
+		{TaskStateRunning, TaskStateFailed, true},
+		{TaskStateRunning, TaskStatePending, true},
+		{TaskStateFailed, TaskStatePending, true},
+		{TaskStateScheduled, TaskStatePending, true},
+		{TaskStateScheduled, TaskStateCancelled, true},
+		{TaskStateScheduled, TaskStateCompleted, false},
+		{TaskStatePending, TaskStateCompleted, false},
+		{TaskStateCompleted, TaskStateRunning, false},
+		{TaskStateCompleted, TaskStateCancelled, false},
+		{TaskStateCancelled, TaskStateRunning, false},
+		{TaskStateCancelled, TaskStateCompleted, false},
+		{TaskStateFailed, TaskStateCancelled, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, CanTransition(tt.from, tt.to), "%s -> %s", tt.from, tt.to)
+	}
+}
+
+func TestValidateTransition(t *testing.T) {
+	assert.NoError(t, ValidateTransition(TaskStatePending, TaskStateRunning))
+
+	err := ValidateTransition(TaskStateCancelled, TaskStateCompleted)
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+	assert.Contains(t, err.Error(), "cancelled -> completed")
+}
+
+func TestStatesTo(t *testing.T) {
+	assert.Equal(t,
+		[]TaskState{TaskStateBlocked, TaskStatePending, TaskStateRunning, TaskStateScheduled},
+		StatesTo(TaskStateCancelled),
+	)
+	assert.Empty(t, StatesTo("unknown"))
+}
+
+func TestIsTerminal(t *testing.T) {
+	assert.True(t, TaskStateCompleted.IsTerminal())
+	assert.True(t, TaskStateCancelled.IsTerminal())
+	assert.False(t, TaskStatePending.IsTerminal())
+	assert.False(t, TaskStateRunning.IsTerminal())
+	assert.False(t, TaskStateFailed.IsTerminal())
+	assert.False(t, TaskStateScheduled.IsTerminal())
+}
+
+func TestCanRetry(t *testing.T) {
+	for _, state := range RetryableStates() {
+		assert.True(t, CanRetry(state), state)
+		assert.True(t, state == TaskStateFailed || state.IsTerminal(), state)
+	}
+	assert.False(t, CanRetry(TaskStatePending))
+	assert.False(t, CanRetry(TaskStateRunning))
+	assert.False(t, CanRetry(TaskStateBlocked))
+	assert.False(t, CanRetry(TaskStateScheduled))
+}
+
+func TestCanEdit(t *testing.T) {
+	assert.True(t, CanEdit(TaskStatePending))
+	assert.True(t, CanEdit(TaskStateScheduled))
+	assert.True(t, CanEdit(TaskStateBlocked))
+	assert.False(t, CanEdit(TaskStateRunning))
+	assert.False(t, CanEdit(TaskStateFailed))
+	assert.False(t, CanEdit(TaskStateCompleted))
+	assert.False(t, CanEdit(TaskStateCancelled))
+}
+
+func TestTaskMarkCompleted_CancelledTask(t *testing.T) {
+	task := NewTask(TaskTypeEmailSend, nil, 5)
+	require.NoError(t, task.TransitionTo(TaskStateCancelled))
+
+	err := task.MarkCompleted(json.RawMessage(`{"sent": true}`))
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+	assert.Equal(t, TaskStateCancelled, task.State)
+	assert.Nil(t, task.Result)
+	assert.Nil(t, task.CompletedAt)
+}
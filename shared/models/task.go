@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,10 +13,13 @@ type TaskState string
 
 const (
 	TaskStatePending   TaskState = "pending"
+	TaskStateScheduled TaskState = "scheduled"
+	TaskStateBlocked   TaskState = "blocked"
 	TaskStateRunning   TaskState = "running"
 	TaskStateCompleted TaskState = "completed"
 	TaskStateFailed    TaskState = "failed"
 	TaskStateCancelled TaskState = "cancelled"
+	TaskStateSkipped   TaskState = "skipped"
 )
 
 // TaskType represents the type of a task to be executed.
@@ -28,6 +32,21 @@ const (
 	TaskTypeLongRunning    TaskType = "long_running"
 )
 
+// ErrorReason classifies why a task attempt failed.
+type ErrorReason string
+
+const (
+	ErrorReasonHandler    ErrorReason = "handler_error"
+	ErrorReasonTimedOut   ErrorReason = "timed_out"
+	ErrorReasonWorkerLost ErrorReason = "worker_lost"
+	// ErrorReasonDependencyFailed is set on tasks that never ran because a
+	// task they depend on did not complete
+	ErrorReasonDependencyFailed ErrorReason = "dependency_failed"
+	// ErrorReasonInvalidPayload is set on tasks whose payload references a
+	// parent result that does not exist, they are not retried
+	ErrorReasonInvalidPayload ErrorReason = "invalid_payload"
+)
+
 // Task represents a task in the system.
 type Task struct {
 	ID          string          `json:"id" db:"id"`
@@ -37,12 +56,36 @@ type Task struct {
 	State       TaskState       `json:"state" db:"state"`
 	Result      json.RawMessage `json:"result,omitempty" db:"result"`
 	Error       string          `json:"error,omitempty" db:"error"`
+	ErrorReason ErrorReason     `json:"error_reason,omitempty" db:"error_reason"`
 	RetryCount  int             `json:"retry_count" db:"retry_count"`
 	MaxRetries  int             `json:"max_retries" db:"max_retries"`
 	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
 	StartedAt   *time.Time      `json:"started_at,omitempty" db:"started_at"`
 	CompletedAt *time.Time      `json:"completed_at,omitempty" db:"completed_at"`
 	WorkerID    string          `json:"worker_id,omitempty" db:"worker_id"`
+	// TimeoutSeconds bounds a single execution, 0 uses the worker defaults
+	TimeoutSeconds int `json:"timeout_seconds,omitempty" db:"timeout_seconds"`
+	// NotBefore is when a scheduled task becomes eligible to run
+	NotBefore *time.Time `json:"not_before,omitempty" db:"not_before"`
+	// DependsOn lists the tasks that must finish before this one is released
+	// from the blocked state, FailurePolicy says what happens if one of them fails
+	DependsOn     []string      `json:"depends_on,omitempty" db:"-"`
+	FailurePolicy FailurePolicy `json:"failure_policy,omitempty" db:"failure_policy"`
+	WorkflowID    string        `json:"workflow_id,omitempty" db:"workflow_id"`
+	WorkflowKey   string        `json:"workflow_key,omitempty" db:"workflow_key"`
+	// UniqueKey keeps two tasks of the same type for the same logical entity
+	// from coexisting, UniqueScope says for how long (see MakeUnique)
+	UniqueKey   string      `json:"unique_key,omitempty" db:"unique_key"`
+	UniqueScope UniqueScope `json:"unique_scope,omitempty" db:"unique_scope"`
+	UniqueUntil *time.Time  `json:"unique_until,omitempty" db:"unique_until"`
+	// BatchID is set on tasks submitted together through the batch endpoint
+	BatchID string `json:"batch_id,omitempty" db:"batch_id"`
+	// GroupID is set on the members of a group, see Group
+	GroupID string `json:"group_id,omitempty" db:"group_id"`
+	// CallbackURL receives a Callback when the task finishes, signed with
+	// CallbackSecret if set, which is never returned by the API
+	CallbackURL    string `json:"callback_url,omitempty" db:"callback_url"`
+	CallbackSecret string `json:"-" db:"callback_secret"`
 }
 
 func NewTask(taskType TaskType, payload json.RawMessage, priority int) *Task {
@@ -64,30 +107,98 @@ func NewTask(taskType TaskType, payload json.RawMessage, priority int) *Task {
 	}
 }
 
+// ScheduleAt holds the task back until runAt. Tasks that are already due stay
+// pending.
+func (t *Task) ScheduleAt(runAt time.Time) {
+	if !runAt.After(time.Now()) {
+		return
+	}
+	notBefore := runAt.UTC()
+	t.NotBefore = &notBefore
+	t.State = TaskStateScheduled
+}
+
+// BlockOn makes the task wait for the given tasks. It stays blocked until they
+// have all finished, a due time set by ScheduleAt applies once it is released.
+func (t *Task) BlockOn(dependsOn []string, policy FailurePolicy) {
+	if len(dependsOn) == 0 {
+		return
+	}
+	if policy == "" {
+		policy = FailurePolicyFail
+	}
+	t.DependsOn = dependsOn
+	t.FailurePolicy = policy
+	t.State = TaskStateBlocked
+}
+
 func (t *Task) CanRetry() bool {
 	return t.RetryCount < t.MaxRetries
 }
 
-func (t *Task) MarkStarted(workerID string) {
+// IsFinished reports whether the task will not run again: it is in a terminal
+// state or it failed with no retries left.
+func (t *Task) IsFinished() bool {
+	if t.State == TaskStateFailed {
+		return !t.CanRetry()
+	}
+	return t.State.IsTerminal()
+}
+
+// FinishedSQL is the SQL condition IsFinished checks, on the task columns
+// qualified by the table alias, unqualified when alias is empty.
+func FinishedSQL(alias string) string {
+	if alias != "" {
+		alias += "."
+	}
+	return fmt.Sprintf(`(%[1]sstate IN ('completed', 'cancelled', 'skipped') OR (%[1]sstate = 'failed' AND %[1]sretry_count >= %[1]smax_retries))`, alias)
+}
+
+// TransitionTo moves the task to a new state, or returns ErrInvalidTransition
+// and leaves it untouched when the move is not allowed.
+func (t *Task) TransitionTo(state TaskState) error {
+	if err := ValidateTransition(t.State, state); err != nil {
+		return err
+	}
+	t.State = state
+	return nil
+}
+
+func (t *Task) MarkStarted(workerID string) error {
+	if err := t.TransitionTo(TaskStateRunning); err != nil {
+		return err
+	}
 	now := time.Now().UTC()
-	t.State = TaskStateRunning
 	t.StartedAt = &now
 	t.WorkerID = workerID
+	return nil
 }
 
-func (t *Task) MarkCompleted(result json.RawMessage) {
+func (t *Task) MarkCompleted(result json.RawMessage) error {
+	if err := t.TransitionTo(TaskStateCompleted); err != nil {
+		return err
+	}
 	now := time.Now().UTC()
-	t.State = TaskStateCompleted
 	t.Result = result
 	t.CompletedAt = &now
+	return nil
 }
 
-func (t *Task) MarkFailed(err error) {
+func (t *Task) MarkFailed(err error) error {
+	if err := t.TransitionTo(TaskStateFailed); err != nil {
+		return err
+	}
 	now := time.Now().UTC()
-	t.State = TaskStateFailed
 	t.Error = err.Error()
 	t.CompletedAt = &now
 	t.RetryCount++
+	return nil
+}
+
+// Timeout returns the execution timeout requested for the task, or 0 when the
+// worker defaults apply.
+func (t *Task) Timeout() time.Duration {
+	return time.Duration(t.TimeoutSeconds) * time.Second
 }
 
 func (t *Task) Duration() time.Duration {
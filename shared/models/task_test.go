@@ -96,3 +96,20 @@ func TestTaskDuration(t *testing.T) {
 	duration := task.Duration()
 	assert.Greater(t, duration, 0*time.Second)
 }
+
+func TestTaskScheduleAt(t *testing.T) {
+	task := NewTask(TaskTypeEmailSend, nil, 5)
+	runAt := time.Now().Add(time.Hour)
+
+	task.ScheduleAt(runAt)
+	assert.Equal(t, TaskStateScheduled, task.State)
+	if assert.NotNil(t, task.NotBefore) {
+		assert.True(t, runAt.Equal(*task.NotBefore))
+	}
+
+	// a run_at in the past runs right away
+	due := NewTask(TaskTypeEmailSend, nil, 5)
+	due.ScheduleAt(time.Now().Add(-time.Minute))
+	assert.Equal(t, TaskStatePending, due.State)
+	assert.Nil(t, due.NotBefore)
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrDuplicateTask is returned when a task cannot be created because another
+// task of the same type holds its unique key.
+var ErrDuplicateTask = errors.New("duplicate task")
+
+// UniqueScope says how long a task holds its unique key, i.e. while a new task
+// of the same type with the same key conflicts with it.
+type UniqueScope string
+
+const (
+	// UniqueScopePending holds the key until the task starts running
+	UniqueScopePending UniqueScope = "pending"
+	// UniqueScopePendingOrRunning holds the key until the task has finished
+	UniqueScopePendingOrRunning UniqueScope = "pending_or_running"
+	// UniqueScopeWindow holds the key for a fixed period after the task was
+	// created, whatever its state
+	UniqueScopeWindow UniqueScope = "window"
+)
+
+func (s UniqueScope) Valid() bool {
+	switch s {
+	case UniqueScopePending, UniqueScopePendingOrRunning, UniqueScopeWindow:
+		return true
+	}
+	return false
+}
+
+// ConflictPolicy says what creating a task does when its unique key is held.
+type ConflictPolicy string
+
+const (
+	// ConflictReject fails the creation with ErrDuplicateTask
+	ConflictReject ConflictPolicy = "reject"
+	// ConflictReturnExisting returns the task holding the key instead
+	ConflictReturnExisting ConflictPolicy = "return_existing"
+	// ConflictReplace replaces the payload of the task holding the key, as
+	// long as it has not started running
+	ConflictReplace ConflictPolicy = "replace"
+)
+
+func (p ConflictPolicy) Valid() bool {
+	switch p {
+	case ConflictReject, ConflictReturnExisting, ConflictReplace:
+		return true
+	}
+	return false
+}
+
+// MakeUnique gives the task a unique key within its type. window is only used
+// by UniqueScopeWindow, an empty scope holds the key while pending or running.
+func (t *Task) MakeUnique(key string, scope UniqueScope, window time.Duration) {
+	if key == "" {
+		return
+	}
+	if scope == "" {
+		scope = UniqueScopePendingOrRunning
+	}
+	t.UniqueKey = key
+	t.UniqueScope = scope
+	if scope == UniqueScopeWindow {
+		until := t.CreatedAt.Add(window)
+		t.UniqueUntil = &until
+	}
+}
+
+// HoldsUniqueKey reports whether a new task with the same type and unique key
+// conflicts with this one at the given time. A failed task that will be
+// retried counts as pending.
+func (t *Task) HoldsUniqueKey(now time.Time) bool {
+	if t.UniqueKey == "" {
+		return false
+	}
+
+	waiting := t.State == TaskStatePending || t.State == TaskStateScheduled || t.State == TaskStateBlocked ||
+		(t.State == TaskStateFailed && t.CanRetry())
+
+	switch t.UniqueScope {
+	case UniqueScopeWindow:
+		return t.UniqueUntil != nil && t.UniqueUntil.After(now)
+	case UniqueScopePending:
+		return waiting
+	default:
+		return waiting || t.State == TaskStateRunning
+	}
+}
+
+// QueueUniqueKey is the key the queue deduplicates the task by, empty when
+// the task has no unique key
+func (t *Task) QueueUniqueKey() string {
+	if t.UniqueKey == "" {
+		return ""
+	}
+	return string(t.Type) + ":" + t.UniqueKey
+}
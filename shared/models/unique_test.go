@@ -0,0 +1,62 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskMakeUnique(t *testing.T) {
+	task := NewTask(TaskTypeDataProcessing, nil, 5)
+	task.MakeUnique("", UniqueScopePending, 0)
+	assert.Empty(t, task.UniqueKey)
+	assert.Empty(t, task.QueueUniqueKey())
+
+	task.MakeUnique("customer-42", "", 0)
+	assert.Equal(t, UniqueScopePendingOrRunning, task.UniqueScope)
+	assert.Nil(t, task.UniqueUntil)
+	assert.Equal(t, "data_processing:customer-42", task.QueueUniqueKey())
+
+	task.MakeUnique("customer-42", UniqueScopeWindow, 10*time.Minute)
+	require.NotNil(t, task.UniqueUntil)
+	assert.Equal(t, task.CreatedAt.Add(10*time.Minute), *task.UniqueUntil)
+}
+
+func TestTaskHoldsUniqueKey(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		scope UniqueScope
+		state TaskState
+		holds bool
+	}{
+		{UniqueScopePending, TaskStatePending, true},
+		{UniqueScopePending, TaskStateScheduled, true},
+		{UniqueScopePending, TaskStateRunning, false},
+		{UniqueScopePendingOrRunning, TaskStateBlocked, true},
+		{UniqueScopePendingOrRunning, TaskStateRunning, true},
+		{UniqueScopePendingOrRunning, TaskStateCompleted, false},
+		{UniqueScopeWindow, TaskStateCompleted, true},
+	}
+
+	for _, tt := range tests {
+		task := NewTask(TaskTypeDataProcessing, nil, 5)
+		task.MakeUnique("customer-42", tt.scope, time.Minute)
+		task.State = tt.state
+		assert.Equal(t, tt.holds, task.HoldsUniqueKey(now), "%s while %s", tt.scope, tt.state)
+	}
+
+	// a failed task holds the key only while it will be retried
+	task := NewTask(TaskTypeDataProcessing, nil, 5)
+	task.MakeUnique("customer-42", UniqueScopePending, 0)
+	task.State = TaskStateFailed
+	assert.True(t, task.HoldsUniqueKey(now))
+	task.RetryCount = task.MaxRetries
+	assert.False(t, task.HoldsUniqueKey(now))
+
+	// the window is over
+	task.MakeUnique("customer-42", UniqueScopeWindow, time.Minute)
+	assert.False(t, task.HoldsUniqueKey(now.Add(2*time.Minute)))
+}
@@ -7,23 +7,26 @@ import (
 	"github.com/google/uuid"
 )
 
-// WorkerStatus represents the current status of a worker
+// WorkerStatus represents the current status of a worker.
+// The values match the valid_status constraint of the workers table.
 type WorkerStatus string
 
 const (
-	WorkerStatusActive   WorkerStatus = "ACTIVE"
-	WorkerStatusIdle     WorkerStatus = "IDLE"
-	WorkerStatusShutdown WorkerStatus = "SHUTDOWN"
+	WorkerStatusActive   WorkerStatus = "active"
+	WorkerStatusIdle     WorkerStatus = "idle"
+	WorkerStatusShutdown WorkerStatus = "shutdown"
 )
 
 // Worker represents a worker node in the system.
 type Worker struct {
-	ID            string       `json:"id" db:"id"`
-	Status	      WorkerStatus `json:"status" db:"status"`
-	CurrentTaskID string       `json:"current_task_id,omitempty" db:"current_task_id"`
-	LastHeartbeat time.Time    `json:"last_heartbeat" db:"last_heartbeat"`
-	TaskTypes     []TaskType   `json:"task_types" db:"task_types"` // Types of tasks this worker can handle
-	CreatedAt     time.Time    `json:"created_at" db:"created_at"`
+	ID             string       `json:"id" db:"id"`
+	Status         WorkerStatus `json:"status" db:"status"`
+	Host           string       `json:"host" db:"host"`
+	Version        string       `json:"version" db:"version"`
+	CurrentTaskIDs []string     `json:"current_task_ids" db:"current_task_ids"` // Tasks the worker is running right now
+	LastHeartbeat  time.Time    `json:"last_heartbeat" db:"last_heartbeat"`
+	TaskTypes      []TaskType   `json:"task_types" db:"task_types"` // Types of tasks this worker can handle
+	CreatedAt      time.Time    `json:"created_at" db:"created_at"`
 }
 
 func NewWorker(taskTypes []TaskType) *Worker {
@@ -48,3 +48,10 @@ func TestMarkHeartbeat(t *testing.T) {
 	assert.True(t, worker.LastHeartbeat.After(originalHeartbeat))
 }
 
+
+func TestWorkerStatus_MatchesSchema(t *testing.T) {
+	// the workers table only accepts lowercase statuses
+	assert.Equal(t, WorkerStatus("active"), WorkerStatusActive)
+	assert.Equal(t, WorkerStatus("idle"), WorkerStatusIdle)
+	assert.Equal(t, WorkerStatus("shutdown"), WorkerStatusShutdown)
+}
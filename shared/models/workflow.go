@@ -0,0 +1,169 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrDependencyCycle is returned when tasks depend on each other in a loop.
+var ErrDependencyCycle = errors.New("dependency cycle")
+
+// FailurePolicy decides what happens to a blocked task when a task it depends
+// on fails, is cancelled or is skipped.
+type FailurePolicy string
+
+const (
+	// FailurePolicyFail fails the dependent task, and in turn its dependents
+	FailurePolicyFail FailurePolicy = "fail"
+	// FailurePolicySkip marks the dependent task as skipped
+	FailurePolicySkip FailurePolicy = "skip"
+	// FailurePolicyContinue runs the dependent task anyway once all of its
+	// dependencies have finished
+	FailurePolicyContinue FailurePolicy = "continue"
+)
+
+// Valid reports whether p is a known failure policy.
+func (p FailurePolicy) Valid() bool {
+	switch p {
+	case FailurePolicyFail, FailurePolicySkip, FailurePolicyContinue:
+		return true
+	default:
+		return false
+	}
+}
+
+// Workflow is a set of tasks submitted together whose dependencies form a DAG.
+type Workflow struct {
+	ID            string        `json:"id" db:"id"`
+	Name          string        `json:"name" db:"name"`
+	FailurePolicy FailurePolicy `json:"failure_policy" db:"failure_policy"`
+	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
+}
+
+func NewWorkflow(name string, policy FailurePolicy) *Workflow {
+	if policy == "" {
+		policy = FailurePolicyFail
+	}
+
+	return &Workflow{
+		ID:            uuid.New().String(),
+		Name:          name,
+		FailurePolicy: policy,
+		CreatedAt:     time.Now().UTC(),
+	}
+}
+
+// TopologicalOrder returns the keys of a dependency graph, given as the
+// dependencies of each node, so that every node comes after the nodes it
+// depends on. Nodes that do not depend on each other keep their key order. It
+// returns ErrDependencyCycle when the graph is not a DAG and an error when a
+// node depends on a key that is not in the graph.
+func TopologicalOrder(dependsOn map[string][]string) ([]string, error) {
+	keys := make([]string, 0, len(dependsOn))
+	for key := range dependsOn {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	marks := make(map[string]int, len(dependsOn))
+	order := make([]string, 0, len(dependsOn))
+
+	var visit func(key string, path []string) error
+	visit = func(key string, path []string) error {
+		switch marks[key] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("%w: %v", ErrDependencyCycle, append(path, key))
+		}
+
+		marks[key] = visiting
+		for _, dep := range dependsOn[key] {
+			if _, ok := dependsOn[dep]; !ok {
+				return fmt.Errorf("%s depends on unknown task %s", key, dep)
+			}
+			if err := visit(dep, append(path, key)); err != nil {
+				return err
+			}
+		}
+		marks[key] = done
+		order = append(order, key)
+		return nil
+	}
+
+	for _, key := range keys {
+		if err := visit(key, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// AggregateState sums up the states of a workflow's tasks as a single state:
+// pending until a task starts, running until every task has finished, then
+// completed, failed or cancelled.
+func AggregateState(tasks []*Task) TaskState {
+	finished, started := true, false
+	failed, cancelled := false, false
+
+	for _, task := range tasks {
+		if !task.IsFinished() {
+			finished = false
+		}
+		switch task.State {
+		case TaskStatePending, TaskStateScheduled, TaskStateBlocked:
+		default:
+			started = true
+		}
+		switch task.State {
+		case TaskStateFailed, TaskStateSkipped:
+			failed = true
+		case TaskStateCancelled:
+			cancelled = true
+		}
+	}
+
+	switch {
+	case !finished && started:
+		return TaskStateRunning
+	case !finished:
+		return TaskStatePending
+	case failed:
+		return TaskStateFailed
+	case cancelled:
+		return TaskStateCancelled
+	default:
+		return TaskStateCompleted
+	}
+}
+
+// WorkflowStatus is a workflow together with its tasks and their aggregate state.
+type WorkflowStatus struct {
+	*Workflow
+	State  TaskState         `json:"state"`
+	Counts map[TaskState]int `json:"counts"`
+	Tasks  []*Task           `json:"tasks"`
+}
+
+func NewWorkflowStatus(workflow *Workflow, tasks []*Task) *WorkflowStatus {
+	counts := make(map[TaskState]int)
+	for _, task := range tasks {
+		counts[task.State]++
+	}
+
+	return &WorkflowStatus{
+		Workflow: workflow,
+		State:    AggregateState(tasks),
+		Counts:   counts,
+		Tasks:    tasks,
+	}
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopologicalOrder(t *testing.T) {
+	// b and c run after a, d after both
+	order, err := TopologicalOrder(map[string][]string{
+		"d": {"b", "c"},
+		"c": {"a"},
+		"b": {"a"},
+		"a": nil,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, order)
+}
+
+func TestTopologicalOrder_Cycle(t *testing.T) {
+	_, err := TopologicalOrder(map[string][]string{
+		"a": {"c"},
+		"b": {"a"},
+		"c": {"b"},
+	})
+	assert.ErrorIs(t, err, ErrDependencyCycle)
+
+	_, err = TopologicalOrder(map[string][]string{"a": {"a"}})
+	assert.ErrorIs(t, err, ErrDependencyCycle)
+}
+
+func TestTopologicalOrder_UnknownDependency(t *testing.T) {
+	_, err := TopologicalOrder(map[string][]string{"a": {"missing"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown task missing")
+}
+
+func TestTaskBlockOn(t *testing.T) {
+	task := NewTask(TaskTypeEmailSend, nil, 5)
+	task.BlockOn(nil, "")
+	assert.Equal(t, TaskStatePending, task.State)
+
+	task.BlockOn([]string{"parent"}, "")
+	assert.Equal(t, TaskStateBlocked, task.State)
+	assert.Equal(t, FailurePolicyFail, task.FailurePolicy)
+	assert.True(t, CanTransition(TaskStateBlocked, TaskStatePending))
+	assert.True(t, CanTransition(TaskStateBlocked, TaskStateSkipped))
+	assert.False(t, CanTransition(TaskStateBlocked, TaskStateRunning))
+	assert.True(t, TaskStateSkipped.IsTerminal())
+}
+
+func TestAggregateState(t *testing.T) {
+	withState := func(states ...TaskState) []*Task {
+		tasks := make([]*Task, len(states))
+		for i, state := range states {
+			tasks[i] = NewTask(TaskTypeEmailSend, nil, 5)
+			tasks[i].State = state
+		}
+		return tasks
+	}
+
+	assert.Equal(t, TaskStatePending, AggregateState(withState(TaskStatePending, TaskStateBlocked)))
+	assert.Equal(t, TaskStateRunning, AggregateState(withState(TaskStateCompleted, TaskStateBlocked)))
+	assert.Equal(t, TaskStateCompleted, AggregateState(withState(TaskStateCompleted, TaskStateCompleted)))
+	assert.Equal(t, TaskStateFailed, AggregateState(withState(TaskStateCompleted, TaskStateSkipped)))
+	assert.Equal(t, TaskStateCancelled, AggregateState(withState(TaskStateCompleted, TaskStateCancelled)))
+
+	// a failed task that will be retried keeps the workflow running
+	tasks := withState(TaskStateCompleted, TaskStateFailed)
+	assert.Equal(t, TaskStateRunning, AggregateState(tasks))
+	tasks[1].RetryCount = tasks[1].MaxRetries
+	assert.Equal(t, TaskStateFailed, AggregateState(tasks))
+}
@@ -0,0 +1,288 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/config"
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// taskFinishedChannel carries the ids of the tasks that finished, postgres
+// delivers a notification only once the transaction that sent it commits.
+// taskEventsChannel carries the ids of the task events as they are logged,
+// notification payloads are too small for the events themselves.
+const (
+	taskFinishedChannel = "task_finished"
+	taskEventsChannel   = "task_events"
+)
+
+// eventBuffer is how many events a slow event subscriber may lag behind
+// before its subscription is dropped
+const eventBuffer = 256
+
+// TaskFinished notifies the listeners that a task finished. It must run in the
+// transaction that finishes the task.
+func TaskFinished(ctx context.Context, tx *sql.Tx, taskID string) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, taskFinishedChannel, taskID); err != nil {
+		return fmt.Errorf("failed to notify task finished: %w", err)
+	}
+	return nil
+}
+
+// Listener receives the task notifications over a single database connection
+// and fans them out: it wakes the goroutines waiting for a task to finish and
+// hands the task events to their subscribers, so any number of them costs one
+// connection.
+type Listener struct {
+	dsn string
+	db  *sql.DB
+	// loadEvent reads a logged event, it is replaced in tests
+	loadEvent func(ctx context.Context, id int64) (*models.TaskEvent, error)
+
+	mu      sync.Mutex
+	waiters map[string]map[chan struct{}]struct{}
+	events  map[chan *models.TaskEvent]struct{}
+}
+
+func NewListener(cfg config.DatabaseConfig) *Listener {
+	l := &Listener{
+		dsn:     cfg.DSN(),
+		waiters: make(map[string]map[chan struct{}]struct{}),
+		events:  make(map[chan *models.TaskEvent]struct{}),
+	}
+	l.loadEvent = l.queryEvent
+	return l
+}
+
+// Subscribe returns a channel that receives a value whenever the task may
+// have finished, and a function that ends the subscription. Notifications
+// are coalesced, the subscriber is expected to look at the task again.
+func (l *Listener) Subscribe(taskID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	l.mu.Lock()
+	if l.waiters[taskID] == nil {
+		l.waiters[taskID] = make(map[chan struct{}]struct{})
+	}
+	l.waiters[taskID][ch] = struct{}{}
+	l.mu.Unlock()
+
+	return ch, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.waiters[taskID], ch)
+		if len(l.waiters[taskID]) == 0 {
+			delete(l.waiters, taskID)
+		}
+	}
+}
+
+// SubscribeEvents returns a channel that receives every task event logged
+// from now on, and a function that ends the subscription. The channel is
+// closed when the subscriber falls too far behind or the connection to the
+// database was lost, the subscriber is expected to catch up from the log.
+func (l *Listener) SubscribeEvents() (<-chan *models.TaskEvent, func()) {
+	ch := make(chan *models.TaskEvent, eventBuffer)
+
+	l.mu.Lock()
+	l.events[ch] = struct{}{}
+	l.mu.Unlock()
+
+	return ch, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.dropEvents(ch)
+	}
+}
+
+// Waiters returns the number of active subscriptions.
+func (l *Listener) Waiters() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	count := 0
+	for _, chans := range l.waiters {
+		count += len(chans)
+	}
+	return count
+}
+
+// Start listens for notifications until the context is cancelled. It returns
+// once the listener is registered, so no notification sent afterwards is
+// missed.
+func (l *Listener) Start(ctx context.Context) error {
+	listener := pq.NewListener(l.dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Warn("Task notification listener event", zap.Int("event", int(event)), zap.Error(err))
+		}
+	})
+	for _, channel := range []string{taskFinishedChannel, taskEventsChannel} {
+		if err := listener.Listen(channel); err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to listen for task notifications: %w", err)
+		}
+	}
+
+	db, err := sql.Open("postgres", l.dsn)
+	if err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to open the task event connection: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	l.db = db
+
+	go l.run(ctx, listener)
+	return nil
+}
+
+func (l *Listener) run(ctx context.Context, listener *pq.Listener) {
+	defer listener.Close()
+	defer l.db.Close()
+
+	logger.Info("Task notification listener started")
+
+	// a ping now and then notices a dead connection sooner
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Task notification listener stopping")
+			return
+		case n := <-listener.Notify:
+			if n == nil {
+				// the connection was reestablished, notifications may have
+				// been lost in between
+				l.wakeAll()
+				continue
+			}
+			switch n.Channel {
+			case taskFinishedChannel:
+				l.wake(n.Extra)
+			case taskEventsChannel:
+				l.publish(ctx, n.Extra)
+			}
+		case <-ticker.C:
+			go listener.Ping()
+		}
+	}
+}
+
+func (l *Listener) wake(taskID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for ch := range l.waiters[taskID] {
+		signal(ch)
+	}
+}
+
+func (l *Listener) wakeAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, chans := range l.waiters {
+		for ch := range chans {
+			signal(ch)
+		}
+	}
+	for ch := range l.events {
+		l.dropEvents(ch)
+	}
+}
+
+// publish hands the logged event to the event subscribers, it is only read
+// when there are some
+func (l *Listener) publish(ctx context.Context, payload string) {
+	id, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		logger.Warn("Failed to decode task event notification", zap.String("payload", payload), zap.Error(err))
+		return
+	}
+
+	l.mu.Lock()
+	subscribed := len(l.events) > 0
+	l.mu.Unlock()
+	if !subscribed {
+		return
+	}
+
+	event, err := l.loadEvent(ctx, id)
+	if err != nil {
+		// the subscribers catch up from the log
+		logger.Warn("Failed to read task event", zap.Int64("event_id", id), zap.Error(err))
+		l.mu.Lock()
+		for ch := range l.events {
+			l.dropEvents(ch)
+		}
+		l.mu.Unlock()
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for ch := range l.events {
+		select {
+		case ch <- event:
+		default:
+			l.dropEvents(ch)
+		}
+	}
+}
+
+func (l *Listener) queryEvent(ctx context.Context, id int64) (*models.TaskEvent, error) {
+	query := `
+		SELECT id, type, task_id, task_type, state, previous_state,
+		       worker_id, progress, message, created_at
+		FROM task_events
+		WHERE id = $1
+	`
+
+	var (
+		event         models.TaskEvent
+		previousState sql.NullString
+		workerID      sql.NullString
+		progress      sql.NullFloat64
+		message       sql.NullString
+	)
+	err := l.db.QueryRowContext(ctx, query, id).Scan(&event.ID, &event.Type, &event.TaskID, &event.TaskType,
+		&event.State, &previousState, &workerID, &progress, &message, &event.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task event: %w", err)
+	}
+
+	event.PreviousState = models.TaskState(previousState.String)
+	event.WorkerID = workerID.String
+	if progress.Valid {
+		event.Progress = &progress.Float64
+	}
+	event.Message = message.String
+	return &event, nil
+}
+
+// dropEvents ends an event subscription, the caller holds the lock
+func (l *Listener) dropEvents(ch chan *models.TaskEvent) {
+	if _, ok := l.events[ch]; ok {
+		delete(l.events, ch)
+		close(ch)
+	}
+}
+
+// signal sends on a buffered channel without blocking, a pending value
+// already tells the subscriber to look again
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
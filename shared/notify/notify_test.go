@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alaajili/task-scheduler/shared/config"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListener_Subscribe(t *testing.T) {
+	l := NewListener(config.DatabaseConfig{})
+
+	a, unsubscribeA := l.Subscribe("task-1")
+	b, unsubscribeB := l.Subscribe("task-1")
+	other, unsubscribeOther := l.Subscribe("task-2")
+	defer unsubscribeOther()
+	assert.Equal(t, 3, l.Waiters())
+
+	// notifications are coalesced and only reach the task's subscribers
+	l.wake("task-1")
+	l.wake("task-1")
+	assert.Len(t, a, 1)
+	assert.Len(t, b, 1)
+	assert.Len(t, other, 0)
+
+	unsubscribeA()
+	unsubscribeB()
+	assert.Equal(t, 1, l.Waiters())
+	assert.NotContains(t, l.waiters, "task-1")
+
+	l.wakeAll()
+	assert.Len(t, other, 1)
+}
+
+func TestListener_SubscribeEvents(t *testing.T) {
+	l := NewListener(config.DatabaseConfig{})
+	var loaded []int64
+	l.loadEvent = func(ctx context.Context, id int64) (*models.TaskEvent, error) {
+		loaded = append(loaded, id)
+		return &models.TaskEvent{ID: id, Type: models.TaskEventStateChanged, TaskID: "task-1", State: models.TaskStateRunning}, nil
+	}
+
+	// nothing is read without subscribers
+	l.publish(context.Background(), "6")
+	assert.Empty(t, loaded)
+
+	events, unsubscribe := l.SubscribeEvents()
+	defer unsubscribe()
+	slow, _ := l.SubscribeEvents()
+
+	l.publish(context.Background(), "7")
+	event := <-events
+	assert.Equal(t, int64(7), event.ID)
+	assert.Equal(t, models.TaskEventStateChanged, event.Type)
+	assert.Equal(t, models.TaskStateRunning, event.State)
+
+	// a subscriber that falls behind is dropped, the others keep receiving
+	for i := 0; i < eventBuffer; i++ {
+		l.publish(context.Background(), "8")
+		<-events
+	}
+	for range slow {
+	}
+	l.publish(context.Background(), "9")
+	assert.Equal(t, int64(9), (<-events).ID)
+
+	// so are all of them when the connection was lost
+	l.wakeAll()
+	_, open := <-events
+	assert.False(t, open)
+
+	// or an event could not be read, they catch up from the log
+	events, _ = l.SubscribeEvents()
+	l.loadEvent = func(ctx context.Context, id int64) (*models.TaskEvent, error) {
+		return nil, errors.New("connection reset")
+	}
+	l.publish(context.Background(), "10")
+	_, open = <-events
+	assert.False(t, open)
+}
@@ -0,0 +1,359 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/config"
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/queue"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// Entry is a queue publication that has been committed but not yet delivered.
+type Entry struct {
+	ID       int64
+	TaskID   string
+	Priority int
+	Attempts int
+	// NotBefore is set for scheduled tasks, which go to the delayed queue
+	NotBefore *time.Time
+	// UniqueKey is set for tasks with a unique key, the queue holds at most
+	// one task per key at a time
+	UniqueKey string
+}
+
+// Insert records a pending publication for a task. It must run in the same
+// transaction that inserts the task so that both commit or roll back together.
+func Insert(ctx context.Context, tx *sql.Tx, taskID string, priority int) error {
+	query := `INSERT INTO task_outbox (task_id, priority) VALUES ($1, $2)`
+
+	if _, err := tx.ExecContext(ctx, query, taskID, priority); err != nil {
+		return fmt.Errorf("failed to insert outbox entry: %w", err)
+	}
+	return nil
+}
+
+// InsertScheduled is Insert for a task that must not run before notBefore.
+// The relay publishes it to the delayed queue instead of the task queue.
+func InsertScheduled(ctx context.Context, tx *sql.Tx, taskID string, priority int, notBefore time.Time) error {
+	query := `INSERT INTO task_outbox (task_id, priority, not_before) VALUES ($1, $2, $3)`
+
+	if _, err := tx.ExecContext(ctx, query, taskID, priority, notBefore); err != nil {
+		return fmt.Errorf("failed to insert outbox entry: %w", err)
+	}
+	return nil
+}
+
+// entryColumns are the columns claimEntries reads, the unique key is built
+// like models.Task.QueueUniqueKey
+const entryColumns = `o.id, o.task_id, o.priority, o.attempts, o.not_before,
+		       t.type || ':' || t.unique_key`
+
+// InsertEntries is Insert for many tasks at once, entries with a NotBefore
+// are scheduled like with InsertScheduled.
+func InsertEntries(ctx context.Context, tx *sql.Tx, entries []Entry) error {
+	for start := 0; start < len(entries); start += insertChunkSize {
+		chunk := entries[start:min(start+insertChunkSize, len(entries))]
+
+		var (
+			query strings.Builder
+			args  []any
+		)
+		query.WriteString(`INSERT INTO task_outbox (task_id, priority, not_before) VALUES `)
+		for i, entry := range chunk {
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			fmt.Fprintf(&query, "($%d, $%d, $%d)", len(args)+1, len(args)+2, len(args)+3)
+			args = append(args, entry.TaskID, entry.Priority, entry.NotBefore)
+		}
+
+		if _, err := tx.ExecContext(ctx, query.String(), args...); err != nil {
+			return fmt.Errorf("failed to insert outbox entries: %w", err)
+		}
+	}
+	return nil
+}
+
+// insertChunkSize bounds the rows of a multi-row insert, postgres takes at
+// most 65535 parameters per statement
+const insertChunkSize = 1000
+
+// maxHeldBackoff bounds how long an entry whose unique key is held waits
+// before the relay tries it again
+const maxHeldBackoff = 30 * time.Second
+
+// Relay drains the task outbox into the redis queue. Several relays can run
+// against the same database, rows are claimed with FOR UPDATE SKIP LOCKED.
+type Relay struct {
+	db        *database.DB
+	queue     *queue.RedisQueue
+	interval  time.Duration
+	batchSize int
+}
+
+func NewRelay(db *database.DB, queue *queue.RedisQueue, cfg config.OutboxConfig) *Relay {
+	interval := cfg.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	return &Relay{
+		db:        db,
+		queue:     queue,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// PublishTask publishes the outbox entries of a single task right away.
+// Entries already claimed by a running relay are left to it.
+func (r *Relay) PublishTask(ctx context.Context, taskID string) error {
+	query := `
+		SELECT ` + entryColumns + `
+		FROM task_outbox o
+		JOIN tasks t ON t.id = o.task_id
+		WHERE o.task_id = $1
+		ORDER BY o.id
+		FOR UPDATE OF o SKIP LOCKED
+	`
+
+	_, err := r.drain(ctx, query, taskID)
+	return err
+}
+
+// PublishTasks is PublishTask for many tasks, e.g. the tasks of a batch.
+func (r *Relay) PublishTasks(ctx context.Context, taskIDs []string) error {
+	query := `
+		SELECT ` + entryColumns + `
+		FROM task_outbox o
+		JOIN tasks t ON t.id = o.task_id
+		WHERE o.task_id = ANY($1)
+		ORDER BY o.id
+		FOR UPDATE OF o SKIP LOCKED
+	`
+
+	_, err := r.drain(ctx, query, pq.Array(taskIDs))
+	return err
+}
+
+// RelayOnce publishes up to one batch of outbox entries and returns how many
+// of them reached the queue. Entries backing off are skipped.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	query := `
+		SELECT ` + entryColumns + `
+		FROM task_outbox o
+		JOIN tasks t ON t.id = o.task_id
+		WHERE o.next_attempt_at IS NULL OR o.next_attempt_at <= NOW()
+		ORDER BY o.id
+		LIMIT $1
+		FOR UPDATE OF o SKIP LOCKED
+	`
+
+	return r.drain(ctx, query, r.batchSize)
+}
+
+// Run relays the outbox until the context is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	logger.Info("Outbox relay started", zap.Duration("interval", r.interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Outbox relay stopping")
+			return
+		case <-ticker.C:
+			published, err := r.RelayOnce(ctx)
+			if err != nil {
+				logger.Error("Failed to relay outbox entries", zap.Error(err))
+				continue
+			}
+			if published > 0 {
+				logger.Debug("Relayed outbox entries", zap.Int("count", published))
+			}
+		}
+	}
+}
+
+// drain claims the entries selected by query, publishes them and deletes the
+// ones that made it to the queue, all inside a single transaction.
+func (r *Relay) drain(ctx context.Context, query string, args ...any) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin outbox transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	entries, err := claimEntries(ctx, tx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	var (
+		published  []int64
+		publishErr error
+		plain      []Entry
+		unique     []Entry
+	)
+	for _, entry := range entries {
+		if entry.UniqueKey != "" {
+			unique = append(unique, entry)
+		} else {
+			plain = append(plain, entry)
+		}
+	}
+
+	// entries without a unique key go out in a single round trip
+	if err := r.publishAll(ctx, plain); err != nil {
+		publishErr = err
+		if err := recordAttempt(ctx, tx, plain[0].ID, publishErr); err != nil {
+			return 0, err
+		}
+		// redis is most likely unavailable, keep the rest for the next run
+		unique = nil
+	} else {
+		for _, entry := range plain {
+			published = append(published, entry.ID)
+		}
+	}
+
+	for _, entry := range unique {
+		err := r.publishUnique(ctx, entry)
+		if errors.Is(err, queue.ErrUniqueKeyHeld) {
+			// another task with the same key is still queued or running,
+			// this one goes once it is done
+			if err := deferEntry(ctx, tx, entry, err); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err != nil {
+			publishErr = err
+			if err := recordAttempt(ctx, tx, entry.ID, publishErr); err != nil {
+				return 0, err
+			}
+			break
+		}
+		published = append(published, entry.ID)
+	}
+
+	if len(published) > 0 {
+		_, err := tx.ExecContext(ctx, `DELETE FROM task_outbox WHERE id = ANY($1)`, pq.Array(published))
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete outbox entries: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit outbox transaction: %w", err)
+	}
+
+	if publishErr != nil {
+		return len(published), fmt.Errorf("failed to publish outbox entry: %w", publishErr)
+	}
+	return len(published), nil
+}
+
+func recordAttempt(ctx context.Context, tx *sql.Tx, entryID int64, publishErr error) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE task_outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1`,
+		entryID, publishErr.Error(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record outbox attempt: %w", err)
+	}
+	return nil
+}
+
+// deferEntry records a failed attempt and keeps the relay away from the entry
+// for a while, longer on every attempt
+func deferEntry(ctx context.Context, tx *sql.Tx, entry Entry, publishErr error) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE task_outbox
+		SET attempts = attempts + 1,
+		    last_error = $2,
+		    next_attempt_at = NOW() + $3 * INTERVAL '1 millisecond'
+		WHERE id = $1
+	`, entry.ID, publishErr.Error(), heldBackoff(entry.Attempts).Milliseconds())
+	if err != nil {
+		return fmt.Errorf("failed to defer outbox entry: %w", err)
+	}
+	return nil
+}
+
+// heldBackoff doubles from a second with every attempt up to maxHeldBackoff
+func heldBackoff(attempts int) time.Duration {
+	if attempts >= 5 {
+		return maxHeldBackoff
+	}
+	return min(time.Second<<attempts, maxHeldBackoff)
+}
+
+// publishAll publishes entries without a unique key, the ones with a future
+// NotBefore to the delayed queue
+func (r *Relay) publishAll(ctx context.Context, entries []Entry) error {
+	tasks := make([]queue.Publication, len(entries))
+	for i, entry := range entries {
+		tasks[i] = queue.Publication{TaskID: entry.TaskID, Priority: entry.Priority}
+		if entry.NotBefore != nil {
+			tasks[i].Delay = time.Until(*entry.NotBefore)
+		}
+	}
+	return r.queue.PublishTasks(ctx, tasks)
+}
+
+func (r *Relay) publishUnique(ctx context.Context, entry Entry) error {
+	if entry.NotBefore != nil {
+		if delay := time.Until(*entry.NotBefore); delay > 0 {
+			return r.queue.PublishDelayedUniqueTask(ctx, entry.TaskID, entry.Priority, delay, entry.UniqueKey)
+		}
+	}
+	return r.queue.PublishUniqueTask(ctx, entry.TaskID, entry.Priority, entry.UniqueKey)
+}
+
+func claimEntries(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]Entry, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var entry Entry
+		var (
+			notBefore sql.NullTime
+			uniqueKey sql.NullString
+		)
+		if err := rows.Scan(&entry.ID, &entry.TaskID, &entry.Priority, &entry.Attempts, &notBefore, &uniqueKey); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox entry: %w", err)
+		}
+		if notBefore.Valid {
+			entry.NotBefore = &notBefore.Time
+		}
+		entry.UniqueKey = uniqueKey.String
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outbox entries: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,254 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alaajili/task-scheduler/shared/config"
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/queue"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRelay(t *testing.T) (*Relay, sqlmock.Sqlmock, *miniredis.Miniredis) {
+	t.Helper()
+
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		mockDB.Close()
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	mr := miniredis.RunT(t)
+	port, err := strconv.Atoi(mr.Port())
+	require.NoError(t, err)
+
+	rq, err := queue.NewRedisQueue(config.RedisConfig{Host: mr.Host(), Port: port})
+	require.NoError(t, err)
+	t.Cleanup(func() { rq.Close() })
+
+	relay := NewRelay(&database.DB{DB: mockDB}, rq, config.OutboxConfig{BatchSize: 10})
+	return relay, mock, mr
+}
+
+func TestInsert(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO task_outbox").
+		WithArgs("task-1", 7).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, err := mockDB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, Insert(context.Background(), tx, "task-1", 7))
+	require.NoError(t, tx.Commit())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertScheduled(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	runAt := time.Now().Add(time.Minute).UTC()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO task_outbox \\(task_id, priority, not_before\\)").
+		WithArgs("task-1", 7, runAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, err := mockDB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, InsertScheduled(context.Background(), tx, "task-1", 7, runAt))
+	require.NoError(t, tx.Commit())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertEntries(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	runAt := time.Now().Add(time.Minute).UTC()
+	entries := make([]Entry, insertChunkSize+1)
+	for i := range entries {
+		entries[i] = Entry{TaskID: "task-" + strconv.Itoa(i), Priority: 5}
+	}
+	entries[insertChunkSize].NotBefore = &runAt
+
+	// one statement per chunk
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO task_outbox \\(task_id, priority, not_before\\) VALUES \\(\\$1, \\$2, \\$3\\), ").
+		WillReturnResult(sqlmock.NewResult(0, insertChunkSize))
+	mock.ExpectExec("INSERT INTO task_outbox \\(task_id, priority, not_before\\) VALUES \\(\\$1, \\$2, \\$3\\)$").
+		WithArgs("task-1000", 5, runAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := mockDB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, InsertEntries(context.Background(), tx, entries))
+	require.NoError(t, tx.Commit())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+var entryRows = []string{"id", "task_id", "priority", "attempts", "not_before", "unique_key"}
+
+func TestRelayOnce_PublishesAndDeletes(t *testing.T) {
+	relay, mock, mr := setupRelay(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM task_outbox").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows(entryRows).
+			AddRow(1, "task-1", 5, 0, nil, nil).
+			AddRow(2, "task-2", 9, 0, nil, nil))
+	mock.ExpectExec("DELETE FROM task_outbox").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	published, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+
+	members, err := mr.ZMembers("task_queue")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"task-1", "task-2"}, members)
+
+	score, err := mr.ZScore("task_queue", "task-2")
+	require.NoError(t, err)
+	assert.Equal(t, float64(9), score)
+}
+
+func TestRelayOnce_ScheduledTaskGoesToDelayedQueue(t *testing.T) {
+	relay, mock, mr := setupRelay(t)
+	runAt := time.Now().Add(time.Hour).UTC()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM task_outbox").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows(entryRows).
+			AddRow(1, "task-1", 5, 0, runAt, nil))
+	mock.ExpectExec("DELETE FROM task_outbox").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	published, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.False(t, mr.Exists("task_queue"))
+
+	score, err := mr.ZScore("delayed_queue", "task-1:5")
+	require.NoError(t, err)
+	assert.InDelta(t, float64(runAt.Unix()), score, 1)
+}
+
+func TestRelayOnce_UniqueKeyHeld(t *testing.T) {
+	relay, mock, mr := setupRelay(t)
+	require.NoError(t, relay.queue.PublishUniqueTask(context.Background(), "task-0", 5, "sync:customer-42"))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM task_outbox").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows(entryRows).
+			AddRow(1, "task-1", 5, 2, nil, "sync:customer-42").
+			AddRow(2, "task-2", 5, 0, nil, nil))
+	// task-1 backs off, the next batches go on without it
+	mock.ExpectExec("UPDATE task_outbox(.+)next_attempt_at").
+		WithArgs(int64(1), sqlmock.AnyArg(), int64(4000)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM task_outbox").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// task-1 waits for task-0 to leave the queue without holding up task-2
+	published, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+
+	members, err := mr.ZMembers("task_queue")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"task-0", "task-2"}, members)
+}
+
+func TestHeldBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, heldBackoff(0))
+	assert.Equal(t, 8*time.Second, heldBackoff(3))
+	assert.Equal(t, maxHeldBackoff, heldBackoff(5))
+	assert.Equal(t, maxHeldBackoff, heldBackoff(100))
+}
+
+func TestRelayOnce_Empty(t *testing.T) {
+	relay, mock, _ := setupRelay(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM task_outbox").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows(entryRows))
+	mock.ExpectRollback()
+
+	published, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, published)
+}
+
+func TestRelayOnce_RedisUnavailable(t *testing.T) {
+	relay, mock, mr := setupRelay(t)
+	mr.SetError("connection refused")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM task_outbox").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows(entryRows).
+			AddRow(1, "task-1", 5, 0, nil, nil).
+			AddRow(2, "task-2", 9, 0, nil, nil))
+	mock.ExpectExec("UPDATE task_outbox SET attempts = attempts \\+ 1").
+		WithArgs(int64(1), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	published, err := relay.RelayOnce(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, 0, published)
+	assert.Contains(t, err.Error(), "failed to publish outbox entry")
+}
+
+func TestPublishTask_ClaimedByAnotherRelay(t *testing.T) {
+	relay, mock, mr := setupRelay(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM task_outbox").
+		WithArgs("task-1").
+		WillReturnRows(sqlmock.NewRows(entryRows))
+	mock.ExpectRollback()
+
+	require.NoError(t, relay.PublishTask(context.Background(), "task-1"))
+	assert.False(t, mr.Exists("task_queue"))
+}
+
+func TestPublishTask_BeginError(t *testing.T) {
+	relay, mock, _ := setupRelay(t)
+
+	mock.ExpectBegin().WillReturnError(errors.New("db down"))
+
+	err := relay.PublishTask(context.Background(), "task-1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "db down")
+}
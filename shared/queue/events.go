@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// cancellations are fanned out over pub/sub, subscribers only see the
+// messages published while they are connected
+const taskCancellationsChannel = "task_cancellations"
+
+// tell every worker that a task was cancelled
+func (q *RedisQueue) PublishCancellation(ctx context.Context, taskID string) error {
+	if err := q.client.Publish(ctx, taskCancellationsChannel, taskID).Err(); err != nil {
+		return fmt.Errorf("failed to publish the task cancellation: %w", err)
+	}
+	return nil
+}
+
+// receive the ids of cancelled tasks until the context is cancelled, the
+// returned channel is closed once the subscription ends
+func (q *RedisQueue) SubscribeCancellations(ctx context.Context) (<-chan string, error) {
+	pubsub := q.client.Subscribe(ctx, taskCancellationsChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to task cancellations: %w", err)
+	}
+
+	taskIDs := make(chan string)
+	go func() {
+		defer close(taskIDs)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			var msg *redis.Message
+			select {
+			case <-ctx.Done():
+				return
+			case msg = <-messages:
+			}
+
+			select {
+			case taskIDs <- msg.Payload:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return taskIDs, nil
+}
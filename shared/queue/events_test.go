@@ -0,0 +1,36 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeCancellations(t *testing.T) {
+	q, _ := setupTestQueue(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	taskIDs, err := q.SubscribeCancellations(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, q.PublishCancellation(ctx, "task-1"))
+
+	select {
+	case taskID := <-taskIDs:
+		assert.Equal(t, "task-1", taskID)
+	case <-time.After(time.Second):
+		t.Fatal("cancellation was not delivered")
+	}
+
+	// the channel is closed once the subscriber stops
+	cancel()
+	select {
+	case _, open := <-taskIDs:
+		assert.False(t, open)
+	case <-time.After(time.Second):
+		t.Fatal("subscription did not stop")
+	}
+}
@@ -0,0 +1,196 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// In-flight bookkeeping for leased tasks:
+//
+//	task_leases        zset  taskID -> lease deadline (unix ms)
+//	task_lease_owners  hash  taskID -> workerID
+//	inflight:<worker>  hash  taskID -> priority the task was popped with
+//
+// The scripts derive the per-worker key from the owner hash, which assumes a
+// single redis node (no cluster slot checks).
+const (
+	leasesKey      = "task_leases"
+	leaseOwnersKey = "task_lease_owners"
+	inFlightPrefix = "inflight:"
+)
+
+// ErrLeaseNotHeld is returned when a worker acts on a task it no longer leases,
+// typically because the lease expired and the task was handed to someone else.
+var ErrLeaseNotHeld = errors.New("task lease not held by worker")
+
+func inFlightKey(workerID string) string {
+	return inFlightPrefix + workerID
+}
+
+// KEYS: task_queue, task_leases, task_lease_owners, inflight:<worker>
+// ARGV: workerID, lease deadline
+var popScript = redis.NewScript(`
+local popped = redis.call('ZPOPMAX', KEYS[1])
+if #popped == 0 then
+	return false
+end
+local taskID, priority = popped[1], popped[2]
+redis.call('HSET', KEYS[4], taskID, priority)
+redis.call('HSET', KEYS[3], taskID, ARGV[1])
+redis.call('ZADD', KEYS[2], ARGV[2], taskID)
+return taskID
+`)
+
+// KEYS: task_leases, task_lease_owners, inflight:<worker>
+// ARGV: workerID, taskID
+var ackScript = redis.NewScript(`
+if redis.call('HGET', KEYS[2], ARGV[2]) ~= ARGV[1] then
+	return 0
+end
+redis.call('ZREM', KEYS[1], ARGV[2])
+redis.call('HDEL', KEYS[2], ARGV[2])
+redis.call('HDEL', KEYS[3], ARGV[2])
+return 1
+`)
+
+// KEYS: task_leases, task_lease_owners, inflight:<worker>, task_queue
+// ARGV: workerID, taskID
+var nackScript = redis.NewScript(`
+if redis.call('HGET', KEYS[2], ARGV[2]) ~= ARGV[1] then
+	return 0
+end
+local priority = redis.call('HGET', KEYS[3], ARGV[2]) or 0
+redis.call('ZREM', KEYS[1], ARGV[2])
+redis.call('HDEL', KEYS[2], ARGV[2])
+redis.call('HDEL', KEYS[3], ARGV[2])
+redis.call('ZADD', KEYS[4], priority, ARGV[2])
+return 1
+`)
+
+// KEYS: task_leases, task_lease_owners
+// ARGV: workerID, taskID, new lease deadline
+var extendScript = redis.NewScript(`
+if redis.call('HGET', KEYS[2], ARGV[2]) ~= ARGV[1] then
+	return 0
+end
+redis.call('ZADD', KEYS[1], 'XX', ARGV[3], ARGV[2])
+return 1
+`)
+
+// KEYS: task_leases, task_lease_owners, task_queue
+// ARGV: now, limit
+var requeueScript = redis.NewScript(`
+local expired = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, taskID in ipairs(expired) do
+	local priority = 0
+	local owner = redis.call('HGET', KEYS[2], taskID)
+	if owner then
+		local inflight = '` + inFlightPrefix + `' .. owner
+		priority = redis.call('HGET', inflight, taskID) or 0
+		redis.call('HDEL', inflight, taskID)
+	end
+	redis.call('HDEL', KEYS[2], taskID)
+	redis.call('ZREM', KEYS[1], taskID)
+	redis.call('ZADD', KEYS[3], priority, taskID)
+end
+return expired
+`)
+
+// KEYS: task_leases, task_lease_owners, task_queue
+// ARGV: taskID, requeue flag, priority
+var revokeScript = redis.NewScript(`
+local owner = redis.call('HGET', KEYS[2], ARGV[1])
+if owner then
+	redis.call('HDEL', '` + inFlightPrefix + `' .. owner, ARGV[1])
+	redis.call('HDEL', KEYS[2], ARGV[1])
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+if ARGV[2] == '1' then
+	redis.call('ZADD', KEYS[3], ARGV[3], ARGV[1])
+end
+return 1
+`)
+
+// acknowledge that a leased task has been fully handled and drop it from the in-flight set
+func (q *RedisQueue) Ack(ctx context.Context, workerID, taskID string) error {
+	keys := []string{leasesKey, leaseOwnersKey, inFlightKey(workerID)}
+	held, err := ackScript.Run(ctx, q.client, keys, workerID, taskID).Int()
+	if err != nil {
+		return fmt.Errorf("failed to ack the task: %w", err)
+	}
+	if held == 0 {
+		return ErrLeaseNotHeld
+	}
+	return nil
+}
+
+// give a leased task back, it is requeued right away with its original priority
+func (q *RedisQueue) Nack(ctx context.Context, workerID, taskID string) error {
+	keys := []string{leasesKey, leaseOwnersKey, inFlightKey(workerID), taskQueueKey}
+	held, err := nackScript.Run(ctx, q.client, keys, workerID, taskID).Int()
+	if err != nil {
+		return fmt.Errorf("failed to nack the task: %w", err)
+	}
+	if held == 0 {
+		return ErrLeaseNotHeld
+	}
+	return nil
+}
+
+// push the lease deadline of a task the worker is still executing
+func (q *RedisQueue) ExtendLease(ctx context.Context, workerID, taskID string, lease time.Duration) error {
+	deadline := time.Now().Add(lease).UnixMilli()
+
+	keys := []string{leasesKey, leaseOwnersKey}
+	held, err := extendScript.Run(ctx, q.client, keys, workerID, taskID, deadline).Int()
+	if err != nil {
+		return fmt.Errorf("failed to extend the task lease: %w", err)
+	}
+	if held == 0 {
+		return ErrLeaseNotHeld
+	}
+	return nil
+}
+
+// move up to limit tasks whose lease expired back into the queue and return their ids
+func (q *RedisQueue) RequeueExpired(ctx context.Context, limit int) ([]string, error) {
+	now := time.Now().UnixMilli()
+
+	keys := []string{leasesKey, leaseOwnersKey, taskQueueKey}
+	requeued, err := requeueScript.Run(ctx, q.client, keys, now, limit).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to requeue expired tasks: %w", err)
+	}
+	return requeued, nil
+}
+
+// get the ids of the tasks currently leased by a worker
+func (q *RedisQueue) InFlightTasks(ctx context.Context, workerID string) ([]string, error) {
+	taskIDs, err := q.client.HKeys(ctx, inFlightKey(workerID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get in-flight tasks: %w", err)
+	}
+	return taskIDs, nil
+}
+
+// drop the lease of a task whoever holds it, the owner can no longer ack it
+func (q *RedisQueue) RevokeLease(ctx context.Context, taskID string) error {
+	keys := []string{leasesKey, leaseOwnersKey, taskQueueKey}
+	if err := revokeScript.Run(ctx, q.client, keys, taskID, 0, 0).Err(); err != nil {
+		return fmt.Errorf("failed to revoke the task lease: %w", err)
+	}
+	return nil
+}
+
+// revoke the lease of a task, if any, and put it back in the queue
+func (q *RedisQueue) RequeueTask(ctx context.Context, taskID string, priority int) error {
+	keys := []string{leasesKey, leaseOwnersKey, taskQueueKey}
+	if err := revokeScript.Run(ctx, q.client, keys, taskID, 1, priority).Err(); err != nil {
+		return fmt.Errorf("failed to requeue the task: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const lockPrefix = "lock:"
+
+// KEYS: lock key
+// ARGV: owner, ttl in ms
+var acquireLockScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+if current then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// KEYS: lock key
+// ARGV: owner
+var releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// acquire (or refresh, when the owner already holds it) a named lock that expires after ttl.
+// it is meant for electing a single active instance among replicas
+func (q *RedisQueue) AcquireLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	acquired, err := acquireLockScript.Run(ctx, q.client, []string{lockPrefix + name}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lock %s: %w", name, err)
+	}
+	return acquired == 1, nil
+}
+
+// release a named lock if it is still held by the owner
+func (q *RedisQueue) ReleaseLock(ctx context.Context, name, owner string) error {
+	if err := releaseLockScript.Run(ctx, q.client, []string{lockPrefix + name}, owner).Err(); err != nil {
+		return fmt.Errorf("failed to release lock %s: %w", name, err)
+	}
+	return nil
+}
@@ -3,12 +3,18 @@ package queue
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alaajili/task-scheduler/shared/config"
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	taskQueueKey    = "task_queue"
+	delayedQueueKey = "delayed_queue"
+)
 
 type RedisQueue struct {
 	client *redis.Client
@@ -34,7 +40,7 @@ func NewRedisQueue(cfg config.RedisConfig) (*RedisQueue, error) {
 
 // publish the task to the redis queue with the priority as a score
 func (q *RedisQueue) PublishTask(ctx context.Context, taskID string, priority int) error {
-	err := q.client.ZAdd(ctx, "task_queue", redis.Z{
+	err := q.client.ZAdd(ctx, taskQueueKey, redis.Z{
 		Score:  float64(priority),
 		Member: taskID,
 	}).Err()
@@ -46,23 +52,131 @@ func (q *RedisQueue) PublishTask(ctx context.Context, taskID string, priority in
 	return nil
 }
 
-// pop the task with the highest priority from the queue
-func (q *RedisQueue) PopTask(ctx context.Context) (string, error) {
-	res, err := q.client.ZPopMax(ctx, "task_queue", 1).Result()
+// Publication is a task to publish with PublishTasks, a positive Delay sends
+// it to the delayed queue
+type Publication struct {
+	TaskID   string
+	Priority int
+	Delay    time.Duration
+}
+
+// publish many tasks in a single round trip
+func (q *RedisQueue) PublishTasks(ctx context.Context, tasks []Publication) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	var ready, delayed []redis.Z
+	for _, task := range tasks {
+		if task.Delay > 0 {
+			delayed = append(delayed, redis.Z{
+				Score:  float64(now.Add(task.Delay).Unix()),
+				Member: fmt.Sprintf("%s:%d", task.TaskID, task.Priority),
+			})
+			continue
+		}
+		ready = append(ready, redis.Z{Score: float64(task.Priority), Member: task.TaskID})
+	}
+
+	_, err := q.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(ready) > 0 {
+			pipe.ZAdd(ctx, taskQueueKey, ready...)
+		}
+		if len(delayed) > 0 {
+			pipe.ZAdd(ctx, delayedQueueKey, delayed...)
+		}
+		return nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to pop the task: %w", err)
+		return fmt.Errorf("failed to publish the tasks: %w", err)
 	}
-	if len(res) == 0 {
+	return nil
+}
+
+// pop the task with the highest priority from the queue and lease it to the worker.
+// the task stays in-flight until it is acked or nacked, or until the lease expires
+// and the sweeper puts it back in the queue
+func (q *RedisQueue) PopTask(ctx context.Context, workerID string, lease time.Duration) (string, error) {
+	deadline := time.Now().Add(lease).UnixMilli()
+
+	keys := []string{taskQueueKey, leasesKey, leaseOwnersKey, inFlightKey(workerID)}
+	taskID, err := popScript.Run(ctx, q.client, keys, workerID, deadline).Text()
+	if err == redis.Nil {
 		return "", nil // no task available
 	}
-	
-	taskID := res[0].Member.(string)
+	if err != nil {
+		return "", fmt.Errorf("failed to pop the task: %w", err)
+	}
+
 	return taskID, nil
 }
 
+// remove a task waiting in the queue, e.g. because it was cancelled before a worker got to it
+func (q *RedisQueue) RemoveTask(ctx context.Context, taskID string) error {
+	err := q.client.ZRem(ctx, taskQueueKey, taskID).Err()
+	if err != nil {
+		return fmt.Errorf("failed to remove the task: %w", err)
+	}
+	return nil
+}
+
+// KEYS: task_queue, delayed_queue, task_delayed_unique_keys
+// ARGV: taskID, previous delayed member, priority, due at (0 when due), now,
+// unique key (empty when none)
+var rescheduleScript = redis.NewScript(`
+local delayed = redis.call('ZREM', KEYS[2], ARGV[2])
+if tonumber(ARGV[4]) > 0 then
+	if redis.call('ZREM', KEYS[1], ARGV[1]) + delayed > 0 then
+		if ARGV[6] ~= '' then
+			redis.call('HSET', KEYS[3], ARGV[1], ARGV[6])
+		end
+		redis.call('ZADD', KEYS[2], ARGV[4], ARGV[1] .. ':' .. ARGV[3])
+		return 1
+	end
+	return 0
+end
+if delayed > 0 then
+	if ARGV[6] ~= '' then
+		-- the promoter checks the unique key before queueing the task
+		redis.call('HSET', KEYS[3], ARGV[1], ARGV[6])
+		redis.call('ZADD', KEYS[2], ARGV[5], ARGV[1] .. ':' .. ARGV[3])
+	else
+		redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+	end
+	return 1
+end
+return redis.call('ZADD', KEYS[1], 'XX', 'CH', ARGV[3], ARGV[1])
+`)
+
+// move a queued task to its new priority and due time, dueAt is nil when the
+// task is due. a task found in neither queue, because it is leased or not
+// published yet, is left alone. a delayed task with a unique key that became
+// due is left to the promoter, which queues it once the key is free
+func (q *RedisQueue) RescheduleTask(
+	ctx context.Context,
+	taskID string,
+	previousPriority, priority int,
+	dueAt *time.Time,
+	uniqueKey string,
+) error {
+	var due int64
+	if dueAt != nil {
+		due = dueAt.UTC().Unix()
+	}
+
+	keys := []string{taskQueueKey, delayedQueueKey, delayedUniqueKeysKey}
+	previous := fmt.Sprintf("%s:%d", taskID, previousPriority)
+	now := time.Now().UTC().Unix()
+	if err := rescheduleScript.Run(ctx, q.client, keys, taskID, previous, priority, due, now, uniqueKey).Err(); err != nil {
+		return fmt.Errorf("failed to reschedule the task: %w", err)
+	}
+	return nil
+}
+
 // get the number of tasks in the queue
 func (q *RedisQueue) GetQueueDepth(ctx context.Context) (int64, error) {
-	count, err := q.client.ZCard(ctx, "task_queue").Result()
+	count, err := q.client.ZCard(ctx, taskQueueKey).Result()
 	if err != nil {
 		return 0, fmt.Errorf("failed to get the queue depth: %w", err)
 	}
@@ -79,7 +193,7 @@ func (q *RedisQueue) PublishDelayedTask(
 ) error {
 	executeAt := time.Now().UTC().Add(delay).Unix()
 	
-	err := q.client.ZAdd(ctx, "delayed_queue", redis.Z{
+	err := q.client.ZAdd(ctx, delayedQueueKey, redis.Z{
 		Score:  float64(executeAt),
 		Member: fmt.Sprintf("%s:%d", taskID, priority),
 	}).Err()
@@ -95,7 +209,7 @@ func (q *RedisQueue) PublishDelayedTask(
 func (q *RedisQueue) GetReadyDelayedTasks(ctx context.Context) ([]string, error) {
 	now := time.Now().UTC().Unix()
 	
-	res, err := q.client.ZRangeByScore(ctx, "delayed_queue", &redis.ZRangeBy{
+	res, err := q.client.ZRangeByScore(ctx, delayedQueueKey, &redis.ZRangeBy{
 		Min:  "0",
 		Max:  fmt.Sprintf("%d", now),
 		Offset: 0,
@@ -110,13 +224,116 @@ func (q *RedisQueue) GetReadyDelayedTasks(ctx context.Context) ([]string, error)
 
 // remove a task from the delayed queue
 func (q *RedisQueue) RemoveDelayedTask(ctx context.Context, taskWithPriority string) error {
-	err := q.client.ZRem(ctx, "delayed_queue", taskWithPriority).Err()
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, delayedQueueKey, taskWithPriority)
+		if taskID, _, err := ParseDelayedMember(taskWithPriority); err == nil {
+			pipe.HDel(ctx, delayedUniqueKeysKey, taskID)
+		}
+		return nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to remove delayed task: %w", err)
 	}
 	return nil
 }
 
+// DelayedTask is a delayed queue entry that was promoted to the task queue
+type DelayedTask struct {
+	TaskID   string
+	Priority int
+	DueAt    time.Time
+}
+
+// split a "taskID:priority" delayed queue member
+func ParseDelayedMember(member string) (string, int, error) {
+	sep := strings.LastIndex(member, ":")
+	if sep <= 0 {
+		return "", 0, fmt.Errorf("invalid delayed task member: %q", member)
+	}
+
+	priority, err := strconv.Atoi(member[sep+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid delayed task priority in %q: %w", member, err)
+	}
+	return member[:sep], priority, nil
+}
+
+// KEYS: delayed_queue, task_queue, task_delayed_unique_keys, task_unique_keys, task_leases
+// ARGV: now, held delay, members...
+var promoteScript = redis.NewScript(`
+local promoted = {}
+for i = 3, #ARGV do
+	local member = ARGV[i]
+	local dueAt = redis.call('ZSCORE', KEYS[1], member)
+	if dueAt and tonumber(dueAt) <= tonumber(ARGV[1]) then
+		local sep = string.find(member, ':[^:]*$')
+		local taskID = string.sub(member, 1, sep - 1)
+		local key = redis.call('HGET', KEYS[3], taskID)
+		local held = false
+		if key then
+			local holder = redis.call('HGET', KEYS[4], key)
+			if holder and holder ~= taskID then
+				held = redis.call('ZSCORE', KEYS[2], holder) or redis.call('ZSCORE', KEYS[5], holder)
+			end
+		end
+		if held then
+			redis.call('ZADD', KEYS[1], tonumber(ARGV[1]) + tonumber(ARGV[2]), member)
+		else
+			if key then
+				redis.call('HSET', KEYS[4], key, taskID)
+				redis.call('HDEL', KEYS[3], taskID)
+			end
+			redis.call('ZADD', KEYS[2], string.sub(member, sep + 1), taskID)
+			redis.call('ZREM', KEYS[1], member)
+			table.insert(promoted, member)
+			table.insert(promoted, dueAt)
+		end
+	end
+end
+return promoted
+`)
+
+// atomically move the given delayed queue members into the task queue.
+// members that are no longer delayed or not due yet are skipped, the ones
+// whose unique key is held by a queued or leased task wait a little longer
+func (q *RedisQueue) PromoteDelayedTasks(ctx context.Context, members []string) ([]DelayedTask, error) {
+	if len(members) == 0 {
+		return nil, nil
+	}
+
+	args := make([]any, 0, len(members)+2)
+	args = append(args, time.Now().UTC().Unix(), int64(heldPromotionDelay.Seconds()))
+	for _, member := range members {
+		args = append(args, member)
+	}
+
+	keys := []string{delayedQueueKey, taskQueueKey, delayedUniqueKeysKey, uniqueKeysKey, leasesKey}
+	res, err := promoteScript.Run(ctx, q.client, keys, args...).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to promote delayed tasks: %w", err)
+	}
+
+	promoted := make([]DelayedTask, 0, len(res)/2)
+	for i := 0; i+1 < len(res); i += 2 {
+		taskID, priority, err := ParseDelayedMember(res[i])
+		if err != nil {
+			return nil, err
+		}
+		dueAt, err := strconv.ParseFloat(res[i+1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delayed task score %q: %w", res[i+1], err)
+		}
+
+		promoted = append(promoted, DelayedTask{
+			TaskID:   taskID,
+			Priority: priority,
+			DueAt:    time.Unix(int64(dueAt), 0).UTC(),
+		})
+	}
+
+	return promoted, nil
+}
+
 func (q *RedisQueue) Close() error {
 	return q.client.Close()
 }
@@ -0,0 +1,473 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestQueue(t *testing.T) (*RedisQueue, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return &RedisQueue{client: client}, mr
+}
+
+func TestPopTask_HighestPriorityFirst(t *testing.T) {
+	q, _ := setupTestQueue(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.PublishTask(ctx, "low", 1))
+	require.NoError(t, q.PublishTask(ctx, "high", 9))
+
+	taskID, err := q.PopTask(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "high", taskID)
+
+	depth, err := q.GetQueueDepth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), depth)
+}
+
+func TestPopTask_EmptyQueue(t *testing.T) {
+	q, _ := setupTestQueue(t)
+
+	taskID, err := q.PopTask(context.Background(), "worker-1", time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, taskID)
+}
+
+func TestPopTask_TracksInFlight(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.PublishTask(ctx, "task-1", 5))
+
+	before := time.Now()
+	taskID, err := q.PopTask(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, "task-1", taskID)
+
+	inFlight, err := q.InFlightTasks(ctx, "worker-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"task-1"}, inFlight)
+
+	assert.Equal(t, "worker-1", mr.HGet(leaseOwnersKey, "task-1"))
+
+	deadline, err := mr.ZScore(leasesKey, "task-1")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, int64(deadline), before.Add(time.Minute).UnixMilli())
+}
+
+func TestRemoveTask(t *testing.T) {
+	q, _ := setupTestQueue(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.PublishTask(ctx, "cancelled", 5))
+	require.NoError(t, q.PublishTask(ctx, "kept", 5))
+	require.NoError(t, q.RemoveTask(ctx, "cancelled"))
+
+	taskID, err := q.PopTask(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "kept", taskID)
+
+	taskID, err = q.PopTask(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, taskID)
+}
+
+func TestPublishTasks(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.PublishTasks(ctx, nil))
+	require.NoError(t, q.PublishTasks(ctx, []Publication{
+		{TaskID: "task-1", Priority: 3},
+		{TaskID: "task-2", Priority: 8},
+		{TaskID: "later", Priority: 5, Delay: time.Hour},
+	}))
+
+	members, err := mr.ZMembers(taskQueueKey)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"task-1", "task-2"}, members)
+
+	score, err := mr.ZScore(delayedQueueKey, "later:5")
+	require.NoError(t, err)
+	assert.InDelta(t, float64(time.Now().Add(time.Hour).Unix()), score, 1)
+}
+
+func TestPublishUniqueTask(t *testing.T) {
+	q, _ := setupTestQueue(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.PublishUniqueTask(ctx, "task-1", 5, "sync:customer-42"))
+	// publishing the same task again is fine, another one with the key waits
+	require.NoError(t, q.PublishUniqueTask(ctx, "task-1", 5, "sync:customer-42"))
+	assert.ErrorIs(t, q.PublishUniqueTask(ctx, "task-2", 5, "sync:customer-42"), ErrUniqueKeyHeld)
+	require.NoError(t, q.PublishUniqueTask(ctx, "other", 5, "sync:customer-7"))
+
+	// the key stays held while the task is in flight
+	_, err := q.PopTask(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+	_, err = q.PopTask(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+	assert.ErrorIs(t, q.PublishUniqueTask(ctx, "task-2", 5, "sync:customer-42"), ErrUniqueKeyHeld)
+
+	require.NoError(t, q.Ack(ctx, "worker-1", "task-1"))
+	require.NoError(t, q.PublishUniqueTask(ctx, "task-2", 5, "sync:customer-42"))
+
+	taskID, err := q.PopTask(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "task-2", taskID)
+}
+
+func TestPromoteDelayedTasks_UniqueKeyHeld(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.PublishUniqueTask(ctx, "task-1", 5, "sync:customer-42"))
+	require.NoError(t, q.PublishDelayedUniqueTask(ctx, "task-2", 5, -time.Minute, "sync:customer-42"))
+
+	// task-1 still holds the key, task-2 waits in the delayed queue
+	promoted, err := q.PromoteDelayedTasks(ctx, []string{"task-2:5"})
+	require.NoError(t, err)
+	assert.Empty(t, promoted)
+	score, err := mr.ZScore(delayedQueueKey, "task-2:5")
+	require.NoError(t, err)
+	assert.Greater(t, score, float64(time.Now().Unix()))
+
+	// once task-1 is done task-2 takes the key over
+	taskID, err := q.PopTask(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, q.Ack(ctx, "worker-1", taskID))
+	mr.ZAdd(delayedQueueKey, float64(time.Now().Add(-time.Second).Unix()), "task-2:5")
+
+	promoted, err = q.PromoteDelayedTasks(ctx, []string{"task-2:5"})
+	require.NoError(t, err)
+	require.Len(t, promoted, 1)
+	assert.Equal(t, "task-2", mr.HGet(uniqueKeysKey, "sync:customer-42"))
+	assert.False(t, mr.Exists(delayedUniqueKeysKey))
+	assert.ErrorIs(t, q.PublishUniqueTask(ctx, "task-3", 5, "sync:customer-42"), ErrUniqueKeyHeld)
+}
+
+func TestAck(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.PublishTask(ctx, "task-1", 5))
+	_, err := q.PopTask(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Ack(ctx, "worker-1", "task-1"))
+
+	inFlight, err := q.InFlightTasks(ctx, "worker-1")
+	require.NoError(t, err)
+	assert.Empty(t, inFlight)
+	assert.False(t, mr.Exists(leasesKey))
+	assert.False(t, mr.Exists(leaseOwnersKey))
+
+	// a second ack has nothing left to acknowledge
+	assert.ErrorIs(t, q.Ack(ctx, "worker-1", "task-1"), ErrLeaseNotHeld)
+}
+
+func TestAck_WrongWorker(t *testing.T) {
+	q, _ := setupTestQueue(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.PublishTask(ctx, "task-1", 5))
+	_, err := q.PopTask(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, q.Ack(ctx, "worker-2", "task-1"), ErrLeaseNotHeld)
+
+	inFlight, err := q.InFlightTasks(ctx, "worker-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"task-1"}, inFlight)
+}
+
+func TestNack_RequeuesWithPriority(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.PublishTask(ctx, "task-1", 7))
+	_, err := q.PopTask(ctx, "worker-1", time.Minute)
+	require.NoError(t, err)
+
+	require.NoError(t, q.Nack(ctx, "worker-1", "task-1"))
+
+	score, err := mr.ZScore(taskQueueKey, "task-1")
+	require.NoError(t, err)
+	assert.Equal(t, float64(7), score)
+
+	inFlight, err := q.InFlightTasks(ctx, "worker-1")
+	require.NoError(t, err)
+	assert.Empty(t, inFlight)
+
+	assert.ErrorIs(t, q.Nack(ctx, "worker-1", "task-1"), ErrLeaseNotHeld)
+}
+
+func TestExtendLease(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.PublishTask(ctx, "task-1", 5))
+	_, err := q.PopTask(ctx, "worker-1", time.Second)
+	require.NoError(t, err)
+
+	before, err := mr.ZScore(leasesKey, "task-1")
+	require.NoError(t, err)
+
+	require.NoError(t, q.ExtendLease(ctx, "worker-1", "task-1", time.Hour))
+
+	after, err := mr.ZScore(leasesKey, "task-1")
+	require.NoError(t, err)
+	assert.Greater(t, after, before)
+
+	assert.ErrorIs(t, q.ExtendLease(ctx, "worker-2", "task-1", time.Hour), ErrLeaseNotHeld)
+}
+
+func TestRequeueExpired(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.PublishTask(ctx, "expired", 3))
+	require.NoError(t, q.PublishTask(ctx, "alive", 8))
+
+	taskID, err := q.PopTask(ctx, "worker-1", time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, "alive", taskID)
+
+	taskID, err = q.PopTask(ctx, "worker-2", time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, "expired", taskID)
+
+	time.Sleep(5 * time.Millisecond)
+
+	requeued, err := q.RequeueExpired(ctx, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"expired"}, requeued)
+
+	score, err := mr.ZScore(taskQueueKey, "expired")
+	require.NoError(t, err)
+	assert.Equal(t, float64(3), score)
+
+	// the crashed worker can no longer ack it
+	assert.ErrorIs(t, q.Ack(ctx, "worker-2", "expired"), ErrLeaseNotHeld)
+
+	// the healthy lease is left alone
+	inFlight, err := q.InFlightTasks(ctx, "worker-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alive"}, inFlight)
+
+	requeued, err = q.RequeueExpired(ctx, 100)
+	require.NoError(t, err)
+	assert.Empty(t, requeued)
+}
+
+func TestRequeueTask_RevokesLease(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.PublishTask(ctx, "task-1", 4))
+	_, err := q.PopTask(ctx, "worker-1", time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, q.RequeueTask(ctx, "task-1", 4))
+
+	score, err := mr.ZScore(taskQueueKey, "task-1")
+	require.NoError(t, err)
+	assert.Equal(t, float64(4), score)
+	assert.False(t, mr.Exists(leasesKey))
+
+	// the previous owner lost the task
+	assert.ErrorIs(t, q.Ack(ctx, "worker-1", "task-1"), ErrLeaseNotHeld)
+	inFlight, err := q.InFlightTasks(ctx, "worker-1")
+	require.NoError(t, err)
+	assert.Empty(t, inFlight)
+
+	// requeueing a task that is not leased just queues it
+	require.NoError(t, q.RequeueTask(ctx, "task-2", 1))
+	assert.True(t, mr.Exists(taskQueueKey))
+}
+
+func TestRevokeLease(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.PublishTask(ctx, "task-1", 4))
+	_, err := q.PopTask(ctx, "worker-1", time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, q.RevokeLease(ctx, "task-1"))
+
+	assert.False(t, mr.Exists(leasesKey))
+	assert.False(t, mr.Exists(taskQueueKey))
+	assert.ErrorIs(t, q.Nack(ctx, "worker-1", "task-1"), ErrLeaseNotHeld)
+}
+
+func TestPromoteDelayedTasks(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.PublishDelayedTask(ctx, "due", 4, -time.Minute))
+	require.NoError(t, q.PublishDelayedTask(ctx, "later", 6, time.Hour))
+
+	ready, err := q.GetReadyDelayedTasks(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"due:4"}, ready)
+
+	// "later:6" is not due and "gone:1" is not delayed at all, both are skipped
+	promoted, err := q.PromoteDelayedTasks(ctx, []string{"due:4", "later:6", "gone:1"})
+	require.NoError(t, err)
+	require.Len(t, promoted, 1)
+	assert.Equal(t, "due", promoted[0].TaskID)
+	assert.Equal(t, 4, promoted[0].Priority)
+	assert.WithinDuration(t, time.Now().Add(-time.Minute), promoted[0].DueAt, 2*time.Second)
+
+	score, err := mr.ZScore(taskQueueKey, "due")
+	require.NoError(t, err)
+	assert.Equal(t, float64(4), score)
+
+	delayed, err := mr.ZMembers(delayedQueueKey)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"later:6"}, delayed)
+
+	// promoting twice is a no-op
+	promoted, err = q.PromoteDelayedTasks(ctx, []string{"due:4"})
+	require.NoError(t, err)
+	assert.Empty(t, promoted)
+}
+
+func TestRescheduleTask(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.PublishTask(ctx, "queued", 2))
+	require.NoError(t, q.PublishDelayedTask(ctx, "delayed", 3, time.Hour))
+
+	// a queued task is re-scored in place
+	require.NoError(t, q.RescheduleTask(ctx, "queued", 2, 9, nil, ""))
+	score, err := mr.ZScore(taskQueueKey, "queued")
+	require.NoError(t, err)
+	assert.Equal(t, float64(9), score)
+
+	// a delayed task keeps its place in the delayed queue with its new priority
+	dueAt := time.Now().Add(2 * time.Hour)
+	require.NoError(t, q.RescheduleTask(ctx, "delayed", 3, 7, &dueAt, ""))
+	delayed, err := mr.ZMembers(delayedQueueKey)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"delayed:7"}, delayed)
+	score, err = mr.ZScore(delayedQueueKey, "delayed:7")
+	require.NoError(t, err)
+	assert.Equal(t, float64(dueAt.Unix()), score)
+
+	// and moves to the task queue once due, while the queued one is held back
+	require.NoError(t, q.RescheduleTask(ctx, "delayed", 7, 7, nil, ""))
+	require.NoError(t, q.RescheduleTask(ctx, "queued", 9, 9, &dueAt, ""))
+	queued, err := mr.ZMembers(taskQueueKey)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"delayed"}, queued)
+	delayed, err = mr.ZMembers(delayedQueueKey)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"queued:9"}, delayed)
+
+	// ZADD XX does not publish a task that was not queued
+	require.NoError(t, q.RescheduleTask(ctx, "leased", 5, 9, nil, ""))
+	require.NoError(t, q.RescheduleTask(ctx, "leased", 5, 9, &dueAt, ""))
+	queued, err = mr.ZMembers(taskQueueKey)
+	require.NoError(t, err)
+	assert.NotContains(t, queued, "leased")
+	delayed, err = mr.ZMembers(delayedQueueKey)
+	require.NoError(t, err)
+	assert.NotContains(t, delayed, "leased:9")
+}
+
+func TestRescheduleTask_UniqueKey(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	ctx := context.Background()
+
+	require.NoError(t, q.PublishUniqueTask(ctx, "task-1", 5, "sync:customer-42"))
+	require.NoError(t, q.PublishDelayedUniqueTask(ctx, "task-2", 5, time.Hour, "sync:customer-42"))
+
+	// a delayed task made due stays delayed until the promoter checks its key
+	require.NoError(t, q.RescheduleTask(ctx, "task-2", 5, 8, nil, "sync:customer-42"))
+	queued, err := mr.ZMembers(taskQueueKey)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"task-1"}, queued)
+	score, err := mr.ZScore(delayedQueueKey, "task-2:8")
+	require.NoError(t, err)
+	assert.LessOrEqual(t, score, float64(time.Now().Unix()))
+
+	promoted, err := q.PromoteDelayedTasks(ctx, []string{"task-2:8"})
+	require.NoError(t, err)
+	assert.Empty(t, promoted)
+	assert.Equal(t, "task-1", mr.HGet(uniqueKeysKey, "sync:customer-42"))
+
+	// a queued task held back is checked again once due
+	dueAt := time.Now().Add(time.Hour)
+	require.NoError(t, q.RescheduleTask(ctx, "task-1", 5, 5, &dueAt, "sync:customer-42"))
+	assert.Equal(t, "sync:customer-42", mr.HGet(delayedUniqueKeysKey, "task-1"))
+
+	mr.ZAdd(delayedQueueKey, float64(time.Now().Add(-time.Second).Unix()), "task-2:8")
+	promoted, err = q.PromoteDelayedTasks(ctx, []string{"task-2:8"})
+	require.NoError(t, err)
+	require.Len(t, promoted, 1)
+	assert.Equal(t, "task-2", mr.HGet(uniqueKeysKey, "sync:customer-42"))
+	assert.Empty(t, mr.HGet(delayedUniqueKeysKey, "task-2"))
+}
+
+func TestParseDelayedMember(t *testing.T) {
+	taskID, priority, err := ParseDelayedMember("6f1c2b1e-7c1d-4c55-9a53-0d1c1f9e2a10:7")
+	require.NoError(t, err)
+	assert.Equal(t, "6f1c2b1e-7c1d-4c55-9a53-0d1c1f9e2a10", taskID)
+	assert.Equal(t, 7, priority)
+
+	_, _, err = ParseDelayedMember("no-priority")
+	assert.Error(t, err)
+
+	_, _, err = ParseDelayedMember("task:high")
+	assert.Error(t, err)
+}
+
+func TestLock(t *testing.T) {
+	q, mr := setupTestQueue(t)
+	ctx := context.Background()
+
+	acquired, err := q.AcquireLock(ctx, "promoter", "worker-1", time.Second)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	acquired, err = q.AcquireLock(ctx, "promoter", "worker-2", time.Second)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+
+	// the holder refreshes its lock
+	acquired, err = q.AcquireLock(ctx, "promoter", "worker-1", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+	assert.Equal(t, time.Minute, mr.TTL(lockPrefix+"promoter"))
+
+	// only the holder can release it
+	require.NoError(t, q.ReleaseLock(ctx, "promoter", "worker-2"))
+	assert.True(t, mr.Exists(lockPrefix+"promoter"))
+	require.NoError(t, q.ReleaseLock(ctx, "promoter", "worker-1"))
+	assert.False(t, mr.Exists(lockPrefix+"promoter"))
+
+	// an expired lock can be taken over
+	acquired, err = q.AcquireLock(ctx, "promoter", "worker-2", time.Second)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+	mr.FastForward(2 * time.Second)
+
+	acquired, err = q.AcquireLock(ctx, "promoter", "worker-1", time.Second)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+}
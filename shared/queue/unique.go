@@ -0,0 +1,81 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// task_unique_keys maps the unique key of a task to the last task published
+// with it. An entry whose task is neither queued nor leased anymore is stale
+// and simply taken over by the next task with that key.
+const uniqueKeysKey = "task_unique_keys"
+
+// task_delayed_unique_keys maps the delayed tasks that have a unique key to
+// it, they go through the same check as PublishUniqueTask once due
+const delayedUniqueKeysKey = "task_delayed_unique_keys"
+
+// heldPromotionDelay is how long a due delayed task waits in the delayed queue
+// when its unique key is held
+const heldPromotionDelay = 5 * time.Second
+
+// ErrUniqueKeyHeld is returned when a task cannot be queued because another
+// task with the same unique key is still queued or in flight.
+var ErrUniqueKeyHeld = errors.New("unique key held by another queued task")
+
+// KEYS: task_unique_keys, task_queue, task_leases
+// ARGV: unique key, taskID, priority
+var publishUniqueScript = redis.NewScript(`
+local holder = redis.call('HGET', KEYS[1], ARGV[1])
+if holder and holder ~= ARGV[2] then
+	if redis.call('ZSCORE', KEYS[2], holder) or redis.call('ZSCORE', KEYS[3], holder) then
+		return 0
+	end
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('ZADD', KEYS[2], ARGV[3], ARGV[2])
+return 1
+`)
+
+// publish a task that must not be queued or run next to another task with the
+// same unique key. it returns ErrUniqueKeyHeld while such a task is queued or
+// leased, publishing the same task again is fine
+func (q *RedisQueue) PublishUniqueTask(ctx context.Context, taskID string, priority int, uniqueKey string) error {
+	keys := []string{uniqueKeysKey, taskQueueKey, leasesKey}
+	published, err := publishUniqueScript.Run(ctx, q.client, keys, uniqueKey, taskID, priority).Int()
+	if err != nil {
+		return fmt.Errorf("failed to publish the task: %w", err)
+	}
+	if published == 0 {
+		return fmt.Errorf("%w: %s", ErrUniqueKeyHeld, uniqueKey)
+	}
+	return nil
+}
+
+// publish a task with a unique key to be queued after a delay, it is only
+// promoted once the key is free
+func (q *RedisQueue) PublishDelayedUniqueTask(
+	ctx context.Context,
+	taskID string,
+	priority int,
+	delay time.Duration,
+	uniqueKey string,
+) error {
+	executeAt := time.Now().UTC().Add(delay).Unix()
+
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, delayedUniqueKeysKey, taskID, uniqueKey)
+		pipe.ZAdd(ctx, delayedQueueKey, redis.Z{
+			Score:  float64(executeAt),
+			Member: fmt.Sprintf("%s:%d", taskID, priority),
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish delayed task: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Package taskstore inserts tasks for every service that creates them, so a
+// new column is only added in one place.
+package taskstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/outbox"
+)
+
+// insertColumns are the columns InsertTask and InsertTasks write, in the
+// order of taskValues
+const insertColumns = `id, type, payload, priority, state,
+			retry_count, max_retries, created_at, timeout_seconds,
+			not_before, failure_policy, workflow_id, workflow_key,
+			unique_key, unique_scope, unique_until, batch_id, group_id,
+			callback_url, callback_secret`
+
+// insertChunkSize bounds the rows of a multi-row insert, postgres takes at
+// most 65535 parameters per statement
+const insertChunkSize = 1000
+
+func taskValues(task *models.Task) []any {
+	failurePolicy := task.FailurePolicy
+	if failurePolicy == "" {
+		failurePolicy = models.FailurePolicyFail
+	}
+
+	return []any{
+		task.ID, task.Type, task.Payload, task.Priority, task.State,
+		task.RetryCount, task.MaxRetries, task.CreatedAt, task.TimeoutSeconds,
+		task.NotBefore, failurePolicy, nullString(task.WorkflowID), nullString(task.WorkflowKey),
+		nullString(task.UniqueKey), nullString(string(task.UniqueScope)), task.UniqueUntil,
+		nullString(task.BatchID), nullString(task.GroupID),
+		nullString(task.CallbackURL), nullString(task.CallbackSecret),
+	}
+}
+
+// InsertTask inserts a task row, and its outbox entry unless the task is
+// blocked.
+func InsertTask(ctx context.Context, tx *sql.Tx, task *models.Task) error {
+	values := taskValues(task)
+	query := `INSERT INTO tasks (` + insertColumns + `) VALUES ` + placeholders(0, len(values))
+
+	if _, err := tx.ExecContext(ctx, query, values...); err != nil {
+		return fmt.Errorf("failed to create task: %w", err)
+	}
+
+	switch {
+	case task.State == models.TaskStateBlocked:
+		return nil
+	case task.NotBefore != nil:
+		return outbox.InsertScheduled(ctx, tx, task.ID, task.Priority, *task.NotBefore)
+	default:
+		return outbox.Insert(ctx, tx, task.ID, task.Priority)
+	}
+}
+
+// InsertTasks is InsertTask for many tasks that are not blocked, using
+// multi-row inserts.
+func InsertTasks(ctx context.Context, tx *sql.Tx, tasks []*models.Task) error {
+	for start := 0; start < len(tasks); start += insertChunkSize {
+		chunk := tasks[start:min(start+insertChunkSize, len(tasks))]
+
+		var (
+			query strings.Builder
+			args  []any
+		)
+		query.WriteString(`INSERT INTO tasks (` + insertColumns + `) VALUES `)
+		for i, task := range chunk {
+			values := taskValues(task)
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteString(placeholders(len(args), len(values)))
+			args = append(args, values...)
+		}
+
+		if _, err := tx.ExecContext(ctx, query.String(), args...); err != nil {
+			return fmt.Errorf("failed to create tasks: %w", err)
+		}
+	}
+
+	entries := make([]outbox.Entry, len(tasks))
+	for i, task := range tasks {
+		entries[i] = outbox.Entry{TaskID: task.ID, Priority: task.Priority, NotBefore: task.NotBefore}
+	}
+	return outbox.InsertEntries(ctx, tx, entries)
+}
+
+// placeholders returns "($n+1, ..., $n+count)"
+func placeholders(n, count int) string {
+	params := make([]string, count)
+	for i := range params {
+		params[i] = fmt.Sprintf("$%d", n+i+1)
+	}
+	return "(" + strings.Join(params, ", ") + ")"
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
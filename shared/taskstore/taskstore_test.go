@@ -0,0 +1,78 @@
+package taskstore
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertTask(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	pending := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{}`), 5)
+	scheduled := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{}`), 5)
+	scheduled.ScheduleAt(time.Now().Add(time.Hour))
+	blocked := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{}`), 5)
+	blocked.BlockOn([]string{pending.ID}, "")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO tasks").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO task_outbox \\(task_id, priority\\)").
+		WithArgs(pending.ID, 5).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO tasks").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO task_outbox \\(task_id, priority, not_before\\)").
+		WithArgs(scheduled.ID, 5, *scheduled.NotBefore).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	// a blocked task gets its outbox entry once it is released
+	mock.ExpectExec("INSERT INTO tasks").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+	tx, err := mockDB.Begin()
+	require.NoError(t, err)
+	for _, task := range []*models.Task{pending, scheduled, blocked} {
+		require.NoError(t, InsertTask(ctx, tx, task))
+	}
+	require.NoError(t, tx.Commit())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertTasks(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	tasks := make([]*models.Task, insertChunkSize+1)
+	for i := range tasks {
+		tasks[i] = models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{}`), 5)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO tasks").WillReturnResult(sqlmock.NewResult(0, insertChunkSize))
+	mock.ExpectExec("INSERT INTO tasks").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO task_outbox").WillReturnResult(sqlmock.NewResult(0, insertChunkSize))
+	mock.ExpectExec("INSERT INTO task_outbox").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := mockDB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, InsertTasks(context.Background(), tx, tasks))
+	require.NoError(t, tx.Commit())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPlaceholders(t *testing.T) {
+	assert.Equal(t, "($1, $2, $3)", placeholders(0, 3))
+	assert.Equal(t, "($21, $22)", placeholders(20, 2))
+}
@@ -12,19 +12,20 @@ import (
 	"github.com/alaajili/task-scheduler/shared/models"
 )
 
-func TestDB(t *testing.T) *database.DB {
-	cfg := config.DatabaseConfig{
-		Host:     "localhost",
-		Port:	  5432,
-		User:	  "postgres",
-		Password: "postgres",
-		DBName:   "taskscheduler_test",
-		SSLMode:  "disable",
-		MaxConns: 5,
-		MaxIdle:  2,	
-	}
+// TestDatabaseConfig is the database TestDB connects to
+var TestDatabaseConfig = config.DatabaseConfig{
+	Host:     "localhost",
+	Port:     5432,
+	User:     "postgres",
+	Password: "postgres",
+	DBName:   "taskscheduler_test",
+	SSLMode:  "disable",
+	MaxConns: 5,
+	MaxIdle:  2,
+}
 
-	db, err := database.NewPostgresDB(cfg)
+func TestDB(t *testing.T) *database.DB {
+	db, err := database.NewPostgresDB(TestDatabaseConfig)
 	if err != nil {
 		t.Fatalf("failed to connect to test databse: %v", err)
 	}
@@ -40,7 +41,7 @@ func TestDB(t *testing.T) *database.DB {
 func CleanupDB(t *testing.T, db *database.DB) {
 	ctx := context.Background()
 
-	tables := []string{"workers", "tasks"}
+	tables := []string{"workers", "tasks", "schedules", "workflows", "task_batches", "task_groups", "dead_letters"}
 	for _, table := range tables {
 		_, err := db.ExecContext(ctx, fmt.Sprintf("TRUNCATE TABLE %s CASCADE", table))
 		if err != nil {
@@ -67,8 +68,9 @@ func CreateTestTask(t *testing.T, db *database.DB, task *models.Task) {
 	query := `
 		INSERT INTO tasks (
 			id, type, payload, priority, state,
-			retry_count, max_retries, created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			retry_count, max_retries, created_at, timeout_seconds,
+			not_before
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	`
 
 	_, err := db.ExecContext(ctx, query,
@@ -80,6 +82,8 @@ func CreateTestTask(t *testing.T, db *database.DB, task *models.Task) {
 		task.RetryCount,
 		task.MaxRetries,
 		task.CreatedAt,
+		task.TimeoutSeconds,
+		task.NotBefore,
 	)
 
 	if err != nil {
@@ -87,25 +91,36 @@ func CreateTestTask(t *testing.T, db *database.DB, task *models.Task) {
 	}
 }
 
+// CreateTestDependency makes a test task depend on another one
+func CreateTestDependency(t *testing.T, db *database.DB, taskID, dependsOn string) {
+	_, err := db.ExecContext(context.Background(),
+		`INSERT INTO task_dependencies (task_id, depends_on) VALUES ($1, $2)`, taskID, dependsOn)
+	if err != nil {
+		t.Fatalf("failed to create test dependency: %v", err)
+	}
+}
+
 func GetTestTaskByID(t *testing.T, db *database.DB, id string) *models.Task {
 	ctx := context.Background()
 
 	query := `
 		SELECT id, type, payload, priority, state, result, error,
 		       retry_count, max_retries, created_at, started_at,
-			   completed_at, worker_id
+			   completed_at, worker_id, timeout_seconds, error_reason,
+			   not_before
 		FROM tasks WHERE id = $1
 	`
 
 	var task models.Task
 	var result, errorMsg sql.NullString
-	var startedAt, completedAt sql.NullTime
-	var workerID sql.NullString
+	var startedAt, completedAt, notBefore sql.NullTime
+	var workerID, errorReason sql.NullString
 	
 	err := db.QueryRowContext(ctx, query, id).Scan(
 		&task.ID, &task.Type, &task.Payload, &task.Priority, &task.State,
 		&result, &errorMsg, &task.RetryCount, &task.MaxRetries,
 		&task.CreatedAt,&startedAt, &completedAt, &workerID,
+		&task.TimeoutSeconds, &errorReason, &notBefore,
 	)
 
 	if err != nil {
@@ -118,6 +133,9 @@ func GetTestTaskByID(t *testing.T, db *database.DB, id string) *models.Task {
 	if errorMsg.Valid {
 		task.Error = errorMsg.String
 	}
+	if errorReason.Valid {
+		task.ErrorReason = models.ErrorReason(errorReason.String)
+	}
 	if startedAt.Valid {
 		task.StartedAt = &startedAt.Time
 	}
@@ -127,6 +145,9 @@ func GetTestTaskByID(t *testing.T, db *database.DB, id string) *models.Task {
 	if workerID.Valid {
 		task.WorkerID = workerID.String
 	}
+	if notBefore.Valid {
+		task.NotBefore = &notBefore.Time
+	}
 
 	return &task
 }
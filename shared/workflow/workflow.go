@@ -0,0 +1,239 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/outbox"
+	"github.com/lib/pq"
+)
+
+// ErrDependencyNotFound is returned when a task depends on a task that does
+// not exist.
+var ErrDependencyNotFound = errors.New("dependency not found")
+
+// finished matches the dependencies that will not run again, failed tasks
+// still have retries to go until retry_count reaches max_retries
+var finished = models.FinishedSQL("p")
+
+// unsuccessful matches finished tasks that did not complete
+const unsuccessful = `(p.state IN ('cancelled', 'skipped') OR (p.state = 'failed' AND p.retry_count >= p.max_retries))`
+
+// InsertDependencies records that a blocked task depends on the given tasks.
+// It must run in the transaction that inserts the task, followed by
+// ResolveTask once the task and all of its dependencies are inserted.
+//
+// Existing dependencies are locked until the transaction commits, so one that
+// finishes concurrently either is seen finished by ResolveTask or sees the new
+// dependency when it resolves its own dependents.
+func InsertDependencies(ctx context.Context, tx *sql.Tx, taskID string, dependsOn []string) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM tasks WHERE id = ANY($1) FOR SHARE`, pq.Array(dependsOn))
+	if err != nil {
+		return fmt.Errorf("failed to lock dependencies: %w", err)
+	}
+	found := make(map[string]bool, len(dependsOn))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan dependency: %w", err)
+		}
+		found[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read dependencies: %w", err)
+	}
+
+	for _, dep := range dependsOn {
+		if !found[dep] {
+			return fmt.Errorf("%w: %s", ErrDependencyNotFound, dep)
+		}
+
+		query := `
+			INSERT INTO task_dependencies (task_id, depends_on)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`
+		if _, err := tx.ExecContext(ctx, query, taskID, dep); err != nil {
+			return fmt.Errorf("failed to insert dependency: %w", err)
+		}
+	}
+	return nil
+}
+
+// Dependencies returns the ids of the tasks a task depends on.
+func Dependencies(ctx context.Context, db *sql.DB, taskID string) ([]string, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT depends_on FROM task_dependencies WHERE task_id = $1 ORDER BY depends_on`, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dependencies: %w", err)
+	}
+	defer rows.Close()
+
+	var deps []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan dependency: %w", err)
+		}
+		deps = append(deps, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dependencies: %w", err)
+	}
+	return deps, nil
+}
+
+// ResolveDependents runs in the transaction that finishes a task, i.e. moves
+// it to a terminal state or fails it for the last time. It releases the
+// dependents that no longer wait for anything and fails or skips the ones
+// whose dependency did not complete. It returns the ids of the released
+// tasks, whose outbox entries are inserted in the same transaction, and of
+// the dependents it finished, which the caller finishes in turn.
+func ResolveDependents(ctx context.Context, tx *sql.Tx, taskID string) ([]string, []string, error) {
+	// lock the dependents in a fixed order, tasks finishing concurrently
+	// wait for each other and the last one sees the others finished
+	rows, err := tx.QueryContext(ctx, `
+		SELECT t.id
+		FROM tasks t
+		JOIN task_dependencies d ON d.task_id = t.id
+		WHERE d.depends_on = $1
+		  AND t.state = 'blocked'
+		ORDER BY t.id
+		FOR UPDATE OF t
+	`, taskID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get dependents: %w", err)
+	}
+	var dependents []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan dependent: %w", err)
+		}
+		dependents = append(dependents, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read dependents: %w", err)
+	}
+
+	var released, finished []string
+	for _, id := range dependents {
+		state, err := resolve(ctx, tx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch state {
+		case models.TaskStatePending, models.TaskStateScheduled:
+			released = append(released, id)
+		case models.TaskStateFailed, models.TaskStateSkipped:
+			finished = append(finished, id)
+		}
+	}
+	return released, finished, nil
+}
+
+// ResolveTask settles a freshly inserted blocked task whose dependencies may
+// already have finished. It returns the state the task ends up in, a task
+// that was failed or skipped is finished by the caller.
+func ResolveTask(ctx context.Context, tx *sql.Tx, taskID string) (models.TaskState, error) {
+	return resolve(ctx, tx, taskID)
+}
+
+// resolve looks at the dependencies of a locked blocked task and moves it on
+// if they allow it. It returns the task's new state.
+func resolve(ctx context.Context, tx *sql.Tx, taskID string) (models.TaskState, error) {
+	var (
+		policy    models.FailurePolicy
+		waiting   int
+		failedDep sql.NullString
+	)
+
+	query := `
+		SELECT t.failure_policy,
+		       COUNT(*) FILTER (WHERE NOT ` + finished + `),
+		       MIN(p.id) FILTER (WHERE ` + unsuccessful + `)
+		FROM tasks t
+		JOIN task_dependencies d ON d.task_id = t.id
+		JOIN tasks p ON p.id = d.depends_on
+		WHERE t.id = $1
+		GROUP BY t.id
+	`
+	err := tx.QueryRowContext(ctx, query, taskID).Scan(&policy, &waiting, &failedDep)
+	if err == sql.ErrNoRows {
+		// no dependencies left, nothing holds the task back
+		return release(ctx, tx, taskID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get dependency states: %w", err)
+	}
+
+	if failedDep.Valid && policy != models.FailurePolicyContinue {
+		state := models.TaskStateFailed
+		if policy == models.FailurePolicySkip {
+			state = models.TaskStateSkipped
+		}
+
+		query := `
+			UPDATE tasks
+			SET state = $2,
+			    completed_at = NOW(),
+			    error = $3,
+			    error_reason = $4,
+			    retry_count = max_retries
+			WHERE id = $1
+			  AND state = 'blocked'
+		`
+		msg := fmt.Sprintf("dependency %s did not complete", failedDep.String)
+		if _, err := tx.ExecContext(ctx, query, taskID, state, msg, models.ErrorReasonDependencyFailed); err != nil {
+			return "", fmt.Errorf("failed to fail dependent task: %w", err)
+		}
+		return state, nil
+	}
+
+	if waiting > 0 {
+		return models.TaskStateBlocked, nil
+	}
+	return release(ctx, tx, taskID)
+}
+
+// release moves a blocked task on to pending, or to scheduled when it is not
+// due yet, and records its outbox entry
+func release(ctx context.Context, tx *sql.Tx, taskID string) (models.TaskState, error) {
+	query := `
+		UPDATE tasks
+		SET state = CASE WHEN not_before > NOW() THEN 'scheduled' ELSE 'pending' END
+		WHERE id = $1
+		  AND state = 'blocked'
+		RETURNING state, priority, not_before
+	`
+
+	var (
+		state     models.TaskState
+		priority  int
+		notBefore sql.NullTime
+	)
+	err := tx.QueryRowContext(ctx, query, taskID).Scan(&state, &priority, &notBefore)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("%w: %s is no longer blocked", models.ErrConflict, taskID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to release task: %w", err)
+	}
+
+	if state == models.TaskStateScheduled {
+		err = outbox.InsertScheduled(ctx, tx, taskID, priority, notBefore.Time)
+	} else {
+		err = outbox.Insert(ctx, tx, taskID, priority)
+	}
+	if err != nil {
+		return "", err
+	}
+	return state, nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -19,10 +20,10 @@ func TestEndToEnd_TaskLifecycle(t *testing.T) {
 		t.Skip("Skipping integration test")
 	}
 
-	// Create a task
+	// Create a task that stays busy long enough for the worker not to finish it
 	taskReq := map[string]interface{}{
-		"type":        "http_request",
-		"payload":     map[string]interface{}{"url": "https://example.com"},
+		"type":        "long_running",
+		"payload":     map[string]interface{}{"duration_seconds": 60, "step_count": 60},
 		"priority":    7,
 		"max_retries": 3,
 	}
@@ -37,7 +38,7 @@ func TestEndToEnd_TaskLifecycle(t *testing.T) {
 	resp.Body.Close()
 
 	assert.NotEmpty(t, task.ID)
-	assert.Equal(t, models.TaskTypeHTTPRequest, task.Type)
+	assert.Equal(t, models.TaskTypeLongRunning, task.Type)
 	assert.Equal(t, 7, task.Priority)
 	assert.Equal(t, models.TaskStatePending, task.State)
 
@@ -53,8 +54,8 @@ func TestEndToEnd_TaskLifecycle(t *testing.T) {
 	assert.Equal(t, task.ID, retrieved.ID)
 	assert.Equal(t, task.Type, retrieved.Type)
 
-	// List tasks
-	resp, err = http.Get(baseURL + "/tasks?state=pending")
+	// List tasks (a worker may already have picked it up, so filter by type)
+	resp, err = http.Get(baseURL + "/tasks?type=long_running")
 	require.NoError(t, err)
 	require.Equal(t, http.StatusOK, resp.StatusCode)
 
@@ -84,6 +85,55 @@ func TestEndToEnd_TaskLifecycle(t *testing.T) {
 	assert.Equal(t, models.TaskStateCancelled, cancelled.State)
 }
 
+func TestEndToEnd_TaskPickedUpByWorker(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test")
+	}
+
+	// Create a task over HTTP, it reaches the worker through the redis queue
+	taskReq := map[string]interface{}{
+		"type": "email_send",
+		"payload": map[string]interface{}{
+			"to":      "test@example.com",
+			"subject": "Integration test",
+			"body":    "Picked up from the queue",
+		},
+		"priority":    9,
+		"max_retries": 3,
+	}
+
+	body, _ := json.Marshal(taskReq)
+	resp, err := http.Post(baseURL+"/tasks", "application/json", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	var task models.Task
+	json.NewDecoder(resp.Body).Decode(&task)
+	resp.Body.Close()
+	require.NotEmpty(t, task.ID)
+
+	// Wait for a worker to complete it
+	var completed models.Task
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err = http.Get(fmt.Sprintf("%s/tasks/%s", baseURL, task.ID))
+		require.NoError(t, err)
+		json.NewDecoder(resp.Body).Decode(&completed)
+		resp.Body.Close()
+
+		if completed.State == models.TaskStateCompleted {
+			break
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	require.Equal(t, models.TaskStateCompleted, completed.State, "task was not completed by a worker")
+
+	assert.NotEmpty(t, completed.WorkerID)
+	assert.NotNil(t, completed.StartedAt)
+	assert.NotNil(t, completed.CompletedAt)
+	assert.NotEmpty(t, completed.Result)
+}
+
 func TestHealthCheck(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test")
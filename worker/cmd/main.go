@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -11,6 +14,7 @@ import (
 	"github.com/alaajili/task-scheduler/shared/config"
 	"github.com/alaajili/task-scheduler/shared/database"
 	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/metrics"
 	"github.com/alaajili/task-scheduler/shared/models"
 	"github.com/alaajili/task-scheduler/shared/queue"
 	"github.com/alaajili/task-scheduler/worker/internal/repository"
@@ -19,6 +23,9 @@ import (
 	"go.uber.org/zap"
 )
 
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
 func main() {
 	if err := logger.Init("development"); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
@@ -52,68 +59,121 @@ func main() {
 		models.TaskTypeEmailSend,
 		models.TaskTypeLongRunning,
 	}
-	workerService := service.NewWorkerService(workerID, taskRepo, rq, taskTypes)
+	workerService := service.NewWorkerService(workerID, taskRepo, rq, taskTypes, cfg.Worker)
+	
+	worker := models.NewWorker(taskTypes)
+	worker.ID = workerID
+	worker.Version = version
+	worker.Host, err = os.Hostname()
+	if err != nil {
+		logger.Warn("Failed to get hostname", zap.Error(err))
+	}
+	
+	pool := service.NewPool(workerService, cfg.Worker.MaxConcurrent, cfg.Worker.TaskPollInterval)
+	heartbeater := service.NewHeartbeater(worker, repository.NewWorkerRepository(db), pool, cfg.Worker.HeartbeatInterval)
+	if err := heartbeater.Register(context.Background()); err != nil {
+		logger.Fatal("Failed to register worker", zap.Error(err))
+	}
 	logger.Info("New worker started",
 		zap.String("worker_id", workerID),
+		zap.String("host", worker.Host),
+		zap.String("version", version),
 		zap.Strings("task_types", taskTypesToStrings(taskTypes)),
 	)
 	
+	// ctx stops the background loops, poolCtx only stops pulling new tasks so
+	// in-flight ones can drain while the worker keeps heartbeating
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	poolCtx, stopPool := context.WithCancel(ctx)
+	defer stopPool()
 	
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	
-	go workerLoop(ctx, workerService, cfg.Worker.TaskPollInterval)
+	go pool.Run(poolCtx)
+	logger.Info("Worker pool started", zap.Int("max_concurrent", pool.Size()))
+	go heartbeater.Run(ctx)
+	go workerService.ListenForCancellations(ctx)
+
+	go leaseSweeperLoop(ctx, rq, cfg.Worker.LeaseSweepInterval)
+
+	promoter := service.NewPromoter(workerID, taskRepo, rq, cfg.Worker.PromoteInterval)
+	go promoter.Run(ctx)
+
+	reaper := service.NewReaper(taskRepo, rq, cfg.Worker.HeartbeatTimeout, cfg.Worker.ReaperInterval)
+	go reaper.Run(ctx)
+
+	deadLetterMonitor := service.NewDeadLetterMonitor(taskRepo, cfg.DeadLetter)
+	go deadLetterMonitor.Run(ctx)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/slots", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pool.Snapshot())
+	})
+
+	metricsServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Worker.MetricsPort),
+		Handler: mux,
+	}
+	go func() {
+		if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Metrics server failed", zap.Error(err))
+		}
+	}()
+	defer metricsServer.Close()
 	
 	sig := <-sigChan
 	logger.Info("Received Shutdown signal", zap.String("signal", sig.String()))
 	
-	cancel()
+	stopPool()
 	
 	shutdownTimeout := cfg.Worker.GracefulShutdownTimeout
-	if shutdownTimeout == 0 {
+	if shutdownTimeout <= 0 {
 		shutdownTimeout = 30 * time.Second
 	}
 	logger.Info("Waiting for tasks to be completed",
 		zap.Duration("timeout", shutdownTimeout),
 	)
 	
-	time.Sleep(2 * time.Second)
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer drainCancel()
+	if err := pool.Shutdown(drainCtx); err != nil {
+		logger.Warn("Running tasks did not finish in time and were requeued", zap.Error(err))
+	}
+	
+	cancel()
+	
+	markCtx, markCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer markCancel()
+	if err := heartbeater.Shutdown(markCtx); err != nil {
+		logger.Error("Failed to mark worker as shut down", zap.Error(err))
+	}
 	
 	logger.Info("Worker stopped", zap.String("worker_id", workerID))
 }
 
-func workerLoop(ctx context.Context, workerService *service.WorkerService, pollInterval time.Duration) {
-	ticker := time.NewTicker(pollInterval)
+// leaseSweeperLoop returns tasks whose lease expired (e.g. their worker crashed)
+// to the queue. Every worker runs it, the requeue itself is atomic in redis.
+func leaseSweeperLoop(ctx context.Context, rq *queue.RedisQueue, interval time.Duration) {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	consecutiveEmptyPolls := 0
-	maxBackoff := 5 * time.Second
-
 	for {
 		select {
 		case <-ctx.Done():
-			logger.Info("Worker loop stopping")
+			logger.Info("Lease sweeper stopping")
 			return
 		case <-ticker.C:
-			// Process next task
-			processed, err := workerService.ProcessNextTask(ctx)
+			requeued, err := rq.RequeueExpired(ctx, 100)
 			if err != nil {
-				logger.Error("Error processing task", zap.Error(err))
+				logger.Error("Failed to requeue expired tasks", zap.Error(err))
 				continue
 			}
-
-			if !processed {
-				// No tasks available - implement backoff
-				consecutiveEmptyPolls++
-				backoff := time.Duration(consecutiveEmptyPolls) * pollInterval
-				backoff = min(backoff, maxBackoff)
-				ticker.Reset(backoff)
-			} else {
-				// Task was processed - reset backoff
-				consecutiveEmptyPolls = 0
-				ticker.Reset(pollInterval)
+			for _, taskID := range requeued {
+				logger.Warn("Requeued task with expired lease", zap.String("task_id", taskID))
 			}
 		}
 	}
@@ -3,6 +3,7 @@ package executor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -12,17 +13,27 @@ import (
 )
 
 
+// ErrTaskTimedOut is returned when a task runs past its execution timeout
+var ErrTaskTimedOut = errors.New("task timed out")
+
+// defaultTimeout applies when neither the task nor the worker config set one
+const defaultTimeout = 5 * time.Minute
+
 type Executor struct {
-	workerID string
-	handlers map[models.TaskType]TaskHandler
+	workerID       string
+	handlers       map[models.TaskType]TaskHandler
+	defaultTimeout time.Duration
+	typeTimeouts   map[models.TaskType]time.Duration
 }
 
 type TaskHandler func(ctx context.Context, payload json.RawMessage) (json.RawMessage, error)
 
 func NewExecutor(workerID string) *Executor {
 	e := &Executor{
-		workerID: workerID,
-		handlers: make(map[models.TaskType]TaskHandler),
+		workerID:       workerID,
+		handlers:       make(map[models.TaskType]TaskHandler),
+		defaultTimeout: defaultTimeout,
+		typeTimeouts:   make(map[models.TaskType]time.Duration),
 	}
 
 	// register task handlers
@@ -38,6 +49,33 @@ func (e *Executor) RegisterHandler(taskType models.TaskType, handler TaskHandler
 	e.handlers[taskType] = handler
 }
 
+// SetDefaultTimeout sets the timeout of tasks that do not specify one and
+// whose type has no timeout of its own
+func (e *Executor) SetDefaultTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		e.defaultTimeout = timeout
+	}
+}
+
+// SetTypeTimeout sets the default timeout of one task type
+func (e *Executor) SetTypeTimeout(taskType models.TaskType, timeout time.Duration) {
+	if timeout > 0 {
+		e.typeTimeouts[taskType] = timeout
+	}
+}
+
+// TimeoutFor resolves the execution timeout of a task: its own timeout first,
+// then the default of its type, then the worker default
+func (e *Executor) TimeoutFor(task *models.Task) time.Duration {
+	if timeout := task.Timeout(); timeout > 0 {
+		return timeout
+	}
+	if timeout, ok := e.typeTimeouts[task.Type]; ok {
+		return timeout
+	}
+	return e.defaultTimeout
+}
+
 func (e *Executor) ExecuteTask(ctx context.Context, task *models.Task) error {
 	logger.Info("Executing task",
 		zap.String("task_id", task.ID),
@@ -53,7 +91,8 @@ func (e *Executor) ExecuteTask(ctx context.Context, task *models.Task) error {
 		return fmt.Errorf("no handler registered for task type: %s", task.Type)
 	}
 	
-	execCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	timeout := e.TimeoutFor(task)
+	execCtx, cancel := context.WithTimeoutCause(ctx, timeout, ErrTaskTimedOut)
 	defer cancel()
 
 	startTime := time.Now()
@@ -66,6 +105,9 @@ func (e *Executor) ExecuteTask(ctx context.Context, task *models.Task) error {
 		zap.Duration("duration", duration),
 		zap.Bool("success", err == nil),
 	)
+	if err != nil && errors.Is(context.Cause(execCtx), ErrTaskTimedOut) {
+		return fmt.Errorf("%w after %s: %v", ErrTaskTimedOut, timeout, err)
+	}
 	if err != nil {
 		return fmt.Errorf("task execution failed: %w", err)
 	}
@@ -68,6 +68,30 @@ func TestExecutor_LongRunning(t *testing.T) {
 	assert.Equal(t, float64(4), result["steps_executed"])
 }
 
+func TestExecutor_LongRunningProgress(t *testing.T) {
+	exec := executor.NewExecutor("test-worker")
+
+	var reported []float64
+	ctx := executor.WithProgress(context.Background(), func(progress float64, message string) {
+		reported = append(reported, progress)
+		assert.NotEmpty(t, message)
+	})
+
+	task := &models.Task{
+		ID:      "test-progress",
+		Type:    models.TaskTypeLongRunning,
+		Payload: json.RawMessage(`{"duration_seconds": 1, "step_count": 4}`),
+	}
+	require.NoError(t, exec.ExecuteTask(ctx, task))
+	assert.Equal(t, []float64{25, 50, 75, 100}, reported)
+
+	// progress is clamped and dropped without a listener
+	executor.ReportProgress(ctx, 150, "done")
+	assert.Equal(t, float64(100), reported[len(reported)-1])
+	executor.ReportProgress(context.Background(), 50, "ignored")
+	assert.Len(t, reported, 5)
+}
+
 func TestExecutor_EmailSend(t *testing.T) {
 	exec := executor.NewExecutor("test-worker")
 	ctx := context.Background()
@@ -158,3 +182,33 @@ func TestExecutor_Timeout(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "cancelled")
 }
+
+func TestExecutor_TaskTimeout(t *testing.T) {
+	exec := executor.NewExecutor("test-worker")
+
+	task := &models.Task{
+		ID:             "test-7",
+		Type:           models.TaskTypeLongRunning,
+		Payload:        json.RawMessage(`{"duration_seconds": 10, "step_count": 5}`),
+		TimeoutSeconds: 1,
+	}
+
+	err := exec.ExecuteTask(context.Background(), task)
+	assert.ErrorIs(t, err, executor.ErrTaskTimedOut)
+}
+
+func TestExecutor_TimeoutFor(t *testing.T) {
+	exec := executor.NewExecutor("test-worker")
+	exec.SetDefaultTimeout(2 * time.Minute)
+	exec.SetTypeTimeout(models.TaskTypeLongRunning, 30*time.Minute)
+
+	emailTask := &models.Task{Type: models.TaskTypeEmailSend}
+	assert.Equal(t, 2*time.Minute, exec.TimeoutFor(emailTask))
+
+	longTask := &models.Task{Type: models.TaskTypeLongRunning}
+	assert.Equal(t, 30*time.Minute, exec.TimeoutFor(longTask))
+
+	// the task's own timeout wins over both defaults
+	longTask.TimeoutSeconds = 90
+	assert.Equal(t, 90*time.Second, exec.TimeoutFor(longTask))
+}
@@ -65,6 +65,8 @@ func (e *Executor) executeLongRunning(ctx context.Context, payload json.RawMessa
 			)
 
 			time.Sleep(stepDuration)
+			ReportProgress(ctx, float64(i+1)*100/float64(req.StepCount),
+				fmt.Sprintf("step %d of %d", i+1, req.StepCount))
 		}
 	}
 
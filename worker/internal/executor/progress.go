@@ -0,0 +1,24 @@
+package executor
+
+import "context"
+
+// ProgressFunc receives the progress a running task reports, as a percentage,
+// with a description of the current step
+type ProgressFunc func(progress float64, message string)
+
+type progressKey struct{}
+
+// WithProgress returns a context whose task reports its progress to fn
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress reports the progress of the task running with ctx, it does
+// nothing when no one listens. progress is clamped to [0, 100].
+func ReportProgress(ctx context.Context, progress float64, message string) {
+	fn, ok := ctx.Value(progressKey{}).(ProgressFunc)
+	if !ok {
+		return
+	}
+	fn(min(max(progress, 0), 100), message)
+}
@@ -0,0 +1,250 @@
+package payload
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alaajili/task-scheduler/shared/models"
+)
+
+// ErrInvalidTemplate is returned when a payload template cannot be resolved,
+// e.g. because it references a parent or a result field that does not exist
+var ErrInvalidTemplate = errors.New("invalid payload template")
+
+// placeholder matches a {{ ... }} reference inside a payload string
+var placeholder = regexp.MustCompile(`\{\{\s*(.*?)\s*\}\}`)
+
+// parentPlaceholder matches the start of a placeholder that references a
+// parent, the other placeholders belong to the payload and are left alone
+var parentPlaceholder = regexp.MustCompile(`\{\{\s*\$?\.parent\.`)
+
+// reference is the start of an expression, .parent.<ref>.result or the
+// JSONPath spelling $.parent.<ref>.result, where ref is a parent task's id or
+// its key within the workflow
+var reference = regexp.MustCompile(`^\$?\.parent\.([^.\[\s]+)\.result`)
+
+// segment is one step of the path into a parent's result: .name, [0], ["name"] or ['name']
+var segment = regexp.MustCompile(`^(?:\.([^.\[\s]+)|\[(\d+)\]|\["([^"]*)"\]|\['([^']*)'\])`)
+
+// HasTemplate reports whether a payload contains references to resolve
+func HasTemplate(payload json.RawMessage) bool {
+	return parentPlaceholder.Match(payload)
+}
+
+// Render resolves the references in the string values of a payload against
+// the results of the task's parents. A string that is a single reference is
+// replaced with the referenced value as is, references inside a longer string
+// are replaced with the value's text. Strings holding JSON, such as the body
+// of an http_request result, can be navigated into like objects. Placeholders
+// that do not start with .parent. are kept as they are.
+func Render(payload json.RawMessage, parents []*models.Task) (json.RawMessage, error) {
+	if !HasTemplate(payload) {
+		return payload, nil
+	}
+
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode payload: %w", err)
+	}
+
+	r := &renderer{parents: parents, results: make(map[string]any)}
+	rendered, err := r.walk(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := json.Marshal(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+	return out, nil
+}
+
+type renderer struct {
+	parents []*models.Task
+	results map[string]any // decoded results by parent id
+}
+
+func (r *renderer) walk(v any) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, elem := range v {
+			rendered, err := r.walk(elem)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = rendered
+		}
+		return v, nil
+	case []any:
+		for i, elem := range v {
+			rendered, err := r.walk(elem)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = rendered
+		}
+		return v, nil
+	case string:
+		return r.renderString(v)
+	default:
+		return v, nil
+	}
+}
+
+func (r *renderer) renderString(s string) (any, error) {
+	var matches [][]int
+	for _, m := range placeholder.FindAllStringSubmatchIndex(s, -1) {
+		if parentPlaceholder.MatchString(s[m[0]:m[1]]) {
+			matches = append(matches, m)
+		}
+	}
+	if len(matches) == 0 {
+		return s, nil
+	}
+
+	// the whole string is one reference, keep the type of the value
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		return r.eval(s[matches[0][2]:matches[0][3]])
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(s[last:m[0]])
+		value, err := r.eval(s[m[2]:m[3]])
+		if err != nil {
+			return nil, err
+		}
+		text, err := toText(value)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(text)
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// eval resolves one expression to the value it references
+func (r *renderer) eval(expr string) (any, error) {
+	m := reference.FindStringSubmatch(expr)
+	if m == nil {
+		return nil, fmt.Errorf("%w: {{ %s }}: expected .parent.<task>.result", ErrInvalidTemplate, expr)
+	}
+
+	parent := r.parent(m[1])
+	if parent == nil {
+		return nil, fmt.Errorf("%w: {{ %s }}: %s is not a parent of this task", ErrInvalidTemplate, expr, m[1])
+	}
+	value, err := r.result(parent)
+	if err != nil {
+		return nil, fmt.Errorf("%w: {{ %s }}: %v", ErrInvalidTemplate, expr, err)
+	}
+
+	path := expr[len(m[0]):]
+	walked := "result"
+	for path != "" {
+		seg := segment.FindStringSubmatch(path)
+		if seg == nil {
+			return nil, fmt.Errorf("%w: {{ %s }}: cannot parse %q", ErrInvalidTemplate, expr, path)
+		}
+		path = path[len(seg[0]):]
+
+		if value, err = step(value, seg); err != nil {
+			return nil, fmt.Errorf("%w: {{ %s }}: %s: %v", ErrInvalidTemplate, expr, walked, err)
+		}
+		walked += seg[0]
+	}
+	return value, nil
+}
+
+func (r *renderer) parent(ref string) *models.Task {
+	for _, parent := range r.parents {
+		if parent.ID == ref || (parent.WorkflowKey != "" && parent.WorkflowKey == ref) {
+			return parent
+		}
+	}
+	return nil
+}
+
+func (r *renderer) result(parent *models.Task) (any, error) {
+	if value, ok := r.results[parent.ID]; ok {
+		return value, nil
+	}
+	if len(parent.Result) == 0 || string(parent.Result) == "null" {
+		return nil, fmt.Errorf("parent %s has no result (state %s)", parent.ID, parent.State)
+	}
+
+	value, err := decode(parent.Result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode result of parent %s: %w", parent.ID, err)
+	}
+	r.results[parent.ID] = value
+	return value, nil
+}
+
+// step follows one path segment into a value
+func step(value any, seg []string) (any, error) {
+	// a string holding JSON, e.g. an http response body
+	if s, ok := value.(string); ok {
+		decoded, err := decode([]byte(s))
+		if err != nil {
+			return nil, fmt.Errorf("is a string that does not hold JSON")
+		}
+		value = decoded
+	}
+
+	if seg[2] != "" {
+		list, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("is not an array")
+		}
+		i, _ := strconv.Atoi(seg[2])
+		if i >= len(list) {
+			return nil, fmt.Errorf("has no index %d", i)
+		}
+		return list[i], nil
+	}
+
+	key := seg[1] + seg[3] + seg[4]
+	obj, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("is not an object")
+	}
+	field, ok := obj[key]
+	if !ok {
+		return nil, fmt.Errorf("has no field %q", key)
+	}
+	return field, nil
+}
+
+func decode(data []byte) (any, error) {
+	var value any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// toText is the text a value is spliced into a longer string as
+func toText(value any) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode value: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,90 @@
+package payload_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/worker/internal/payload"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parents() []*models.Task {
+	fetch := models.NewTask(models.TaskTypeHTTPRequest, nil, 5)
+	fetch.ID = "3f0c9a52-1111-4d2e-9a60-5b1f2c7d8e90"
+	fetch.WorkflowKey = "fetch"
+	fetch.State = models.TaskStateCompleted
+	fetch.Result = json.RawMessage(`{
+		"status_code": 200,
+		"body": "{\"items\": [{\"id\": 7, \"name\": \"first\"}], \"total\": 1}"
+	}`)
+
+	failed := models.NewTask(models.TaskTypeDataProcessing, nil, 5)
+	failed.WorkflowKey = "broken"
+	failed.State = models.TaskStateFailed
+
+	return []*models.Task{fetch, failed}
+}
+
+func TestRender(t *testing.T) {
+	tmpl := json.RawMessage(`{
+		"raw": "{{ .parent.3f0c9a52-1111-4d2e-9a60-5b1f2c7d8e90.result.body }}",
+		"status": "{{ .parent.fetch.result.status_code }}",
+		"items": "{{ $.parent.fetch.result.body.items }}",
+		"first": "{{ .parent.fetch.result.body.items[0]['name'] }}",
+		"message": "got {{ .parent.fetch.result.body.total }} item(s), status {{.parent.fetch.result.status_code}}",
+		"nested": [{"id": "{{ .parent.fetch.result.body.items[0].id }}"}],
+		"plain": "untouched",
+		"greeting": "Hello {{ name }}, {{ .parent.fetch.result.status_code }}"
+	}`)
+
+	rendered, err := payload.Render(tmpl, parents())
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"raw": "{\"items\": [{\"id\": 7, \"name\": \"first\"}], \"total\": 1}",
+		"status": 200,
+		"items": [{"id": 7, "name": "first"}],
+		"first": "first",
+		"message": "got 1 item(s), status 200",
+		"nested": [{"id": 7}],
+		"plain": "untouched",
+		"greeting": "Hello {{ name }}, 200"
+	}`, string(rendered))
+}
+
+func TestRender_NoTemplate(t *testing.T) {
+	plain := json.RawMessage(`{"to": "a@example.com"}`)
+
+	rendered, err := payload.Render(plain, nil)
+	require.NoError(t, err)
+	assert.Equal(t, plain, rendered)
+
+	// placeholders that do not reference a parent belong to the payload
+	greeting := json.RawMessage(`{"body": "Hello {{ name }}"}`)
+	assert.False(t, payload.HasTemplate(greeting))
+	rendered, err = payload.Render(greeting, nil)
+	require.NoError(t, err)
+	assert.Equal(t, greeting, rendered)
+}
+
+func TestRender_MissingReference(t *testing.T) {
+	tests := []struct {
+		tmpl    string
+		message string
+	}{
+		{`{"x": "{{ .parent.unknown.result.body }}"}`, "unknown is not a parent of this task"},
+		{`{"x": "{{ .parent.fetch.result.missing }}"}`, `result: has no field "missing"`},
+		{`{"x": "{{ .parent.fetch.result.body.items[3] }}"}`, "result.body.items: has no index 3"},
+		{`{"x": "{{ .parent.fetch.result.status_code.value }}"}`, "result.status_code: is not an object"},
+		{`{"x": "{{ .parent.broken.result.body }}"}`, "has no result (state failed)"},
+		{`{"x": "{{ .parent.fetch }}"}`, "expected .parent.<task>.result"},
+	}
+
+	for _, tt := range tests {
+		_, err := payload.Render(json.RawMessage(tt.tmpl), parents())
+		require.ErrorIs(t, err, payload.ErrInvalidTemplate, tt.tmpl)
+		assert.Contains(t, err.Error(), tt.message, tt.tmpl)
+	}
+}
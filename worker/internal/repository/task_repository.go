@@ -4,14 +4,27 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/lib/pq"
 	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/lifecycle"
 	"github.com/alaajili/task-scheduler/shared/models"
 )
 
+var (
+	// ErrTaskNotFound is returned when a task id does not match any row
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrTaskNotPending is returned when starting a task that was cancelled
+	// or claimed by another worker in the meantime
+	ErrTaskNotPending = fmt.Errorf("%w: task is not pending", models.ErrConflict)
+	// ErrTaskNotRunning is returned when finishing a task that is no longer
+	// running, typically because it was cancelled while executing
+	ErrTaskNotRunning = fmt.Errorf("%w: task is not running", models.ErrConflict)
+)
+
 // TaskRepository handles database operations for tasks
 type TaskRepository struct {
 	db *database.DB
@@ -30,9 +43,9 @@ func NewTaskRepository(db *database.DB) *TaskRepository {
 func (r *TaskRepository) GetNextPendingTask(ctx context.Context, taskTypes []models.TaskType) (*models.Task, error) {
 	query := `
 		SELECT id, type, payload, priority, state, 
-		       retry_count, max_retries, created_at
+		       retry_count, max_retries, created_at, timeout_seconds
 		FROM tasks
-		WHERE state = 'pending'
+		WHERE (state = 'pending' OR (state = 'scheduled' AND not_before <= NOW()))
 		  AND type = ANY($1)
 		ORDER BY priority DESC, created_at ASC
 		LIMIT 1
@@ -49,7 +62,7 @@ func (r *TaskRepository) GetNextPendingTask(ctx context.Context, taskTypes []mod
 	// Use pq.Array to convert to PostgreSQL array type
 	err := r.db.QueryRowContext(ctx, query, pq.Array(typeStrings)).Scan(
 		&task.ID, &task.Type, &task.Payload, &task.Priority, &task.State,
-		&task.RetryCount, &task.MaxRetries, &task.CreatedAt,
+		&task.RetryCount, &task.MaxRetries, &task.CreatedAt, &task.TimeoutSeconds,
 	)
 
 	if err == sql.ErrNoRows {
@@ -62,14 +75,24 @@ func (r *TaskRepository) GetNextPendingTask(ctx context.Context, taskTypes []mod
 	return &task, nil
 }
 
-// MarkTaskStarted marks a task as started
+// MarkTaskStarted marks a pending task as started and opens its next attempt,
+// or returns ErrTaskNotPending
 func (r *TaskRepository) MarkTaskStarted(ctx context.Context, taskID, workerID string) error {
 	query := `
-		UPDATE tasks 
-		SET state = 'running', 
-		    started_at = NOW(), 
-		    worker_id = $2
-		WHERE id = $1
+		WITH started AS (
+			UPDATE tasks 
+			SET state = 'running', 
+			    started_at = NOW(), 
+			    worker_id = $2
+			WHERE id = $1
+			  AND (state = 'pending' OR (state = 'scheduled' AND not_before <= NOW()))
+			RETURNING id, worker_id, started_at
+		)
+		INSERT INTO task_attempts (task_id, attempt, worker_id, started_at)
+		SELECT id,
+		       COALESCE((SELECT MAX(attempt) FROM task_attempts WHERE task_id = started.id), 0) + 1,
+		       worker_id, started_at
+		FROM started
 	`
 
 	result, err := r.db.ExecContext(ctx, query, taskID, workerID)
@@ -83,13 +106,31 @@ func (r *TaskRepository) MarkTaskStarted(ctx context.Context, taskID, workerID s
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("task not found or already started: %s", taskID)
+		return fmt.Errorf("%w: %s", ErrTaskNotPending, taskID)
 	}
 
 	return nil
 }
 
-// MarkTaskCompleted marks a task as completed with result
+// RecordProgress logs a progress event for a task while it runs on workerID
+func (r *TaskRepository) RecordProgress(ctx context.Context, taskID, workerID string, progress float64, message string) error {
+	query := `
+		INSERT INTO task_events (task_id, task_type, type, state, worker_id, progress, message)
+		SELECT id, type, $2, state, $3, $4, $5
+		FROM tasks
+		WHERE id = $1
+		  AND state = 'running'
+	`
+
+	_, err := r.db.ExecContext(ctx, query, taskID, models.TaskEventProgress, workerID, progress, message)
+	if err != nil {
+		return fmt.Errorf("failed to record task progress: %w", err)
+	}
+	return nil
+}
+
+// MarkTaskCompleted marks a running task as completed with result and releases
+// the tasks that were waiting for it, or returns ErrTaskNotRunning
 func (r *TaskRepository) MarkTaskCompleted(ctx context.Context, taskID string, result json.RawMessage) error {
 	query := `
 		UPDATE tasks 
@@ -97,32 +138,204 @@ func (r *TaskRepository) MarkTaskCompleted(ctx context.Context, taskID string, r
 		    completed_at = NOW(), 
 		    result = $2
 		WHERE id = $1
+		  AND state = 'running'
 	`
 
-	_, err := r.db.ExecContext(ctx, query, taskID, result)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, query, taskID, result)
 	if err != nil {
 		return fmt.Errorf("failed to mark task as completed: %w", err)
 	}
+	if err := requireRunning(res, taskID); err != nil {
+		return err
+	}
+
+	outcome := attemptOutcome{
+		state:      models.AttemptStateCompleted,
+		resultSize: sql.NullInt64{Int64: int64(len(result)), Valid: true},
+	}
+	if err := finishAttempt(ctx, tx, taskID, outcome); err != nil {
+		return err
+	}
 
+	if _, err := lifecycle.Finish(ctx, tx, taskID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit task completion: %w", err)
+	}
 	return nil
 }
 
-// MarkTaskFailed marks a task as failed with error
-func (r *TaskRepository) MarkTaskFailed(ctx context.Context, taskID, errorMsg string) error {
+// MarkTaskFailed marks a running task as failed with error, or returns
+// ErrTaskNotRunning. A final failure uses up the remaining retries. When the
+// task has no retries left its dependents are failed or skipped according to
+// their failure policy.
+func (r *TaskRepository) MarkTaskFailed(
+	ctx context.Context,
+	taskID, errorMsg string,
+	reason models.ErrorReason,
+	final bool,
+) error {
 	query := `
 		UPDATE tasks 
 		SET state = 'failed', 
 		    completed_at = NOW(), 
 		    error = $2,
-		    retry_count = retry_count + 1
+		    error_reason = $3,
+		    retry_count = CASE WHEN $4 THEN GREATEST(retry_count + 1, max_retries) ELSE retry_count + 1 END
 		WHERE id = $1
+		  AND state = 'running'
+		RETURNING retry_count >= max_retries
 	`
 
-	_, err := r.db.ExecContext(ctx, query, taskID, errorMsg)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exhausted bool
+	err = tx.QueryRowContext(ctx, query, taskID, errorMsg, reason, final).Scan(&exhausted)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: %s", ErrTaskNotRunning, taskID)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to mark task as failed: %w", err)
 	}
 
+	outcome := attemptOutcome{state: models.AttemptStateFailed, err: errorMsg, reason: reason}
+	if err := finishAttempt(ctx, tx, taskID, outcome); err != nil {
+		return err
+	}
+
+	if exhausted {
+		if _, err := lifecycle.Finish(ctx, tx, taskID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit task failure: %w", err)
+	}
+	return nil
+}
+
+// requireRunning turns an update that matched no running task into ErrTaskNotRunning
+func requireRunning(res sql.Result, taskID string) error {
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("%w: %s", ErrTaskNotRunning, taskID)
+	}
+
+	return nil
+}
+
+// ReleaseTask hands a task this worker could not finish back to pending
+// without counting a retry
+func (r *TaskRepository) ReleaseTask(ctx context.Context, taskID, workerID string) error {
+	query := `
+		UPDATE tasks
+		SET state = 'pending',
+		    started_at = NULL,
+		    worker_id = NULL
+		WHERE id = $1
+		  AND state = 'running'
+		  AND worker_id = $2
+	`
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, taskID, workerID)
+	if err != nil {
+		return fmt.Errorf("failed to release task: %w", err)
+	}
+	if err := requireRunning(result, taskID); err != nil {
+		return err
+	}
+
+	if err := finishAttempt(ctx, tx, taskID, attemptOutcome{state: models.AttemptStateReleased}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit task release: %w", err)
+	}
+	return nil
+}
+
+// MarkAttemptCancelled closes the attempt of a task that stopped running on
+// this worker without the worker finishing it, typically because the task was
+// cancelled. An attempt already closed, e.g. by the reaper, is left alone.
+func (r *TaskRepository) MarkAttemptCancelled(ctx context.Context, taskID string) error {
+	return finishAttempt(ctx, r.db, taskID, attemptOutcome{state: models.AttemptStateCancelled})
+}
+
+// RecordRetryScheduled records when the failed last attempt of a task is
+// retried
+func (r *TaskRepository) RecordRetryScheduled(ctx context.Context, taskID string, retryDelay time.Duration) error {
+	query := `
+		UPDATE task_attempts
+		SET retry_at = NOW() + make_interval(secs => $2)
+		WHERE id = (SELECT MAX(id) FROM task_attempts WHERE task_id = $1)
+		  AND state = 'failed'
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, taskID, retryDelay.Seconds()); err != nil {
+		return fmt.Errorf("failed to record task retry: %w", err)
+	}
+	return nil
+}
+
+// attemptOutcome is how an attempt ended
+type attemptOutcome struct {
+	state      models.AttemptState
+	err        string
+	reason     models.ErrorReason
+	resultSize sql.NullInt64
+	// retried is set when the task is retried right away
+	retried bool
+}
+
+// execer runs a statement on the database or in a transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// finishAttempt closes the open attempt of a task, a task started before
+// attempts were recorded has none
+func finishAttempt(ctx context.Context, db execer, taskID string, o attemptOutcome) error {
+	query := `
+		UPDATE task_attempts
+		SET state = $2,
+		    finished_at = NOW(),
+		    duration_ms = (EXTRACT(EPOCH FROM NOW() - started_at) * 1000)::BIGINT,
+		    error = NULLIF($3, ''),
+		    error_reason = NULLIF($4, ''),
+		    result_size = $5,
+		    retry_at = CASE WHEN $6 THEN NOW() END
+		WHERE task_id = $1
+		  AND finished_at IS NULL
+	`
+
+	_, err := db.ExecContext(ctx, query, taskID, o.state, o.err, o.reason, o.resultSize, o.retried)
+	if err != nil {
+		return fmt.Errorf("failed to finish task attempt: %w", err)
+	}
 	return nil
 }
 
@@ -134,6 +347,7 @@ func (r *TaskRepository) MarkTaskForRetry(ctx context.Context, taskID string, re
 		    started_at = NULL,
 		    worker_id = NULL
 		WHERE id = $1
+		  AND state = 'failed'
 		  AND retry_count < max_retries
 	`
 
@@ -148,35 +362,300 @@ func (r *TaskRepository) MarkTaskForRetry(ctx context.Context, taskID string, re
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("task not eligible for retry: %s", taskID)
+		return fmt.Errorf("%w: task not eligible for retry: %s", models.ErrConflict, taskID)
 	}
 
 	return nil
 }
 
+// MarkDelayedPending moves failed tasks that are due for a retry and scheduled
+// tasks back to pending and returns the ids of the tasks that are pending afterwards
+func (r *TaskRepository) MarkDelayedPending(ctx context.Context, taskIDs []string) ([]string, error) {
+	query := `
+		UPDATE tasks
+		SET state = 'pending',
+		    started_at = NULL,
+		    worker_id = NULL
+		WHERE id = ANY($1)
+		  AND (state IN ('pending', 'scheduled') OR (state = 'failed' AND retry_count < max_retries))
+		RETURNING id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(taskIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark delayed tasks as pending: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan task id: %w", err)
+		}
+		pending = append(pending, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pending tasks: %w", err)
+	}
+
+	return pending, nil
+}
+
+// OrphanedTask is a running task together with the worker that claimed it
+type OrphanedTask struct {
+	Task   *models.Task
+	Worker *models.Worker // nil when the worker has no row in the workers table
+}
+
+// orphanedByWorker matches running tasks of a worker that is missing, shut down
+// or has not sent a heartbeat within the threshold
+const orphanedByWorker = `
+	t.state = 'running'
+	AND t.worker_id IS NOT NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM workers w
+		WHERE w.id = t.worker_id
+		  AND w.status <> 'shutdown'
+		  AND w.last_heartbeat >= NOW() - make_interval(secs => $1)
+	)
+`
+
+// GetOrphanedTasks returns up to limit running tasks whose worker looks dead
+func (r *TaskRepository) GetOrphanedTasks(ctx context.Context, threshold time.Duration, limit int) ([]OrphanedTask, error) {
+	query := `
+		SELECT t.id, t.type, t.priority, t.state, t.retry_count, t.max_retries,
+		       t.worker_id, w.id, w.status, w.last_heartbeat
+		FROM tasks t
+		LEFT JOIN workers w ON w.id = t.worker_id
+		WHERE ` + orphanedByWorker + `
+		ORDER BY t.started_at ASC
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, threshold.Seconds(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get orphaned tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var orphaned []OrphanedTask
+	for rows.Next() {
+		var task models.Task
+		var taskWorkerID, workerID, status sql.NullString
+		var lastHeartbeat sql.NullTime
+
+		if err := rows.Scan(
+			&task.ID, &task.Type, &task.Priority, &task.State, &task.RetryCount, &task.MaxRetries,
+			&taskWorkerID, &workerID, &status, &lastHeartbeat,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan orphaned task: %w", err)
+		}
+		task.WorkerID = taskWorkerID.String
+
+		entry := OrphanedTask{Task: &task}
+		if workerID.Valid {
+			entry.Worker = &models.Worker{
+				ID:            workerID.String,
+				Status:        models.WorkerStatus(status.String),
+				LastHeartbeat: lastHeartbeat.Time,
+			}
+		}
+		orphaned = append(orphaned, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read orphaned tasks: %w", err)
+	}
+
+	return orphaned, nil
+}
+
+// RequeueOrphanedTask moves a task held by a dead worker back to pending and
+// counts a retry. It returns false when the task is no longer orphaned, e.g.
+// another reaper recovered it first or its worker came back.
+func (r *TaskRepository) RequeueOrphanedTask(
+	ctx context.Context,
+	taskID, workerID, reason string,
+	threshold time.Duration,
+) (bool, error) {
+	query := `
+		UPDATE tasks t
+		SET state = 'pending',
+		    retry_count = retry_count + 1,
+		    started_at = NULL,
+		    worker_id = NULL,
+		    error = $4,
+		    error_reason = 'worker_lost'
+		WHERE t.id = $2 AND t.worker_id = $3 AND ` + orphanedByWorker
+
+	return r.recoverOrphanedTask(ctx, query, taskID, workerID, reason, threshold, false)
+}
+
+// FailOrphanedTask marks a task held by a dead worker as failed. It returns
+// false when the task is no longer orphaned.
+func (r *TaskRepository) FailOrphanedTask(
+	ctx context.Context,
+	taskID, workerID, reason string,
+	threshold time.Duration,
+) (bool, error) {
+	query := `
+		UPDATE tasks t
+		SET state = 'failed',
+		    completed_at = NOW(),
+		    error = $4,
+		    error_reason = 'worker_lost'
+		WHERE t.id = $2 AND t.worker_id = $3 AND ` + orphanedByWorker
+
+	return r.recoverOrphanedTask(ctx, query, taskID, workerID, reason, threshold, true)
+}
+
+// recoverOrphanedTask runs one of the recovery updates and logs the recovered
+// event, a task that is failed for good has its dependents resolved in the
+// same transaction
+func (r *TaskRepository) recoverOrphanedTask(
+	ctx context.Context,
+	query, taskID, workerID, reason string,
+	threshold time.Duration,
+	finished bool,
+) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, threshold.Seconds(), taskID, workerID, reason)
+	if err != nil {
+		return false, fmt.Errorf("failed to recover orphaned task: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO task_events (task_id, task_type, type, state, worker_id, message)
+		SELECT id, type, $2, state, $3, $4
+		FROM tasks
+		WHERE id = $1
+	`, taskID, models.TaskEventRecovered, workerID, reason)
+	if err != nil {
+		return false, fmt.Errorf("failed to record task recovery: %w", err)
+	}
+
+	outcome := attemptOutcome{
+		state:   models.AttemptStateLost,
+		err:     reason,
+		reason:  models.ErrorReasonWorkerLost,
+		retried: !finished,
+	}
+	if err := finishAttempt(ctx, tx, taskID, outcome); err != nil {
+		return false, err
+	}
+
+	if finished {
+		if _, err := lifecycle.Finish(ctx, tx, taskID); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit task recovery: %w", err)
+	}
+	return true, nil
+}
+
+// CountDeadLetters returns the number of dead letters per task type
+func (r *TaskRepository) CountDeadLetters(ctx context.Context) (map[models.TaskType]int, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT task_type, COUNT(*) FROM dead_letters GROUP BY task_type`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[models.TaskType]int)
+	for rows.Next() {
+		var (
+			taskType models.TaskType
+			count    int
+		)
+		if err := rows.Scan(&taskType, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan dead letter count: %w", err)
+		}
+		counts[taskType] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dead letter counts: %w", err)
+	}
+	return counts, nil
+}
+
+// GetTaskParents retrieves the tasks a task depends on, with their results
+func (r *TaskRepository) GetTaskParents(ctx context.Context, taskID string) ([]*models.Task, error) {
+	query := `
+		SELECT p.id, p.type, p.state, p.result, p.workflow_key
+		FROM task_dependencies d
+		JOIN tasks p ON p.id = d.depends_on
+		WHERE d.task_id = $1
+		ORDER BY p.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task parents: %w", err)
+	}
+	defer rows.Close()
+
+	var parents []*models.Task
+	for rows.Next() {
+		var task models.Task
+		var result, workflowKey sql.NullString
+
+		if err := rows.Scan(&task.ID, &task.Type, &task.State, &result, &workflowKey); err != nil {
+			return nil, fmt.Errorf("failed to scan task parent: %w", err)
+		}
+		if result.Valid {
+			task.Result = []byte(result.String)
+		}
+		task.WorkflowKey = workflowKey.String
+		parents = append(parents, &task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read task parents: %w", err)
+	}
+
+	return parents, nil
+}
+
 // GetTaskByID retrieves a task by ID
 func (r *TaskRepository) GetTaskByID(ctx context.Context, taskID string) (*models.Task, error) {
 	query := `
 		SELECT id, type, payload, priority, state, result, error,
 		       retry_count, max_retries, created_at, started_at, 
-		       completed_at, worker_id
+		       completed_at, worker_id, timeout_seconds, error_reason,
+		       not_before, unique_key
 		FROM tasks 
 		WHERE id = $1
 	`
 
 	var task models.Task
 	var result, errorMsg sql.NullString
-	var startedAt, completedAt sql.NullTime
-	var workerID sql.NullString
+	var startedAt, completedAt, notBefore sql.NullTime
+	var workerID, errorReason, uniqueKey sql.NullString
 
 	err := r.db.QueryRowContext(ctx, query, taskID).Scan(
 		&task.ID, &task.Type, &task.Payload, &task.Priority, &task.State,
 		&result, &errorMsg, &task.RetryCount, &task.MaxRetries,
 		&task.CreatedAt, &startedAt, &completedAt, &workerID,
+		&task.TimeoutSeconds, &errorReason, &notBefore, &uniqueKey,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("task not found: %s", taskID)
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get task: %w", err)
@@ -189,15 +668,22 @@ func (r *TaskRepository) GetTaskByID(ctx context.Context, taskID string) (*model
 	if errorMsg.Valid {
 		task.Error = errorMsg.String
 	}
+	if errorReason.Valid {
+		task.ErrorReason = models.ErrorReason(errorReason.String)
+	}
 	if startedAt.Valid {
 		task.StartedAt = &startedAt.Time
 	}
 	if completedAt.Valid {
 		task.CompletedAt = &completedAt.Time
 	}
+	if notBefore.Valid {
+		task.NotBefore = &notBefore.Time
+	}
 	if workerID.Valid {
 		task.WorkerID = workerID.String
 	}
+	task.UniqueKey = uniqueKey.String
 
 	return &task, nil
 }
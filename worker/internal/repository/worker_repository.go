@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/alaajili/task-scheduler/shared/database"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/lib/pq"
+)
+
+// ErrWorkerNotFound is returned when a worker id does not match any row
+var ErrWorkerNotFound = errors.New("worker not found")
+
+// WorkerRepository handles database operations for workers
+type WorkerRepository struct {
+	db *database.DB
+}
+
+// NewWorkerRepository creates a new worker repository
+func NewWorkerRepository(db *database.DB) *WorkerRepository {
+	return &WorkerRepository{db: db}
+}
+
+// RegisterWorker inserts the worker, or refreshes its row when a worker with
+// the same id registered before
+func (r *WorkerRepository) RegisterWorker(ctx context.Context, worker *models.Worker) error {
+	query := `
+		INSERT INTO workers (
+			id, status, host, version, current_task_ids,
+			task_types, last_heartbeat, created_at
+		) VALUES ($1, $2, $3, $4, '{}', $5, NOW(), NOW())
+		ON CONFLICT (id) DO UPDATE
+		SET status = EXCLUDED.status,
+		    host = EXCLUDED.host,
+		    version = EXCLUDED.version,
+		    current_task_ids = '{}',
+		    task_types = EXCLUDED.task_types,
+		    last_heartbeat = NOW()
+	`
+
+	taskTypes := make([]string, len(worker.TaskTypes))
+	for i, t := range worker.TaskTypes {
+		taskTypes[i] = string(t)
+	}
+
+	_, err := r.db.ExecContext(ctx, query,
+		worker.ID, worker.Status, worker.Host, worker.Version, pq.Array(taskTypes),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register worker: %w", err)
+	}
+
+	return nil
+}
+
+// Heartbeat records that the worker is alive along with what it is running
+func (r *WorkerRepository) Heartbeat(
+	ctx context.Context,
+	workerID string,
+	status models.WorkerStatus,
+	taskIDs []string,
+) error {
+	query := `
+		UPDATE workers
+		SET status = $2,
+		    current_task_ids = $3,
+		    last_heartbeat = NOW()
+		WHERE id = $1
+	`
+
+	if taskIDs == nil {
+		taskIDs = []string{}
+	}
+
+	result, err := r.db.ExecContext(ctx, query, workerID, status, pq.Array(taskIDs))
+	if err != nil {
+		return fmt.Errorf("failed to record heartbeat: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
+	}
+
+	return nil
+}
+
+// MarkWorkerShutdown marks the worker as shut down and clears its tasks
+func (r *WorkerRepository) MarkWorkerShutdown(ctx context.Context, workerID string) error {
+	query := `
+		UPDATE workers
+		SET status = $2,
+		    current_task_ids = '{}',
+		    last_heartbeat = NOW()
+		WHERE id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, workerID, models.WorkerStatusShutdown)
+	if err != nil {
+		return fmt.Errorf("failed to mark worker shutdown: %w", err)
+	}
+
+	return nil
+}
+
+// GetWorkerByID retrieves a worker by ID
+func (r *WorkerRepository) GetWorkerByID(ctx context.Context, workerID string) (*models.Worker, error) {
+	query := `
+		SELECT id, status, host, version, current_task_ids,
+		       task_types, last_heartbeat, created_at
+		FROM workers
+		WHERE id = $1
+	`
+
+	var worker models.Worker
+	var taskIDs, taskTypes []string
+
+	err := r.db.QueryRowContext(ctx, query, workerID).Scan(
+		&worker.ID, &worker.Status, &worker.Host, &worker.Version, pq.Array(&taskIDs),
+		pq.Array(&taskTypes), &worker.LastHeartbeat, &worker.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrWorkerNotFound, workerID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worker: %w", err)
+	}
+
+	worker.CurrentTaskIDs = taskIDs
+	worker.TaskTypes = make([]models.TaskType, len(taskTypes))
+	for i, t := range taskTypes {
+		worker.TaskTypes[i] = models.TaskType(t)
+	}
+
+	return &worker, nil
+}
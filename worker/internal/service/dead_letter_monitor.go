@@ -0,0 +1,168 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/config"
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/metrics"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/worker/internal/repository"
+	"go.uber.org/zap"
+)
+
+// webhookTimeout bounds the delivery of an alert to the webhook
+const webhookTimeout = 10 * time.Second
+
+// DeadLetterAlert is raised when the dead letters grew while above the
+// threshold.
+type DeadLetterAlert struct {
+	Count     int                     `json:"count"`
+	Previous  int                     `json:"previous"`
+	Threshold int                     `json:"threshold"`
+	ByType    map[models.TaskType]int `json:"by_type"`
+	RaisedAt  time.Time               `json:"raised_at"`
+}
+
+// AlertHook is called with every dead letter alert.
+type AlertHook func(ctx context.Context, alert DeadLetterAlert)
+
+// DeadLetterMonitor exports the number of dead letters as a metric and calls
+// its hooks when they grow past the threshold. Every worker runs one, each
+// alerts on its own.
+type DeadLetterMonitor struct {
+	taskRepo  *repository.TaskRepository
+	interval  time.Duration
+	threshold int
+	hooks     []AlertHook
+
+	// previous is the count seen by the last check, -1 before the first one
+	previous int
+}
+
+func NewDeadLetterMonitor(taskRepo *repository.TaskRepository, cfg config.DeadLetterConfig) *DeadLetterMonitor {
+	m := &DeadLetterMonitor{
+		taskRepo:  taskRepo,
+		interval:  cfg.CheckInterval,
+		threshold: cfg.AlertThreshold,
+		previous:  -1,
+	}
+	if m.interval <= 0 {
+		m.interval = 30 * time.Second
+	}
+	if m.threshold < 0 {
+		m.threshold = 0
+	}
+	if cfg.AlertWebhookURL != "" {
+		m.OnAlert(WebhookAlertHook(cfg.AlertWebhookURL))
+	}
+	return m
+}
+
+// OnAlert registers a hook called with every alert.
+func (m *DeadLetterMonitor) OnAlert(hook AlertHook) {
+	m.hooks = append(m.hooks, hook)
+}
+
+// Run checks the dead letters on every tick until the context is cancelled
+func (m *DeadLetterMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Dead letter monitor stopping")
+			return
+		case <-ticker.C:
+			if _, err := m.CheckOnce(ctx); err != nil {
+				logger.Error("Failed to check dead letters", zap.Error(err))
+			}
+		}
+	}
+}
+
+// CheckOnce updates the metric and raises an alert when the dead letters grew
+// since the previous check and are above the threshold. The first check only
+// takes the baseline. It returns the number of dead letters.
+func (m *DeadLetterMonitor) CheckOnce(ctx context.Context) (int, error) {
+	counts, err := m.taskRepo.CountDeadLetters(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	metrics.DeadLetters.Reset()
+	count := 0
+	for taskType, n := range counts {
+		metrics.DeadLetters.WithLabelValues(string(taskType)).Set(float64(n))
+		count += n
+	}
+
+	previous := m.previous
+	m.previous = count
+	if previous < 0 || count <= previous || count <= m.threshold {
+		return count, nil
+	}
+
+	alert := DeadLetterAlert{
+		Count:     count,
+		Previous:  previous,
+		Threshold: m.threshold,
+		ByType:    counts,
+		RaisedAt:  time.Now().UTC(),
+	}
+	logger.Warn("Dead letters growing",
+		zap.Int("count", count),
+		zap.Int("previous", previous),
+		zap.Int("threshold", m.threshold),
+	)
+	metrics.DeadLetterAlerts.Inc()
+	for _, hook := range m.hooks {
+		hook(ctx, alert)
+	}
+	return count, nil
+}
+
+// WebhookAlertHook posts every alert as JSON to url, a failed delivery is
+// only logged, the next growth raises another alert.
+func WebhookAlertHook(url string) AlertHook {
+	client := &http.Client{Timeout: webhookTimeout}
+
+	return func(ctx context.Context, alert DeadLetterAlert) {
+		if err := postAlert(ctx, client, url, alert); err != nil {
+			logger.Warn("Failed to deliver dead letter alert",
+				zap.String("url", url),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+func postAlert(ctx context.Context, client *http.Client, url string, alert DeadLetterAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alaajili/task-scheduler/shared/config"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/testutil"
+	"github.com/alaajili/task-scheduler/worker/internal/repository"
+	"github.com/alaajili/task-scheduler/worker/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failForGood runs a task and fails it without retries left
+func failForGood(t *testing.T, repo *repository.TaskRepository, taskType models.TaskType) *models.Task {
+	ctx := context.Background()
+
+	task := models.NewTask(taskType, json.RawMessage(`{}`), 5)
+	testutil.CreateTestTask(t, repo.DB(), task)
+	require.NoError(t, repo.MarkTaskStarted(ctx, task.ID, "test-worker"))
+	require.NoError(t, repo.MarkTaskFailed(ctx, task.ID, "boom", models.ErrorReasonHandler, true))
+	return task
+}
+
+func TestDeadLetterMonitor(t *testing.T) {
+	db := testutil.TestDB(t)
+	repo := repository.NewTaskRepository(db)
+	ctx := context.Background()
+
+	var received []service.DeadLetterAlert
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert service.DeadLetterAlert
+		json.NewDecoder(r.Body).Decode(&alert)
+		received = append(received, alert)
+	}))
+	defer webhook.Close()
+
+	monitor := service.NewDeadLetterMonitor(repo, config.DeadLetterConfig{AlertThreshold: 1, AlertWebhookURL: webhook.URL})
+	var alerts []service.DeadLetterAlert
+	monitor.OnAlert(func(ctx context.Context, alert service.DeadLetterAlert) {
+		alerts = append(alerts, alert)
+	})
+
+	// a failure that is retried is not a dead letter
+	task := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{}`), 5)
+	task.MaxRetries = 3
+	testutil.CreateTestTask(t, db, task)
+	require.NoError(t, repo.MarkTaskStarted(ctx, task.ID, "test-worker"))
+	require.NoError(t, repo.MarkTaskFailed(ctx, task.ID, "boom", models.ErrorReasonHandler, false))
+
+	// the first check takes the baseline
+	failForGood(t, repo, models.TaskTypeEmailSend)
+	count, err := monitor.CheckOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Empty(t, alerts)
+
+	failForGood(t, repo, models.TaskTypeEmailSend)
+	failForGood(t, repo, models.TaskTypeHTTPRequest)
+	count, err = monitor.CheckOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, 3, alerts[0].Count)
+	assert.Equal(t, 1, alerts[0].Previous)
+	assert.Equal(t, map[models.TaskType]int{models.TaskTypeEmailSend: 2, models.TaskTypeHTTPRequest: 1}, alerts[0].ByType)
+	require.Len(t, received, 1)
+	assert.Equal(t, 3, received[0].Count)
+
+	// no growth, no alert
+	_, err = monitor.CheckOnce(ctx)
+	require.NoError(t, err)
+	assert.Len(t, alerts, 1)
+}
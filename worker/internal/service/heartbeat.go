@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/worker/internal/repository"
+	"go.uber.org/zap"
+)
+
+// Heartbeater keeps the row of this worker in the workers table up to date:
+// registered on startup, refreshed on every interval with the tasks running in
+// the pool, and marked shutdown on exit.
+type Heartbeater struct {
+	worker     *models.Worker
+	workerRepo *repository.WorkerRepository
+	pool       *Pool
+	interval   time.Duration
+}
+
+func NewHeartbeater(
+	worker *models.Worker,
+	workerRepo *repository.WorkerRepository,
+	pool *Pool,
+	interval time.Duration,
+) *Heartbeater {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	return &Heartbeater{
+		worker:     worker,
+		workerRepo: workerRepo,
+		pool:       pool,
+		interval:   interval,
+	}
+}
+
+// Register records the worker as idle with no running tasks
+func (h *Heartbeater) Register(ctx context.Context) error {
+	h.worker.Status = models.WorkerStatusIdle
+	h.worker.CurrentTaskIDs = nil
+	h.worker.MarkHeartbeat()
+
+	return h.workerRepo.RegisterWorker(ctx, h.worker)
+}
+
+// Run sends a heartbeat on every tick until the context is cancelled
+func (h *Heartbeater) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Heartbeat stopping")
+			return
+		case <-ticker.C:
+			if err := h.Beat(ctx); err != nil {
+				logger.Error("Failed to send heartbeat", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Beat records the current status and tasks of the worker. A worker whose row
+// disappeared registers again.
+func (h *Heartbeater) Beat(ctx context.Context) error {
+	var taskIDs []string
+	for _, slot := range h.pool.Snapshot() {
+		if slot.Busy {
+			taskIDs = append(taskIDs, slot.TaskID)
+		}
+	}
+
+	status := models.WorkerStatusIdle
+	if len(taskIDs) > 0 {
+		status = models.WorkerStatusActive
+	}
+
+	err := h.workerRepo.Heartbeat(ctx, h.worker.ID, status, taskIDs)
+	if errors.Is(err, repository.ErrWorkerNotFound) {
+		logger.Warn("Worker row missing, registering again", zap.String("worker_id", h.worker.ID))
+		if err := h.Register(ctx); err != nil {
+			return err
+		}
+		err = h.workerRepo.Heartbeat(ctx, h.worker.ID, status, taskIDs)
+	}
+	return err
+}
+
+// Shutdown marks the worker as shut down
+func (h *Heartbeater) Shutdown(ctx context.Context) error {
+	return h.workerRepo.MarkWorkerShutdown(ctx, h.worker.ID)
+}
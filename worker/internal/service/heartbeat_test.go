@@ -0,0 +1,70 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/testutil"
+	"github.com/alaajili/task-scheduler/worker/internal/repository"
+	"github.com/alaajili/task-scheduler/worker/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeartbeater_Lifecycle(t *testing.T) {
+	db := testutil.TestDB(t)
+	workerRepo := repository.NewWorkerRepository(db)
+	ctx := context.Background()
+
+	worker := models.NewWorker([]models.TaskType{models.TaskTypeEmailSend})
+	worker.ID = "worker-heartbeat"
+	worker.Host = "test-host"
+	worker.Version = "test"
+
+	workerService, _ := setupWorkerTest(t)
+	pool := service.NewPool(workerService, 2, time.Second)
+	heartbeater := service.NewHeartbeater(worker, workerRepo, pool, time.Second)
+
+	require.NoError(t, heartbeater.Register(ctx))
+
+	registered, err := workerRepo.GetWorkerByID(ctx, worker.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.WorkerStatusIdle, registered.Status)
+	assert.Equal(t, "test-host", registered.Host)
+	assert.Equal(t, "test", registered.Version)
+	assert.Equal(t, []models.TaskType{models.TaskTypeEmailSend}, registered.TaskTypes)
+	assert.Empty(t, registered.CurrentTaskIDs)
+
+	require.NoError(t, heartbeater.Beat(ctx))
+
+	beat, err := workerRepo.GetWorkerByID(ctx, worker.ID)
+	require.NoError(t, err)
+	assert.False(t, beat.LastHeartbeat.Before(registered.LastHeartbeat))
+
+	require.NoError(t, heartbeater.Shutdown(ctx))
+
+	stopped, err := workerRepo.GetWorkerByID(ctx, worker.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.WorkerStatusShutdown, stopped.Status)
+}
+
+func TestHeartbeater_RegistersAgainWhenRowIsMissing(t *testing.T) {
+	db := testutil.TestDB(t)
+	workerRepo := repository.NewWorkerRepository(db)
+	ctx := context.Background()
+
+	worker := models.NewWorker([]models.TaskType{models.TaskTypeEmailSend})
+	worker.ID = "worker-missing"
+
+	workerService, _ := setupWorkerTest(t)
+	pool := service.NewPool(workerService, 1, time.Second)
+	heartbeater := service.NewHeartbeater(worker, workerRepo, pool, time.Second)
+
+	require.NoError(t, heartbeater.Beat(ctx))
+
+	registered, err := workerRepo.GetWorkerByID(ctx, worker.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.WorkerStatusIdle, registered.Status)
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/metrics"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"go.uber.org/zap"
+)
+
+const maxPollBackoff = 5 * time.Second
+
+// TaskRunner claims and runs tasks on behalf of a pool. WorkerService is the
+// implementation used by the worker.
+type TaskRunner interface {
+	ClaimNextTask(ctx context.Context) (*ClaimedTask, error)
+	RunTask(ctx context.Context, claimed *ClaimedTask) error
+}
+
+// SlotState describes what one pool slot is doing
+type SlotState struct {
+	Slot      int             `json:"slot"`
+	Busy      bool            `json:"busy"`
+	TaskID    string          `json:"task_id,omitempty"`
+	TaskType  models.TaskType `json:"task_type,omitempty"`
+	StartedAt *time.Time      `json:"started_at,omitempty"`
+}
+
+// Pool runs up to size tasks concurrently. A new task is claimed as soon as a
+// slot frees up, polling only backs off while there is nothing to claim.
+//
+// Tasks run under a context owned by the pool rather than the one passed to
+// Run, so stopping the dispatch loop lets in-flight tasks finish. Shutdown
+// waits for them and cancels whatever is still running once it gives up.
+type Pool struct {
+	runner       TaskRunner
+	size         int
+	pollInterval time.Duration
+
+	taskCtx    context.Context
+	cancelTask context.CancelFunc
+	stopped    chan struct{}
+
+	mu    sync.Mutex
+	slots []SlotState
+	idle  chan int
+	wg    sync.WaitGroup
+}
+
+func NewPool(runner TaskRunner, size int, pollInterval time.Duration) *Pool {
+	if size <= 0 {
+		size = 1
+	}
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+
+	taskCtx, cancelTask := context.WithCancel(context.Background())
+
+	p := &Pool{
+		runner:       runner,
+		size:         size,
+		pollInterval: pollInterval,
+		taskCtx:      taskCtx,
+		cancelTask:   cancelTask,
+		stopped:      make(chan struct{}),
+		slots:        make([]SlotState, size),
+		idle:         make(chan int, size),
+	}
+	for i := range p.slots {
+		p.slots[i].Slot = i
+		p.idle <- i
+	}
+	return p
+}
+
+// Run dispatches tasks to idle slots until the context is cancelled. Tasks
+// already running are left alone, see Shutdown.
+func (p *Pool) Run(ctx context.Context) {
+	defer close(p.stopped)
+
+	emptyPolls := 0
+	for {
+		var slot int
+		select {
+		case <-ctx.Done():
+			logger.Info("Worker pool stopping")
+			return
+		case slot = <-p.idle:
+		}
+
+		claimed, err := p.runner.ClaimNextTask(ctx)
+		if err != nil || claimed == nil {
+			p.idle <- slot
+			if err != nil && ctx.Err() == nil {
+				logger.Error("Error claiming task", zap.Error(err))
+			}
+
+			// nothing to run, back off before polling again
+			emptyPolls++
+			backoff := min(time.Duration(emptyPolls)*p.pollInterval, maxPollBackoff)
+			select {
+			case <-ctx.Done():
+				logger.Info("Worker pool stopping")
+				return
+			case <-time.After(backoff):
+			}
+			continue
+		}
+
+		emptyPolls = 0
+		p.start(slot, claimed)
+	}
+}
+
+func (p *Pool) start(slot int, claimed *ClaimedTask) {
+	startedAt := time.Now().UTC()
+	p.setSlot(slot, SlotState{
+		Slot:      slot,
+		Busy:      true,
+		TaskID:    claimed.Task.ID,
+		TaskType:  claimed.Task.Type,
+		StartedAt: &startedAt,
+	})
+	metrics.WorkerSlotsBusy.Inc()
+
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			p.setSlot(slot, SlotState{Slot: slot})
+			metrics.WorkerSlotsBusy.Dec()
+			p.idle <- slot
+			p.wg.Done()
+		}()
+
+		if err := p.runner.RunTask(p.taskCtx, claimed); err != nil {
+			logger.Error("Error processing task",
+				zap.String("task_id", claimed.Task.ID),
+				zap.Int("slot", slot),
+				zap.Error(err),
+			)
+		}
+	}()
+}
+
+// Shutdown waits for the running tasks to finish. When ctx expires first the
+// remaining tasks are cancelled, and Shutdown waits for them to hand their work
+// back before returning ctx's error. Cancel the context given to Run first.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		// no task can start once the dispatch loop returned
+		<-p.stopped
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancelTask()
+		return nil
+	case <-ctx.Done():
+		logger.Warn("Drain timed out, cancelling running tasks", zap.Int("running", p.busy()))
+		p.cancelTask()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) busy() int {
+	busy := 0
+	for _, slot := range p.Snapshot() {
+		if slot.Busy {
+			busy++
+		}
+	}
+	return busy
+}
+
+func (p *Pool) setSlot(slot int, state SlotState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.slots[slot] = state
+}
+
+// Snapshot returns the current state of every slot
+func (p *Pool) Snapshot() []SlotState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	snapshot := make([]SlotState, len(p.slots))
+	copy(snapshot, p.slots)
+	return snapshot
+}
+
+// Size returns the number of slots in the pool
+func (p *Pool) Size() int {
+	return p.size
+}
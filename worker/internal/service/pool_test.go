@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRunner struct {
+	mu      sync.Mutex
+	pending int
+	claimed int
+
+	running    atomic.Int32
+	maxRunning atomic.Int32
+	completed  atomic.Int32
+	cancelled  atomic.Int32
+	release    chan struct{}
+}
+
+func (r *fakeRunner) ClaimNextTask(ctx context.Context) (*ClaimedTask, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending == 0 {
+		return nil, nil
+	}
+	r.pending--
+	r.claimed++
+	task := &models.Task{ID: fmt.Sprintf("task-%d", r.claimed), Type: models.TaskTypeLongRunning}
+	return &ClaimedTask{Task: task}, nil
+}
+
+func (r *fakeRunner) RunTask(ctx context.Context, claimed *ClaimedTask) error {
+	running := r.running.Add(1)
+	defer r.running.Add(-1)
+
+	for {
+		peak := r.maxRunning.Load()
+		if running <= peak || r.maxRunning.CompareAndSwap(peak, running) {
+			break
+		}
+	}
+
+	select {
+	case <-r.release:
+		r.completed.Add(1)
+	case <-ctx.Done():
+		r.cancelled.Add(1)
+	}
+	return nil
+}
+
+func TestPool_BoundsConcurrency(t *testing.T) {
+	runner := &fakeRunner{pending: 5, release: make(chan struct{})}
+	pool := NewPool(runner, 3, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return runner.running.Load() == 3 }, time.Second, 5*time.Millisecond)
+
+	busy := 0
+	for _, slot := range pool.Snapshot() {
+		if slot.Busy {
+			busy++
+			assert.NotEmpty(t, slot.TaskID)
+			assert.NotNil(t, slot.StartedAt)
+		}
+	}
+	assert.Equal(t, 3, busy)
+
+	// freeing slots lets the remaining tasks in
+	close(runner.release)
+	require.Eventually(t, func() bool { return runner.completed.Load() == 5 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(3), runner.maxRunning.Load())
+
+	cancel()
+	<-done
+	require.NoError(t, pool.Shutdown(context.Background()))
+
+	for _, slot := range pool.Snapshot() {
+		assert.False(t, slot.Busy)
+	}
+}
+
+func TestPool_ShutdownDrainsRunningTasks(t *testing.T) {
+	runner := &fakeRunner{pending: 2, release: make(chan struct{})}
+	pool := NewPool(runner, 4, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go pool.Run(ctx)
+
+	require.Eventually(t, func() bool { return runner.running.Load() == 2 }, time.Second, 5*time.Millisecond)
+
+	// stopping the dispatch loop does not touch running tasks
+	cancel()
+	shutdown := make(chan error)
+	go func() { shutdown <- pool.Shutdown(context.Background()) }()
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(2), runner.running.Load())
+
+	close(runner.release)
+	select {
+	case err := <-shutdown:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("pool did not drain")
+	}
+	assert.Equal(t, int32(2), runner.completed.Load())
+	assert.Zero(t, runner.cancelled.Load())
+}
+
+func TestPool_ShutdownCancelsAfterTimeout(t *testing.T) {
+	runner := &fakeRunner{pending: 2, release: make(chan struct{})}
+	pool := NewPool(runner, 2, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go pool.Run(ctx)
+
+	require.Eventually(t, func() bool { return runner.running.Load() == 2 }, time.Second, 5*time.Millisecond)
+	cancel()
+
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer drainCancel()
+
+	err := pool.Shutdown(drainCtx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(2), runner.cancelled.Load())
+	assert.Zero(t, runner.running.Load())
+}
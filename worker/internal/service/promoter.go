@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/metrics"
+	"github.com/alaajili/task-scheduler/shared/queue"
+	"github.com/alaajili/task-scheduler/worker/internal/repository"
+	"go.uber.org/zap"
+)
+
+const promoterLock = "delayed-queue-promoter"
+
+// Promoter moves retries and scheduled tasks that became due from the delayed
+// queue into the task queue and flips their rows to pending. Every worker runs one, but
+// only the replica holding the promoter lock does any work.
+type Promoter struct {
+	id       string
+	taskRepo *repository.TaskRepository
+	queue    *queue.RedisQueue
+	interval time.Duration
+	lockTTL  time.Duration
+}
+
+func NewPromoter(
+	id string,
+	taskRepo *repository.TaskRepository,
+	queue *queue.RedisQueue,
+	interval time.Duration,
+) *Promoter {
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	return &Promoter{
+		id:       id,
+		taskRepo: taskRepo,
+		queue:    queue,
+		interval: interval,
+		// outlive a few missed ticks so leadership does not flap
+		lockTTL: 5 * interval,
+	}
+}
+
+// Run promotes due tasks on every tick until the context is cancelled
+func (p *Promoter) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Delayed queue promoter stopping")
+			// hand leadership over right away instead of waiting for the ttl
+			if err := p.queue.ReleaseLock(context.Background(), promoterLock, p.id); err != nil {
+				logger.Warn("Failed to release promoter lock", zap.Error(err))
+			}
+			return
+		case <-ticker.C:
+			if _, err := p.PromoteOnce(ctx); err != nil {
+				logger.Error("Failed to promote delayed tasks", zap.Error(err))
+			}
+		}
+	}
+}
+
+// PromoteOnce promotes the delayed tasks that are due and returns how many
+// reached the task queue. It does nothing unless this instance is the leader.
+func (p *Promoter) PromoteOnce(ctx context.Context) (int, error) {
+	leader, err := p.queue.AcquireLock(ctx, promoterLock, p.id, p.lockTTL)
+	if err != nil {
+		return 0, err
+	}
+	if !leader {
+		return 0, nil
+	}
+
+	members, err := p.queue.GetReadyDelayedTasks(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(members) == 0 {
+		return 0, nil
+	}
+
+	memberByID := make(map[string]string, len(members))
+	taskIDs := make([]string, 0, len(members))
+	for _, member := range members {
+		taskID, _, err := queue.ParseDelayedMember(member)
+		if err != nil {
+			logger.Error("Dropping malformed delayed task", zap.String("member", member), zap.Error(err))
+			p.drop(ctx, member)
+			continue
+		}
+		memberByID[taskID] = member
+		taskIDs = append(taskIDs, taskID)
+	}
+
+	// flip the rows first, so a worker never pops a task that is still failed
+	// or scheduled
+	pending, err := p.taskRepo.MarkDelayedPending(ctx, taskIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	ready := make([]string, 0, len(pending))
+	for _, taskID := range pending {
+		ready = append(ready, memberByID[taskID])
+		delete(memberByID, taskID)
+	}
+
+	// whatever is left was cancelled or deleted while it was waiting
+	for taskID, member := range memberByID {
+		logger.Info("Dropping delayed task that is no longer retryable", zap.String("task_id", taskID))
+		p.drop(ctx, member)
+	}
+
+	promoted, err := p.queue.PromoteDelayedTasks(ctx, ready)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	for _, task := range promoted {
+		lag := now.Sub(task.DueAt)
+		metrics.PromotionLag.Observe(lag.Seconds())
+
+		logger.Info("Promoted delayed task",
+			zap.String("task_id", task.TaskID),
+			zap.Int("priority", task.Priority),
+			zap.Duration("lag", lag),
+		)
+	}
+	metrics.TasksPromoted.Add(float64(len(promoted)))
+
+	return len(promoted), nil
+}
+
+func (p *Promoter) drop(ctx context.Context, member string) {
+	if err := p.queue.RemoveDelayedTask(ctx, member); err != nil {
+		logger.Error("Failed to remove delayed task", zap.String("member", member), zap.Error(err))
+	}
+}
@@ -0,0 +1,100 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/config"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/queue"
+	"github.com/alaajili/task-scheduler/shared/testutil"
+	"github.com/alaajili/task-scheduler/worker/internal/repository"
+	"github.com/alaajili/task-scheduler/worker/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromoter_PromotesDueRetries(t *testing.T) {
+	db := testutil.TestDB(t)
+	repo := repository.NewTaskRepository(db)
+	cfg, _ := config.LoadConfig("")
+	rq, err := queue.NewRedisQueue(cfg.Redis)
+	if err != nil {
+		t.Skip("redis not available")
+	}
+	defer rq.Close()
+	ctx := context.Background()
+
+	retry := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{"to": "a@example.com", "subject": "retry"}`), 6)
+	retry.State = models.TaskStateFailed
+	retry.RetryCount = 1
+	testutil.CreateTestTask(t, db, retry)
+
+	cancelled := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{"to": "b@example.com", "subject": "cancelled"}`), 6)
+	cancelled.State = models.TaskStateCancelled
+	testutil.CreateTestTask(t, db, cancelled)
+
+	require.NoError(t, rq.PublishDelayedTask(ctx, retry.ID, retry.Priority, -time.Second))
+	require.NoError(t, rq.PublishDelayedTask(ctx, cancelled.ID, cancelled.Priority, -time.Second))
+	t.Cleanup(func() {
+		rq.RemoveDelayedTask(ctx, cancelled.ID+":6")
+		taskID, _ := rq.PopTask(ctx, "promoter-test", time.Second)
+		rq.Ack(ctx, "promoter-test", taskID)
+	})
+
+	promoter := service.NewPromoter("promoter-test", repo, rq, time.Second)
+	promoted, err := promoter.PromoteOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, promoted)
+
+	updated, err := repo.GetTaskByID(ctx, retry.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.TaskStatePending, updated.State)
+
+	// the cancelled task is dropped instead of being promoted
+	stillCancelled, err := repo.GetTaskByID(ctx, cancelled.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.TaskStateCancelled, stillCancelled.State)
+
+	ready, err := rq.GetReadyDelayedTasks(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, ready)
+}
+
+func TestPromoter_PromotesDueScheduledTasks(t *testing.T) {
+	db := testutil.TestDB(t)
+	repo := repository.NewTaskRepository(db)
+	cfg, _ := config.LoadConfig("")
+	rq, err := queue.NewRedisQueue(cfg.Redis)
+	if err != nil {
+		t.Skip("redis not available")
+	}
+	defer rq.Close()
+	ctx := context.Background()
+
+	due := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{"to": "a@example.com", "subject": "due"}`), 4)
+	due.ScheduleAt(time.Now().Add(time.Hour))
+	testutil.CreateTestTask(t, db, due)
+
+	// workers polling the database leave the task alone until it is due
+	next, err := repo.GetNextPendingTask(ctx, []models.TaskType{models.TaskTypeEmailSend})
+	require.NoError(t, err)
+	assert.Nil(t, next)
+
+	require.NoError(t, rq.PublishDelayedTask(ctx, due.ID, due.Priority, -time.Second))
+	t.Cleanup(func() {
+		taskID, _ := rq.PopTask(ctx, "promoter-test", time.Second)
+		rq.Ack(ctx, "promoter-test", taskID)
+	})
+
+	promoted, err := service.NewPromoter("promoter-test", repo, rq, time.Second).PromoteOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, promoted)
+
+	updated, err := repo.GetTaskByID(ctx, due.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.TaskStatePending, updated.State)
+	assert.NotNil(t, updated.NotBefore)
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/logger"
+	"github.com/alaajili/task-scheduler/shared/metrics"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/queue"
+	"github.com/alaajili/task-scheduler/worker/internal/repository"
+	"go.uber.org/zap"
+)
+
+const reaperBatchSize = 100
+
+// Reaper recovers tasks left running by workers that stopped heartbeating.
+// Every worker runs one; recovering a task is a conditional update, so when
+// replicas race for the same task exactly one of them recovers it.
+type Reaper struct {
+	taskRepo  *repository.TaskRepository
+	queue     *queue.RedisQueue
+	threshold time.Duration
+	interval  time.Duration
+}
+
+func NewReaper(
+	taskRepo *repository.TaskRepository,
+	queue *queue.RedisQueue,
+	threshold time.Duration,
+	interval time.Duration,
+) *Reaper {
+	if threshold <= 0 {
+		threshold = 30 * time.Second
+	}
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+
+	return &Reaper{
+		taskRepo:  taskRepo,
+		queue:     queue,
+		threshold: threshold,
+		interval:  interval,
+	}
+}
+
+// Run looks for orphaned tasks on every tick until the context is cancelled
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Stale task reaper stopping")
+			return
+		case <-ticker.C:
+			if _, err := r.ReapOnce(ctx); err != nil {
+				logger.Error("Failed to reap stale tasks", zap.Error(err))
+			}
+		}
+	}
+}
+
+// ReapOnce recovers the orphaned tasks found right now and returns how many
+// this instance recovered. Tasks that can still be retried are requeued with
+// one more retry counted, the others are failed.
+func (r *Reaper) ReapOnce(ctx context.Context) (int, error) {
+	orphaned, err := r.taskRepo.GetOrphanedTasks(ctx, r.threshold, reaperBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	recovered := 0
+	for _, orphan := range orphaned {
+		if orphan.Worker != nil && orphan.Worker.Status != models.WorkerStatusShutdown &&
+			orphan.Worker.IsHealthy(r.threshold) {
+			continue
+		}
+
+		ok, err := r.recover(ctx, orphan.Task)
+		if err != nil {
+			logger.Error("Failed to recover orphaned task",
+				zap.String("task_id", orphan.Task.ID),
+				zap.String("worker_id", orphan.Task.WorkerID),
+				zap.Error(err),
+			)
+			continue
+		}
+		if ok {
+			recovered++
+		}
+	}
+
+	return recovered, nil
+}
+
+func (r *Reaper) recover(ctx context.Context, task *models.Task) (bool, error) {
+	reason := fmt.Sprintf("worker %s stopped heartbeating", task.WorkerID)
+
+	var ok bool
+	var err error
+	state, outcome := models.TaskStatePending, "requeued"
+	if task.CanRetry() {
+		ok, err = r.taskRepo.RequeueOrphanedTask(ctx, task.ID, task.WorkerID, reason, r.threshold)
+	} else {
+		state, outcome = models.TaskStateFailed, "failed"
+		ok, err = r.taskRepo.FailOrphanedTask(ctx, task.ID, task.WorkerID, reason, r.threshold)
+	}
+	if err != nil || !ok {
+		return false, err
+	}
+
+	// the dead worker may still hold a lease on the task
+	if state == models.TaskStatePending {
+		err = r.queue.RequeueTask(ctx, task.ID, task.Priority)
+	} else {
+		err = r.queue.RevokeLease(ctx, task.ID)
+	}
+	if err != nil {
+		logger.Error("Failed to update the queue for a recovered task",
+			zap.String("task_id", task.ID),
+			zap.Error(err),
+		)
+	}
+
+	metrics.TasksRecovered.WithLabelValues(outcome).Inc()
+	logger.Warn("Recovered orphaned task",
+		zap.String("task_id", task.ID),
+		zap.String("worker_id", task.WorkerID),
+		zap.String("state", string(state)),
+	)
+
+	return true, nil
+}
@@ -0,0 +1,99 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alaajili/task-scheduler/shared/config"
+	"github.com/alaajili/task-scheduler/shared/models"
+	"github.com/alaajili/task-scheduler/shared/queue"
+	"github.com/alaajili/task-scheduler/shared/testutil"
+	"github.com/alaajili/task-scheduler/worker/internal/repository"
+	"github.com/alaajili/task-scheduler/worker/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaper_RecoversTasksOfDeadWorkers(t *testing.T) {
+	db := testutil.TestDB(t)
+	taskRepo := repository.NewTaskRepository(db)
+	workerRepo := repository.NewWorkerRepository(db)
+	cfg, _ := config.LoadConfig("")
+	rq, err := queue.NewRedisQueue(cfg.Redis)
+	if err != nil {
+		t.Skip("redis not available")
+	}
+	defer rq.Close()
+	ctx := context.Background()
+
+	dead := models.NewWorker([]models.TaskType{models.TaskTypeEmailSend})
+	dead.ID = "worker-dead"
+	require.NoError(t, workerRepo.RegisterWorker(ctx, dead))
+	_, err = db.ExecContext(ctx, `UPDATE workers SET last_heartbeat = NOW() - INTERVAL '5 minutes' WHERE id = $1`, dead.ID)
+	require.NoError(t, err)
+
+	alive := models.NewWorker([]models.TaskType{models.TaskTypeEmailSend})
+	alive.ID = "worker-alive"
+	require.NoError(t, workerRepo.RegisterWorker(ctx, alive))
+
+	payload := json.RawMessage(`{"to": "a@example.com", "subject": "orphan"}`)
+	retryable := models.NewTask(models.TaskTypeEmailSend, payload, 5)
+	exhausted := models.NewTask(models.TaskTypeEmailSend, payload, 5)
+	exhausted.RetryCount = exhausted.MaxRetries
+	healthy := models.NewTask(models.TaskTypeEmailSend, payload, 5)
+	for _, task := range []*models.Task{retryable, exhausted, healthy} {
+		testutil.CreateTestTask(t, db, task)
+	}
+	require.NoError(t, taskRepo.MarkTaskStarted(ctx, retryable.ID, dead.ID))
+	require.NoError(t, taskRepo.MarkTaskStarted(ctx, exhausted.ID, dead.ID))
+	require.NoError(t, taskRepo.MarkTaskStarted(ctx, healthy.ID, alive.ID))
+	t.Cleanup(func() {
+		for {
+			taskID, _ := rq.PopTask(ctx, "reaper-test", time.Second)
+			if taskID == "" {
+				return
+			}
+			rq.Ack(ctx, "reaper-test", taskID)
+		}
+	})
+
+	reaper := service.NewReaper(taskRepo, rq, 30*time.Second, time.Second)
+	recovered, err := reaper.ReapOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, recovered)
+
+	requeued, err := taskRepo.GetTaskByID(ctx, retryable.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.TaskStatePending, requeued.State)
+	assert.Equal(t, 1, requeued.RetryCount)
+	assert.Empty(t, requeued.WorkerID)
+
+	failed, err := taskRepo.GetTaskByID(ctx, exhausted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.TaskStateFailed, failed.State)
+	assert.Contains(t, failed.Error, dead.ID)
+
+	// the recovery and its reason are in the event log of the task
+	var (
+		workerID string
+		message  string
+	)
+	err = db.QueryRowContext(ctx,
+		`SELECT worker_id, message FROM task_events WHERE task_id = $1 AND type = 'recovered' AND state = 'failed'`,
+		exhausted.ID,
+	).Scan(&workerID, &message)
+	require.NoError(t, err)
+	assert.Equal(t, dead.ID, workerID)
+	assert.Contains(t, message, "stopped heartbeating")
+
+	stillRunning, err := taskRepo.GetTaskByID(ctx, healthy.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.TaskStateRunning, stillRunning.State)
+
+	// a second reaper finds nothing left to recover
+	recovered, err = service.NewReaper(taskRepo, rq, 30*time.Second, time.Second).ReapOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, recovered)
+}
@@ -2,28 +2,39 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
+	"sync"
 	"time"
 
+	"github.com/alaajili/task-scheduler/shared/config"
 	"github.com/alaajili/task-scheduler/shared/logger"
 	"github.com/alaajili/task-scheduler/shared/models"
 	"github.com/alaajili/task-scheduler/shared/queue"
 	"github.com/alaajili/task-scheduler/worker/internal/executor"
+	"github.com/alaajili/task-scheduler/worker/internal/payload"
 	"github.com/alaajili/task-scheduler/worker/internal/repository"
 	"go.uber.org/zap"
 )
 
+// errTaskCancelled is the cause attached to the context of a task that was
+// cancelled through the API while running
+var errTaskCancelled = errors.New("task cancelled")
 
 type WorkerService struct {
-	workerID string
-	taskRepo   *repository.TaskRepository
-	queue      *queue.RedisQueue
-	executor   *executor.Executor
-	taskTypes  []models.TaskType
-	maxRetries int
-	useQueue   bool
+	workerID      string
+	taskRepo      *repository.TaskRepository
+	queue         *queue.RedisQueue
+	executor      *executor.Executor
+	taskTypes     []models.TaskType
+	maxRetries    int
+	useQueue      bool
+	leaseDuration time.Duration
+
+	mu      sync.Mutex
+	running map[string]context.CancelCauseFunc // task id -> cancels its execution
 }
 
 func NewWorkerService(
@@ -31,47 +42,89 @@ func NewWorkerService(
 	taskRepo  *repository.TaskRepository,
 	queue     *queue.RedisQueue,
 	taskTypes []models.TaskType,
+	cfg       config.WorkerConfig,
 ) *WorkerService {
+	leaseDuration := cfg.VisibilityTimeout
+	if leaseDuration <= 0 {
+		leaseDuration = time.Minute
+	}
+
+	exec := executor.NewExecutor(workerID)
+	exec.SetDefaultTimeout(cfg.TaskTimeout)
+	for taskType, timeout := range cfg.TaskTypeTimeouts {
+		exec.SetTypeTimeout(models.TaskType(taskType), timeout)
+	}
+
 	return &WorkerService{
-		workerID:   workerID,
-		taskRepo:   taskRepo,
-		queue:      queue,
-		executor:   executor.NewExecutor(workerID),
-		taskTypes:  taskTypes,
-		maxRetries: 5,
-		useQueue:   true,
+		workerID:      workerID,
+		taskRepo:      taskRepo,
+		queue:         queue,
+		executor:      exec,
+		taskTypes:     taskTypes,
+		maxRetries:    5,
+		useQueue:      true,
+		leaseDuration: leaseDuration,
+		running:       make(map[string]context.CancelCauseFunc),
 	}
 }
 
+// ClaimedTask is a task this worker marked as started and still has to run
+type ClaimedTask struct {
+	Task   *models.Task
+	leased bool
+}
+
 // ProcessNextTask fetches and process the next available task
 func (s *WorkerService) ProcessNextTask(ctx context.Context) (bool, error) {
+	claimed, err := s.ClaimNextTask(ctx)
+	if err != nil || claimed == nil {
+		return false, err
+	}
+
+	return true, s.RunTask(ctx, claimed)
+}
+
+// ClaimNextTask fetches the next available task and marks it as started by
+// this worker. It returns nil when there is nothing to do.
+func (s *WorkerService) ClaimNextTask(ctx context.Context) (*ClaimedTask, error) {
 	var task *models.Task
 	var err error
+	leased := false
 	
 	if s.useQueue && s.queue != nil {
-		// get task from redis queue
-		taskID, err := s.queue.PopTask(ctx)
+		// get task from redis queue, it stays leased to this worker until acked
+		var taskID string
+		taskID, err = s.queue.PopTask(ctx, s.workerID, s.leaseDuration)
 		if err != nil {
 			logger.Error("Failed to get task from the queue", zap.Error(err))
 			// fallback to databse polling
 			task, err = s.taskRepo.GetNextPendingTask(ctx, s.taskTypes)
 		} else if taskID == "" {
-			return false, nil // no tasks in the queue 
+			return nil, nil // no tasks in the queue 
 		} else {
 			// get task details from db
+			leased = true
 			task, err = s.taskRepo.GetTaskByID(ctx, taskID)
+			if errors.Is(err, repository.ErrTaskNotFound) {
+				// the task row is gone, nothing left to execute
+				s.releaseLease(ctx, taskID, false)
+				return nil, err
+			}
+			if err != nil {
+				s.releaseLease(ctx, taskID, true)
+			}
 		}
 	} else {
 		task, err = s.taskRepo.GetNextPendingTask(ctx, s.taskTypes) // db polling
 	}
 	
 	if err != nil {
-		return false, fmt.Errorf("failed to get next task: %w", err)
+		return nil, fmt.Errorf("failed to get next task: %w", err)
 	}
 	
 	// no tasks available
 	if task == nil {
-		return false, nil
+		return nil, nil
 	}
 	
 	logger.Info("Processing task",
@@ -82,14 +135,75 @@ func (s *WorkerService) ProcessNextTask(ctx context.Context) (bool, error) {
 	
 	// mark the test as started
 	if err := s.taskRepo.MarkTaskStarted(ctx, task.ID, s.workerID); err != nil {
+		if errors.Is(err, repository.ErrTaskNotPending) {
+			// cancelled or taken by another worker, there is nothing to run
+			logger.Info("Skipping task that is no longer pending",
+				zap.String("task_id", task.ID),
+			)
+			if leased {
+				s.releaseLease(ctx, task.ID, false)
+			}
+			return nil, nil
+		}
 		logger.Error("Failed to mark task as started",
 			zap.String("task_id", task.ID),
 			zap.Error(err),
 		)
-		return false, err
+		if leased {
+			s.releaseLease(ctx, task.ID, true)
+		}
+		return nil, err
+	}
+
+	return &ClaimedTask{Task: task, leased: leased}, nil
+}
+
+// RunTask executes a claimed task and records its outcome
+func (s *WorkerService) RunTask(ctx context.Context, claimed *ClaimedTask) error {
+	task := claimed.Task
+
+	requeue := false
+	if claimed.leased {
+		stopLease := s.keepLease(ctx, task.ID)
+		defer func() {
+			stopLease()
+			// still release the lease when the run was cancelled
+			s.releaseLease(context.WithoutCancel(ctx), task.ID, requeue)
+		}()
 	}
 	
-	err = s.executor.ExecuteTask(ctx, task)
+	taskCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	s.track(task.ID, cancel)
+	defer s.untrack(task.ID)
+	taskCtx = executor.WithProgress(taskCtx, func(progress float64, message string) {
+		if err := s.taskRepo.RecordProgress(ctx, task.ID, s.workerID, progress, message); err != nil {
+			logger.Warn("Failed to record task progress",
+				zap.String("task_id", task.ID),
+				zap.Error(err),
+			)
+		}
+	})
+	
+	err := s.renderPayload(taskCtx, task)
+	if err == nil {
+		err = s.executor.ExecuteTask(taskCtx, task)
+	}
+	if err != nil && errors.Is(context.Cause(taskCtx), errTaskCancelled) {
+		// the row is already cancelled, only the attempt and the lease are
+		// left to close
+		logger.Info("Task cancelled while running",
+			zap.String("task_id", task.ID),
+		)
+		s.closeCancelledAttempt(context.WithoutCancel(ctx), task.ID)
+		return nil
+	}
+	if err != nil && ctx.Err() != nil {
+		// the worker is shutting down, hand the task back without counting a retry
+		requeue = claimed.leased
+		s.releaseInterrupted(context.WithoutCancel(ctx), claimed)
+		return nil
+	}
 	if err != nil {
 		logger.Error("Task execution failed",
 			zap.String("task_id", task.ID),
@@ -102,30 +216,150 @@ func (s *WorkerService) ProcessNextTask(ctx context.Context) (bool, error) {
 				zap.Error(err),
 			)
 		}
-		return true, nil
+		return nil
 	}
 	
 	// mark task completed
 	if err := s.taskRepo.MarkTaskCompleted(ctx, task.ID, task.Result); err != nil {
+		if errors.Is(err, repository.ErrTaskNotRunning) {
+			logger.Info("Task is no longer running, discarding its result",
+				zap.String("task_id", task.ID),
+			)
+			s.closeCancelledAttempt(ctx, task.ID)
+			return nil
+		}
 		logger.Error("Failed to mark task as completed",
 			zap.String("task_id", task.ID),
 			zap.Error(err),
 		)
-		return true, err
+		return err
 	}
 	
 	logger.Info("Task completed successfully",
 		zap.String("task_id", task.ID),
 	)
 	
-	return true, nil
+	return nil
+}
+
+// CancelTask stops the execution of a task running on this worker and
+// reports whether it was running here
+func (s *WorkerService) CancelTask(taskID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cancel, ok := s.running[taskID]
+	if ok {
+		cancel(errTaskCancelled)
+	}
+	return ok
+}
+
+// ListenForCancellations cancels the tasks running on this worker as soon as
+// they are cancelled through the API, until the context is cancelled
+func (s *WorkerService) ListenForCancellations(ctx context.Context) {
+	for ctx.Err() == nil {
+		taskIDs, err := s.queue.SubscribeCancellations(ctx)
+		if err != nil {
+			logger.Error("Failed to subscribe to task cancellations", zap.Error(err))
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		for taskID := range taskIDs {
+			if s.CancelTask(taskID) {
+				logger.Info("Cancelling running task",
+					zap.String("task_id", taskID),
+				)
+			}
+		}
+	}
+	logger.Info("Cancellation listener stopping")
+}
+
+func (s *WorkerService) track(taskID string, cancel context.CancelCauseFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running[taskID] = cancel
+}
+
+func (s *WorkerService) untrack(taskID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, taskID)
+}
+
+// releaseInterrupted puts a task whose run was cut short back to pending. A
+// leased task is requeued by nacking its lease, any other one is published again.
+func (s *WorkerService) releaseInterrupted(ctx context.Context, claimed *ClaimedTask) {
+	task := claimed.Task
+	logger.Warn("Task interrupted, releasing it",
+		zap.String("task_id", task.ID),
+	)
+	
+	if err := s.taskRepo.ReleaseTask(ctx, task.ID, s.workerID); err != nil {
+		logger.Error("Failed to release interrupted task",
+			zap.String("task_id", task.ID),
+			zap.Error(err),
+		)
+		return
+	}
+	
+	if !claimed.leased && s.useQueue && s.queue != nil {
+		if err := s.queue.PublishTask(ctx, task.ID, task.Priority); err != nil {
+			logger.Error("Failed to publish interrupted task",
+				zap.String("task_id", task.ID),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// closeCancelledAttempt closes the attempt of a task that stopped running
+// under this worker, the attempt is only history so a failure is just logged
+func (s *WorkerService) closeCancelledAttempt(ctx context.Context, taskID string) {
+	if err := s.taskRepo.MarkAttemptCancelled(ctx, taskID); err != nil {
+		logger.Warn("Failed to close cancelled task attempt",
+			zap.String("task_id", taskID),
+			zap.Error(err),
+		)
+	}
 }
 
 func (s *WorkerService) handleTaskFailure(ctx context.Context, task *models.Task, execErr error) error {
-	if err := s.taskRepo.MarkTaskFailed(ctx, task.ID, execErr.Error()); err != nil {
+	reason := models.ErrorReasonHandler
+	final := false
+	switch {
+	case errors.Is(execErr, executor.ErrTaskTimedOut):
+		reason = models.ErrorReasonTimedOut
+	case errors.Is(execErr, payload.ErrInvalidTemplate):
+		// the parents' results will not change, retrying cannot help
+		reason = models.ErrorReasonInvalidPayload
+		final = true
+	}
+
+	if err := s.taskRepo.MarkTaskFailed(ctx, task.ID, execErr.Error(), reason, final); err != nil {
+		if errors.Is(err, repository.ErrTaskNotRunning) {
+			logger.Info("Task is no longer running, not retrying it",
+				zap.String("task_id", task.ID),
+			)
+			s.closeCancelledAttempt(ctx, task.ID)
+			return nil
+		}
 		return err
 	}
 	
+	if final {
+		logger.Warn("Task failed permanently, moved to dead letters",
+			zap.String("task_id", task.ID),
+			zap.String("reason", string(reason)),
+		)
+		return nil
+	}
+
 	if task.RetryCount+1 < task.MaxRetries {
 		delay := s.calculateRetryDelay(task.RetryCount + 1)
 		logger.Info("Scheduling task for retry",
@@ -133,9 +367,15 @@ func (s *WorkerService) handleTaskFailure(ctx context.Context, task *models.Task
 			zap.Int("retry_count", task.RetryCount+1),
 			zap.Duration("retry_delay", delay),
 		)
+		if err := s.taskRepo.RecordRetryScheduled(ctx, task.ID, delay); err != nil {
+			logger.Warn("Failed to record task retry",
+				zap.String("task_id", task.ID),
+				zap.Error(err),
+			)
+		}
 		
 		if s.useQueue && s.queue != nil {
-			if err := s.queue.PublishDelayedTask(ctx, task.ID, task.Priority, delay); err != nil {
+			if err := s.publishRetry(ctx, task, delay); err != nil {
 				logger.Error("Failed to publish delayed task",
 					zap.String("task_id", task.ID),
 					zap.Error(err),
@@ -148,7 +388,7 @@ func (s *WorkerService) handleTaskFailure(ctx context.Context, task *models.Task
 			return s.taskRepo.MarkTaskForRetry(ctx, task.ID, delay)
 		}
 	} else {
-		logger.Warn("Task exceeded max retries",
+		logger.Warn("Task exceeded max retries, moved to dead letters",
 			zap.String("task_id", task.ID),
 			zap.Int("retry_count", task.RetryCount+1),
 		)
@@ -157,6 +397,90 @@ func (s *WorkerService) handleTaskFailure(ctx context.Context, task *models.Task
 	return nil
 }
 
+// publishRetry sends a failed task to the delayed queue, a task with a unique
+// key only goes back to the task queue once nothing else holds the key
+func (s *WorkerService) publishRetry(ctx context.Context, task *models.Task, delay time.Duration) error {
+	if key := task.QueueUniqueKey(); key != "" {
+		return s.queue.PublishDelayedUniqueTask(ctx, task.ID, task.Priority, delay, key)
+	}
+	return s.queue.PublishDelayedTask(ctx, task.ID, task.Priority, delay)
+}
+
+// renderPayload resolves the references to parent results in the payload of a
+// task right before it runs
+func (s *WorkerService) renderPayload(ctx context.Context, task *models.Task) error {
+	if !payload.HasTemplate(task.Payload) {
+		return nil
+	}
+
+	parents, err := s.taskRepo.GetTaskParents(ctx, task.ID)
+	if err != nil {
+		return err
+	}
+	if len(parents) == 0 {
+		// nothing to reference, the payload is the task's own
+		return nil
+	}
+	rendered, err := payload.Render(task.Payload, parents)
+	if err != nil {
+		return err
+	}
+	task.Payload = rendered
+	return nil
+}
+
+// keepLease extends the queue lease of a running task until the returned
+// function is called, so a slow task is not handed to another worker
+func (s *WorkerService) keepLease(ctx context.Context, taskID string) func() {
+	leaseCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(s.leaseDuration / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-leaseCtx.Done():
+				return
+			case <-ticker.C:
+				if err := s.queue.ExtendLease(leaseCtx, s.workerID, taskID, s.leaseDuration); err != nil {
+					logger.Warn("Failed to extend task lease",
+						zap.String("task_id", taskID),
+						zap.Error(err),
+					)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// releaseLease acks a leased task, or nacks it when it should be retried by
+// another attempt
+func (s *WorkerService) releaseLease(ctx context.Context, taskID string, requeue bool) {
+	var err error
+	if requeue {
+		err = s.queue.Nack(ctx, s.workerID, taskID)
+	} else {
+		err = s.queue.Ack(ctx, s.workerID, taskID)
+	}
+
+	if err != nil {
+		logger.Warn("Failed to release task lease",
+			zap.String("task_id", taskID),
+			zap.Bool("requeue", requeue),
+			zap.Error(err),
+		)
+	}
+}
+
 func (s *WorkerService) calculateRetryDelay(retryCount int) time.Duration {
 	baseDelay := 5.0
 	
@@ -3,7 +3,9 @@ package service_test
 import (
 	"context"
 	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/alaajili/task-scheduler/shared/config"
 	"github.com/alaajili/task-scheduler/shared/models"
@@ -28,7 +30,7 @@ func setupWorkerTest(t *testing.T) (*service.WorkerService, *repository.TaskRepo
 		models.TaskTypeLongRunning,
 	}
 	
-	workerService := service.NewWorkerService("test-worker", repo, rq,taskTypes)
+	workerService := service.NewWorkerService("test-worker", repo, rq, taskTypes, cfg.Worker)
 	
 	return workerService, repo
 }
@@ -92,6 +94,28 @@ func TestProcessNextTask_TaskFailsAndRetries(t *testing.T) {
 	assert.Equal(t, models.TaskStateFailed, updatedTask.State)
 	assert.Equal(t, 1, updatedTask.RetryCount)
 	assert.NotEmpty(t, updatedTask.Error)
+	assert.Equal(t, models.ErrorReasonHandler, updatedTask.ErrorReason)
+}
+
+func TestProcessNextTask_TaskTimesOut(t *testing.T) {
+	workerService, repo := setupWorkerTest(t)
+	ctx := context.Background()
+
+	// the task asks for less time than its work takes
+	payload := json.RawMessage(`{"duration_seconds": 5, "step_count": 5}`)
+	task := models.NewTask(models.TaskTypeLongRunning, payload, 5)
+	task.TimeoutSeconds = 1
+	testutil.CreateTestTask(t, repo.DB(), task)
+
+	processed, err := workerService.ProcessNextTask(ctx)
+	require.NoError(t, err)
+	assert.True(t, processed)
+
+	updatedTask, err := repo.GetTaskByID(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.TaskStateFailed, updatedTask.State)
+	assert.Equal(t, models.ErrorReasonTimedOut, updatedTask.ErrorReason)
+	assert.Contains(t, updatedTask.Error, "timed out")
 }
 
 func TestProcessNextTask_HTTPRequest(t *testing.T) {
@@ -188,6 +212,279 @@ func TestProcessNextTask_DataProcessing(t *testing.T) {
 	assert.Equal(t, float64(2), result["records_count"])
 }
 
+func TestRunTask_InterruptedTaskIsReleased(t *testing.T) {
+	workerService, repo := setupWorkerTest(t)
+
+	payload := json.RawMessage(`{"duration_seconds": 10, "step_count": 10}`)
+	task := models.NewTask(models.TaskTypeLongRunning, payload, 5)
+	testutil.CreateTestTask(t, repo.DB(), task)
+
+	claimed, err := workerService.ClaimNextTask(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+
+	// the worker gives up on the task, e.g. the drain timed out
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.NoError(t, workerService.RunTask(ctx, claimed))
+
+	// it goes back to pending without counting a retry
+	updatedTask, err := repo.GetTaskByID(context.Background(), task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.TaskStatePending, updatedTask.State)
+	assert.Equal(t, 0, updatedTask.RetryCount)
+	assert.Empty(t, updatedTask.WorkerID)
+	assert.Nil(t, updatedTask.StartedAt)
+}
+
+func TestRunTask_CancelledWhileRunning(t *testing.T) {
+	workerService, repo := setupWorkerTest(t)
+	ctx := context.Background()
+
+	payload := json.RawMessage(`{"duration_seconds": 10, "step_count": 10}`)
+	task := models.NewTask(models.TaskTypeLongRunning, payload, 5)
+	testutil.CreateTestTask(t, repo.DB(), task)
+
+	claimed, err := workerService.ClaimNextTask(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+
+	done := make(chan error, 1)
+	go func() { done <- workerService.RunTask(ctx, claimed) }()
+
+	// the api cancels the row, then notifies the worker
+	_, err = repo.DB().ExecContext(ctx, `UPDATE tasks SET state = 'cancelled' WHERE id = $1`, task.ID)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return workerService.CancelTask(task.ID) }, time.Second, 10*time.Millisecond)
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("task was not cancelled")
+	}
+
+	updatedTask, err := repo.GetTaskByID(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.TaskStateCancelled, updatedTask.State)
+
+	attempts := listAttempts(t, repo, task.ID)
+	require.Len(t, attempts, 1)
+	assert.Equal(t, models.AttemptStateCancelled, attempts[0].State)
+	assert.NotNil(t, attempts[0].FinishedAt)
+}
+
+// listAttempts returns the attempts recorded for a task, oldest first
+func listAttempts(t *testing.T, repo *repository.TaskRepository, taskID string) []models.TaskAttempt {
+	rows, err := repo.DB().QueryContext(context.Background(), `
+		SELECT attempt, worker_id, state, finished_at, COALESCE(error, ''), COALESCE(result_size, -1), retry_at
+		FROM task_attempts
+		WHERE task_id = $1
+		ORDER BY attempt
+	`, taskID)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var attempts []models.TaskAttempt
+	for rows.Next() {
+		var a models.TaskAttempt
+		var resultSize int
+		require.NoError(t, rows.Scan(&a.Attempt, &a.WorkerID, &a.State, &a.FinishedAt, &a.Error, &resultSize, &a.RetryAt))
+		if resultSize >= 0 {
+			a.ResultSize = &resultSize
+		}
+		attempts = append(attempts, a)
+	}
+	require.NoError(t, rows.Err())
+	return attempts
+}
+
+func TestProcessNextTask_RecordsAttempts(t *testing.T) {
+	workerService, repo := setupWorkerTest(t)
+	ctx := context.Background()
+
+	payload := json.RawMessage(`{"duration_seconds": 1, "step_count": 1, "simulate_error": true}`)
+	failing := models.NewTask(models.TaskTypeLongRunning, payload, 5)
+	failing.MaxRetries = 3
+	testutil.CreateTestTask(t, repo.DB(), failing)
+
+	// every run of the task is an attempt, a failure that is retried says when
+	for i := 0; i < 2; i++ {
+		_, err := repo.DB().ExecContext(ctx, `UPDATE tasks SET state = 'pending' WHERE id = $1`, failing.ID)
+		require.NoError(t, err)
+		processed, err := workerService.ProcessNextTask(ctx)
+		require.NoError(t, err)
+		require.True(t, processed)
+	}
+
+	attempts := listAttempts(t, repo, failing.ID)
+	require.Len(t, attempts, 2)
+	for i, a := range attempts {
+		assert.Equal(t, i+1, a.Attempt)
+		assert.Equal(t, "test-worker", a.WorkerID)
+		assert.Equal(t, models.AttemptStateFailed, a.State)
+		assert.Contains(t, a.Error, "simulated error")
+		assert.NotNil(t, a.FinishedAt)
+		assert.NotNil(t, a.RetryAt)
+	}
+
+	// ahead of the failing task, which may be pending again
+	task := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{"to": "user@example.com"}`), 9)
+	testutil.CreateTestTask(t, repo.DB(), task)
+	processed, err := workerService.ProcessNextTask(ctx)
+	require.NoError(t, err)
+	require.True(t, processed)
+
+	updatedTask, err := repo.GetTaskByID(ctx, task.ID)
+	require.NoError(t, err)
+	attempts = listAttempts(t, repo, task.ID)
+	require.Len(t, attempts, 1)
+	assert.Equal(t, models.AttemptStateCompleted, attempts[0].State)
+	require.NotNil(t, attempts[0].ResultSize)
+	assert.Equal(t, len(updatedTask.Result), *attempts[0].ResultSize)
+}
+
+func TestProcessNextTask_ReleasesDependents(t *testing.T) {
+	workerService, repo := setupWorkerTest(t)
+	ctx := context.Background()
+
+	payload := json.RawMessage(`{"to": "a@example.com", "subject": "hi"}`)
+	parent := models.NewTask(models.TaskTypeEmailSend, payload, 5)
+	other := models.NewTask(models.TaskTypeEmailSend, payload, 5)
+	other.State = models.TaskStateRunning
+	child := models.NewTask(models.TaskTypeEmailSend, payload, 5)
+	child.State = models.TaskStateBlocked
+	for _, task := range []*models.Task{parent, other, child} {
+		testutil.CreateTestTask(t, repo.DB(), task)
+	}
+	testutil.CreateTestDependency(t, repo.DB(), child.ID, parent.ID)
+	testutil.CreateTestDependency(t, repo.DB(), child.ID, other.ID)
+
+	processed, err := workerService.ProcessNextTask(ctx)
+	require.NoError(t, err)
+	assert.True(t, processed)
+
+	// the child still waits for the other task
+	assert.Equal(t, models.TaskStateBlocked, testutil.GetTestTaskByID(t, repo.DB(), child.ID).State)
+
+	require.NoError(t, repo.MarkTaskCompleted(ctx, other.ID, json.RawMessage(`{}`)))
+	assert.Equal(t, models.TaskStatePending, testutil.GetTestTaskByID(t, repo.DB(), child.ID).State)
+
+	var outboxEntries int
+	require.NoError(t, repo.DB().QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM task_outbox WHERE task_id = $1`, child.ID,
+	).Scan(&outboxEntries))
+	assert.Equal(t, 1, outboxEntries)
+}
+
+func TestProcessNextTask_FailedDependencyFailsDependents(t *testing.T) {
+	workerService, repo := setupWorkerTest(t)
+	ctx := context.Background()
+
+	// a task without retries left
+	parent := models.NewTask(models.TaskTypeLongRunning, json.RawMessage(`{
+		"duration_seconds": 1,
+		"step_count": 2,
+		"simulate_error": true,
+		"error_after": 0
+	}`), 5)
+	parent.MaxRetries = 1
+	child := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{}`), 5)
+	child.State = models.TaskStateBlocked
+	grandchild := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{}`), 5)
+	grandchild.State = models.TaskStateBlocked
+	for _, task := range []*models.Task{parent, child, grandchild} {
+		testutil.CreateTestTask(t, repo.DB(), task)
+	}
+	testutil.CreateTestDependency(t, repo.DB(), child.ID, parent.ID)
+	testutil.CreateTestDependency(t, repo.DB(), grandchild.ID, child.ID)
+
+	processed, err := workerService.ProcessNextTask(ctx)
+	require.NoError(t, err)
+	assert.True(t, processed)
+
+	for _, id := range []string{child.ID, grandchild.ID} {
+		task := testutil.GetTestTaskByID(t, repo.DB(), id)
+		assert.Equal(t, models.TaskStateFailed, task.State)
+		assert.Equal(t, models.ErrorReasonDependencyFailed, task.ErrorReason)
+		assert.False(t, task.CanRetry())
+	}
+}
+
+// createCompletedParent creates a completed task with an http_request result
+// and a pending child task depending on it, PARENT_ID in the child's payload
+// is replaced with the parent's id
+func createCompletedParent(t *testing.T, repo *repository.TaskRepository, childPayload string) (parent, child *models.Task) {
+	t.Helper()
+
+	parent = models.NewTask(models.TaskTypeHTTPRequest, json.RawMessage(`{}`), 5)
+	parent.State = models.TaskStateCompleted
+	childPayload = strings.ReplaceAll(childPayload, "PARENT_ID", parent.ID)
+	child = models.NewTask(models.TaskTypeEmailSend, json.RawMessage(childPayload), 5)
+	testutil.CreateTestTask(t, repo.DB(), parent)
+	testutil.CreateTestTask(t, repo.DB(), child)
+	testutil.CreateTestDependency(t, repo.DB(), child.ID, parent.ID)
+
+	_, err := repo.DB().ExecContext(context.Background(),
+		`UPDATE tasks SET result = $2 WHERE id = $1`,
+		parent.ID, `{"status_code": 200, "body": "{\"email\": \"b@example.com\"}"}`,
+	)
+	require.NoError(t, err)
+	return parent, child
+}
+
+func TestProcessNextTask_RendersParentResults(t *testing.T) {
+	workerService, repo := setupWorkerTest(t)
+	ctx := context.Background()
+
+	// without the parent's result the email has no recipient and fails
+	_, child := createCompletedParent(t, repo, `{"subject": "hi", "to": "{{ .parent.PARENT_ID.result.body.email }}"}`)
+
+	processed, err := workerService.ProcessNextTask(ctx)
+	require.NoError(t, err)
+	assert.True(t, processed)
+
+	assert.Equal(t, models.TaskStateCompleted, testutil.GetTestTaskByID(t, repo.DB(), child.ID).State)
+}
+
+func TestProcessNextTask_MissingParentResultFailsTask(t *testing.T) {
+	workerService, repo := setupWorkerTest(t)
+	ctx := context.Background()
+
+	_, child := createCompletedParent(t, repo, `{"subject": "hi", "to": "{{ .parent.nobody.result.body.email }}"}`)
+
+	processed, err := workerService.ProcessNextTask(ctx)
+	require.NoError(t, err)
+	assert.True(t, processed)
+
+	updatedTask := testutil.GetTestTaskByID(t, repo.DB(), child.ID)
+	assert.Equal(t, models.TaskStateFailed, updatedTask.State)
+	assert.Equal(t, models.ErrorReasonInvalidPayload, updatedTask.ErrorReason)
+	assert.Contains(t, updatedTask.Error, "nobody is not a parent of this task")
+	// the reference will not appear on a retry
+	assert.False(t, updatedTask.CanRetry())
+}
+
+func TestMarkTaskCompleted_CancelledTaskStaysCancelled(t *testing.T) {
+	_, repo := setupWorkerTest(t)
+	ctx := context.Background()
+
+	task := models.NewTask(models.TaskTypeEmailSend, json.RawMessage(`{"to": "a@example.com"}`), 5)
+	task.State = models.TaskStateCancelled
+	testutil.CreateTestTask(t, repo.DB(), task)
+
+	err := repo.MarkTaskCompleted(ctx, task.ID, json.RawMessage(`{"sent": true}`))
+	assert.ErrorIs(t, err, repository.ErrTaskNotRunning)
+	err = repo.MarkTaskFailed(ctx, task.ID, "boom", models.ErrorReasonHandler, false)
+	assert.ErrorIs(t, err, repository.ErrTaskNotRunning)
+	err = repo.MarkTaskStarted(ctx, task.ID, "test-worker")
+	assert.ErrorIs(t, err, repository.ErrTaskNotPending)
+
+	updatedTask, err := repo.GetTaskByID(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.TaskStateCancelled, updatedTask.State)
+}
+
 // func TestRetryDelay_ExponentialBackoff(t *testing.T) {
 // 	workerService, _ := setupWorkerTest(t)
 